@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	errAlreadyBootstrapped = errors.New("server is already bootstrapped")
+	errEmptyPassword       = errors.New("password must not be empty")
+)
+
+// accountStores holds the account database stores opened by admin commands.
+type accountStores struct {
+	conn       core.Connection
+	users      core.UserStore
+	tokens     core.TokenStore
+	files      core.FileStore
+	apiKeys    core.APIKeyStore
+	twoFactors core.TwoFactorStore
+	audit      core.AuditStore
+}
+
+// openAccountStores opens the configured account database without starting
+// the server. Callers must close the returned connection.
+func openAccountStores() *accountStores {
+	storageConfig := resolveStorageConfig(resolveDataPath())
+	conn, users, tokens, files, apiKeys, twoFactors, audit, err := storage.NewAccountStores(
+		core.StorageType(viper.GetString("storage")),
+		storageConfig,
+	)
+	cobra.CheckErr(err)
+
+	return &accountStores{
+		conn:       conn,
+		users:      users,
+		tokens:     tokens,
+		files:      files,
+		apiKeys:    apiKeys,
+		twoFactors: twoFactors,
+		audit:      audit,
+	}
+}
+
+// userForName looks up a user by name, falling back to the bootstrap user.
+func (it *accountStores) userForName(name string) *core.User {
+	if name == "" {
+		name = core.DefaultUserName
+	}
+	user, err := it.users.ForUserName(name)
+	if err != nil {
+		cobra.CheckErr(fmt.Errorf("user '%s' not found: %w", name, err))
+	}
+	return user
+}
+
+// userNames maps user ids to user names for display.
+func (it *accountStores) userNames() map[core.UserID]string {
+	users, err := it.users.All()
+	cobra.CheckErr(err)
+
+	names := make(map[core.UserID]string, len(users))
+	for _, u := range users {
+		names[u.UserID] = u.UserName
+	}
+	return names
+}
+
+// printOutput writes data as JSON when the --json flag is set, otherwise it
+// lets text render a human readable table.
+func printOutput(cmd *cobra.Command, data any, text func(w io.Writer)) {
+	out := cmd.OutOrStdout()
+	if viper.GetBool("json") {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		cobra.CheckErr(enc.Encode(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	text(w)
+	cobra.CheckErr(w.Flush())
+}
+
+type statusOutput struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func printStatus(cmd *cobra.Command, message string) {
+	printOutput(cmd, &statusOutput{Status: "ok", Message: message}, func(w io.Writer) {
+		fmt.Fprintln(w, message)
+	})
+}
+
+// readPassword reads a password from the given file, or from stdin if the
+// path is "-". A trailing newline is ignored.
+func readPassword(cmd *cobra.Command, path string) core.Password {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	cobra.CheckErr(err)
+
+	pw := strings.TrimRight(string(data), "\r\n")
+	if pw == "" {
+		cobra.CheckErr(errEmptyPassword)
+	}
+	return pw
+}
+
+func hashPassword(pw core.Password) core.Password {
+	hasher, err := password.NewHasher(resolvePasswordConfig())
+	cobra.CheckErr(err)
+	hashed, err := hasher.Hash(pw)
+	cobra.CheckErr(err)
+	return hashed
+}
+
+// adminCmd represents the admin command
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Manages users, sessions and files without starting the server",
+	Long: `This command opens the configured storage directly to manage
+users, sessions and files while the server is not running.`,
+}
+
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap",
+	Short: "Creates the first admin user",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+		passwordFile, _ := cmd.Flags().GetString("password-file")
+		pw := readPassword(cmd, passwordFile)
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		count, err := stores.users.Count()
+		cobra.CheckErr(err)
+		if count != 0 {
+			cobra.CheckErr(errAlreadyBootstrapped)
+		}
+
+		if userName == "" {
+			userName = core.DefaultUserName
+		}
+		err = stores.users.Add(&core.User{
+			UserID:   uuid.NewString(),
+			UserName: userName,
+			Password: hashPassword(pw),
+			IsAdmin:  true,
+		})
+		cobra.CheckErr(err)
+
+		printStatus(cmd, fmt.Sprintf("Bootstrapped with admin user '%s'", userName))
+	},
+}
+
+var resetPasswordCmd = &cobra.Command{
+	Use:   "reset-password",
+	Short: "Sets a new password for a user",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+		passwordFile, _ := cmd.Flags().GetString("password-file")
+		pw := readPassword(cmd, passwordFile)
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		user := stores.userForName(userName)
+		err := stores.users.SetPassword(user.UserID, hashPassword(pw))
+		cobra.CheckErr(err)
+
+		printStatus(cmd, fmt.Sprintf("Password reset for user '%s'", user.UserName))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adminCmd)
+	adminCmd.AddCommand(bootstrapCmd)
+	adminCmd.AddCommand(resetPasswordCmd)
+
+	adminCmd.PersistentFlags().Bool("json", false, "Prints output as JSON")
+	err := viper.BindPFlag("json", adminCmd.PersistentFlags().Lookup("json"))
+	cobra.CheckErr(err)
+
+	for _, c := range []*cobra.Command{bootstrapCmd, resetPasswordCmd} {
+		c.Flags().StringP("user", "u", core.DefaultUserName, "User name")
+		c.Flags().String("password-file", "", "File to read the password from, '-' reads from stdin")
+		err = c.MarkFlagRequired("password-file")
+		cobra.CheckErr(err)
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/spf13/cobra"
+)
+
+type apiKeyOutput struct {
+	APIKeyID   core.APIKeyID `json:"id"`
+	UserName   string        `json:"userName"`
+	Name       string        `json:"name"`
+	Scopes     []core.Scope  `json:"scopes"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt"`
+	ExpiresAt  *time.Time    `json:"expiresAt"`
+	// Key is only set right after creation.
+	Key string `json:"key,omitempty"`
+}
+
+func newAPIKeyOutput(key *core.APIKey, userName string) *apiKeyOutput {
+	o := &apiKeyOutput{
+		APIKeyID:  key.APIKeyID,
+		UserName:  userName,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.LastUsedAt.IsZero() {
+		lastUsedAt := key.LastUsedAt
+		o.LastUsedAt = &lastUsedAt
+	}
+	if !key.ExpiresAt.IsZero() {
+		expiresAt := key.ExpiresAt
+		o.ExpiresAt = &expiresAt
+	}
+	return o
+}
+
+var apiKeysCmd = &cobra.Command{
+	Use:   "api-keys",
+	Short: "Lists, creates and revokes API keys",
+}
+
+var apiKeysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists API keys of all users, or of one user with --user",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		var keys []*core.APIKey
+		var err error
+		if userName != "" {
+			keys, err = stores.apiKeys.ForUser(stores.userForName(userName).UserID)
+		} else {
+			keys, err = stores.apiKeys.All()
+		}
+		cobra.CheckErr(err)
+
+		names := stores.userNames()
+		output := make([]*apiKeyOutput, 0, len(keys))
+		for _, k := range keys {
+			output = append(output, newAPIKeyOutput(k, names[k.UserID]))
+		}
+
+		printOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tUSER\tNAME\tSCOPES\tCREATED\tEXPIRES")
+			for _, o := range output {
+				expires := "never"
+				if o.ExpiresAt != nil {
+					expires = o.ExpiresAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					o.APIKeyID,
+					o.UserName,
+					o.Name,
+					strings.Join(o.Scopes, ","),
+					o.CreatedAt.Format(time.RFC3339),
+					expires,
+				)
+			}
+		})
+	},
+}
+
+var apiKeysCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Creates an API key and prints it once",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+		scopes, _ := cmd.Flags().GetStringSlice("scope")
+		ttl, _ := cmd.Flags().GetDuration("expires-in")
+
+		for _, scope := range scopes {
+			if !core.ValidScope(scope) {
+				cobra.CheckErr(fmt.Errorf("invalid scope '%s'", scope))
+			}
+		}
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		user := stores.userForName(userName)
+		secret, err := core.GenerateAPIKey()
+		cobra.CheckErr(err)
+
+		key := &core.APIKey{
+			APIKeyID:  uuid.NewString(),
+			UserID:    user.UserID,
+			Name:      args[0],
+			KeyHash:   core.HashAPIKey(secret),
+			Scopes:    scopes,
+			CreatedAt: time.Now(),
+		}
+		if ttl > 0 {
+			key.ExpiresAt = key.CreatedAt.Add(ttl)
+		}
+		cobra.CheckErr(stores.apiKeys.Add(key))
+
+		output := newAPIKeyOutput(key, user.UserName)
+		output.Key = secret
+		printOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintf(w, "Created API key '%s' (%s) for user '%s'\n", key.Name, key.APIKeyID, user.UserName)
+			fmt.Fprintln(w, secret)
+		})
+	},
+}
+
+var apiKeysRevokeCmd = &cobra.Command{
+	Use:   "revoke <api-key-id>...",
+	Short: "Revokes API keys by id",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		for _, id := range args {
+			err := stores.apiKeys.Delete(id)
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("api key '%s' not revoked: %w", id, err))
+			}
+		}
+
+		printStatus(cmd, fmt.Sprintf("Revoked %d API key(s)", len(args)))
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(apiKeysCmd)
+	apiKeysCmd.AddCommand(apiKeysListCmd)
+	apiKeysCmd.AddCommand(apiKeysCreateCmd)
+	apiKeysCmd.AddCommand(apiKeysRevokeCmd)
+
+	apiKeysListCmd.Flags().StringP("user", "u", "", "Only lists API keys of this user")
+	apiKeysCreateCmd.Flags().StringP("user", "u", core.DefaultUserName, "User the key acts as")
+	apiKeysCreateCmd.Flags().StringSlice("scope", nil, "Scopes of the key: files:read, files:write, sync or admin")
+	apiKeysCreateCmd.Flags().Duration("expires-in", 0, "Expires the key after this duration. Defaults to never")
+	err := apiKeysCreateCmd.MarkFlagRequired("scope")
+	cobra.CheckErr(err)
+}
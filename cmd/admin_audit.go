@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/spf13/cobra"
+)
+
+type auditEntryOutput struct {
+	ID        core.AuditEntryID `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Event     core.AuditEvent   `json:"event"`
+	Outcome   core.AuditOutcome `json:"outcome"`
+	UserName  string            `json:"userName"`
+	ClientIP  string            `json:"clientIp"`
+	SessionID core.SessionID    `json:"sessionId"`
+	FileID    core.FileID       `json:"fileId"`
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Lists audit log entries, newest first",
+	Long: `This command lists audit log entries, newest first. Pass the id
+of the last entry shown as --before to page further back.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		limit = core.AuditPageSize(limit)
+		before, _ := cmd.Flags().GetInt64("before")
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		entries, err := stores.audit.Page(before, limit)
+		cobra.CheckErr(err)
+
+		names := stores.userNames()
+		output := make([]*auditEntryOutput, 0, len(entries))
+		for _, e := range entries {
+			output = append(output, &auditEntryOutput{
+				ID:        e.AuditEntryID,
+				Timestamp: e.Timestamp,
+				Event:     e.Event,
+				Outcome:   e.Outcome,
+				UserName:  names[e.UserID],
+				ClientIP:  e.ClientIP,
+				SessionID: e.SessionID,
+				FileID:    e.FileID,
+			})
+		}
+
+		printOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tTIME\tEVENT\tOUTCOME\tUSER\tIP\tSESSION\tFILE")
+			for _, o := range output {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					o.ID,
+					o.Timestamp.Format(time.RFC3339),
+					o.Event,
+					o.Outcome,
+					o.UserName,
+					o.ClientIP,
+					o.SessionID,
+					o.FileID,
+				)
+			}
+		})
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().Int("limit", 50, fmt.Sprintf("Maximum number of entries to list, at most %d", core.MaxAuditPageSize))
+	auditCmd.Flags().Int64("before", 0, "Only lists entries older than the one with this id")
+}
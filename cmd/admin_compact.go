@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type compactionOutput struct {
+	FileID   core.FileID `json:"fileId"`
+	GroupID  string      `json:"groupId"`
+	Messages int         `json:"messages"`
+	Bytes    int64       `json:"bytes"`
+	Horizon  string      `json:"horizon"`
+}
+
+var filesCompactCmd = &cobra.Command{
+	Use:   "compact [file-id...]",
+	Short: "Removes synced messages that are part of a newer uploaded snapshot",
+	Long: `This command removes the synced messages of all files, or of the given
+ones, that are older than the retention at the time the latest snapshot of
+the file was uploaded. Clients syncing from before the removed messages
+download the file again. The file locks of a running server are not shared
+with this command, so run it while the server is stopped and leave a running
+server to its own compaction.interval.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		retention, _ := cmd.Flags().GetDuration("retention")
+		if retention == 0 {
+			retention = viper.GetDuration("compaction.retention")
+		}
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		var files []*core.File
+		if len(args) == 0 {
+			all, err := stores.files.All()
+			cobra.CheckErr(err)
+			files = all
+		}
+		for _, fileID := range args {
+			file, err := stores.files.ForID(fileID)
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("file '%s' not found: %w", fileID, err))
+			}
+			files = append(files, file)
+		}
+
+		groups := storage.NewGroupManager(
+			core.StorageType(viper.GetString("storage")),
+			resolveStorageConfig(resolveDataPath()),
+			1,
+			0,
+		)
+		defer groups.Close()
+
+		compactions, err := storage.CompactGroups(groups, nil, files, retention)
+		cobra.CheckErr(err)
+
+		output := make([]*compactionOutput, 0, len(compactions))
+		for _, c := range compactions {
+			output = append(output, &compactionOutput{
+				FileID:   c.FileID,
+				GroupID:  c.GroupID,
+				Messages: c.Messages,
+				Bytes:    c.Bytes,
+				Horizon:  c.Horizon,
+			})
+		}
+
+		printOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tGROUP\tMESSAGES\tBYTES\tHORIZON")
+			for _, o := range output {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", o.FileID, o.GroupID, o.Messages, o.Bytes, o.Horizon)
+			}
+		})
+	},
+}
+
+func init() {
+	filesCmd.AddCommand(filesCompactCmd)
+
+	filesCompactCmd.Flags().Duration("retention", 0, "Overrides compaction.retention")
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/spf13/cobra"
+)
+
+type fileOutput struct {
+	FileID      core.FileID `json:"fileId"`
+	Name        string      `json:"name"`
+	Owner       string      `json:"owner"`
+	GroupID     string      `json:"groupId"`
+	SyncVersion int16       `json:"syncVersion"`
+	Encrypted   bool        `json:"encrypted"`
+	Deleted     bool        `json:"deleted"`
+}
+
+var filesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "Lists, renames, deletes, restores and compacts budget files",
+}
+
+var filesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists files of all users, or of one user with --user",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		var files []*core.File
+		var err error
+		if userName != "" {
+			files, err = stores.files.ForOwner(stores.userForName(userName).UserID)
+		} else {
+			files, err = stores.files.All()
+		}
+		cobra.CheckErr(err)
+
+		names := stores.userNames()
+		output := make([]*fileOutput, 0, len(files))
+		for _, f := range files {
+			output = append(output, &fileOutput{
+				FileID:      f.FileID,
+				Name:        f.Name,
+				Owner:       names[f.Owner],
+				GroupID:     f.GroupID,
+				SyncVersion: f.SyncVersion,
+				Encrypted:   f.EncryptKeyID != "",
+				Deleted:     f.Deleted,
+			})
+		}
+
+		printOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tNAME\tOWNER\tGROUP\tENCRYPTED\tDELETED")
+			for _, o := range output {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%t\n", o.FileID, o.Name, o.Owner, o.GroupID, o.Encrypted, o.Deleted)
+			}
+		})
+	},
+}
+
+var filesRenameCmd = &cobra.Command{
+	Use:   "rename <file-id> <name>",
+	Short: "Renames a file",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		err := stores.files.UpdateName(args[0], args[1])
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("file '%s' not renamed: %w", args[0], err))
+		}
+
+		printStatus(cmd, fmt.Sprintf("Renamed file '%s' to '%s'", args[0], args[1]))
+	},
+}
+
+var filesDeleteCmd = &cobra.Command{
+	Use:   "delete <file-id>",
+	Short: "Marks a file as deleted",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		err := stores.files.Delete(args[0])
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("file '%s' not deleted: %w", args[0], err))
+		}
+
+		printStatus(cmd, fmt.Sprintf("Deleted file '%s'", args[0]))
+	},
+}
+
+var filesUndeleteCmd = &cobra.Command{
+	Use:   "undelete <file-id>",
+	Short: "Restores a file marked as deleted",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		err := stores.files.Undelete(args[0])
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("file '%s' not restored: %w", args[0], err))
+		}
+
+		printStatus(cmd, fmt.Sprintf("Restored file '%s'", args[0]))
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(filesCmd)
+	filesCmd.AddCommand(filesListCmd)
+	filesCmd.AddCommand(filesRenameCmd)
+	filesCmd.AddCommand(filesDeleteCmd)
+	filesCmd.AddCommand(filesUndeleteCmd)
+
+	filesListCmd.Flags().StringP("user", "u", "", "Only lists files owned by this user")
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/spf13/cobra"
+)
+
+type sessionOutput struct {
+	SessionID  core.SessionID `json:"id"`
+	UserName   string         `json:"userName"`
+	Device     string         `json:"device"`
+	UserAgent  string         `json:"userAgent"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	LastUsedAt time.Time      `json:"lastUsedAt"`
+	ExpiresAt  *time.Time     `json:"expiresAt"`
+}
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Lists and revokes login sessions",
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists sessions of all users, or of one user with --user",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		var users []*core.User
+		if userName != "" {
+			users = []*core.User{stores.userForName(userName)}
+		} else {
+			all, err := stores.users.All()
+			cobra.CheckErr(err)
+			users = all
+		}
+
+		output := []*sessionOutput{}
+		for _, user := range users {
+			sessions, err := stores.tokens.ForUser(user.UserID)
+			cobra.CheckErr(err)
+
+			for _, s := range sessions {
+				o := &sessionOutput{
+					SessionID:  s.SessionID,
+					UserName:   user.UserName,
+					Device:     s.Device,
+					UserAgent:  s.UserAgent,
+					CreatedAt:  s.CreatedAt,
+					LastUsedAt: s.LastUsedAt,
+				}
+				if !s.ExpiresAt.IsZero() {
+					expiresAt := s.ExpiresAt
+					o.ExpiresAt = &expiresAt
+				}
+				output = append(output, o)
+			}
+		}
+
+		printOutput(cmd, output, func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tUSER\tDEVICE\tCREATED\tLAST USED\tEXPIRES")
+			for _, o := range output {
+				expires := "never"
+				if o.ExpiresAt != nil {
+					expires = o.ExpiresAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					o.SessionID,
+					o.UserName,
+					o.Device,
+					o.CreatedAt.Format(time.RFC3339),
+					o.LastUsedAt.Format(time.RFC3339),
+					expires,
+				)
+			}
+		})
+	},
+}
+
+var sessionsRevokeCmd = &cobra.Command{
+	Use:   "revoke <session-id>...",
+	Short: "Revokes sessions by id",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		for _, id := range args {
+			err := stores.tokens.Delete(id)
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("session '%s' not revoked: %w", id, err))
+			}
+		}
+
+		printStatus(cmd, fmt.Sprintf("Revoked %d session(s)", len(args)))
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsRevokeCmd)
+
+	sessionsListCmd.Flags().StringP("user", "u", "", "Only lists sessions of this user")
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var twoFactorCmd = &cobra.Command{
+	Use:   "two-factor",
+	Short: "Manages two-factor authentication of users",
+}
+
+var twoFactorDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disables two-factor authentication for a user who lost their codes",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, _ := cmd.Flags().GetString("user")
+
+		stores := openAccountStores()
+		defer stores.conn.Close()
+
+		user := stores.userForName(userName)
+		err := stores.twoFactors.Delete(user.UserID)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("two-factor authentication of user '%s' not disabled: %w", user.UserName, err))
+		}
+
+		printStatus(cmd, fmt.Sprintf("Disabled two-factor authentication for user '%s'", user.UserName))
+	},
+}
+
+func init() {
+	adminCmd.AddCommand(twoFactorCmd)
+	twoFactorCmd.AddCommand(twoFactorDisableCmd)
+
+	twoFactorDisableCmd.Flags().StringP("user", "u", "", "User name")
+	err := twoFactorDisableCmd.MarkFlagRequired("user")
+	cobra.CheckErr(err)
+}
@@ -6,6 +6,10 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -48,6 +52,47 @@ func init() {
 	cobra.CheckErr(err)
 	desc := fmt.Sprintf("config file (default  '%s/actual-sync/config.yaml')", home)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", desc)
+	rootCmd.PersistentFlags().String("storage", "sqlite", "Sets storage type for actual-sync")
+	rootCmd.PersistentFlags().StringP("data-path", "d", home, `Sets configuration & data directory path. 
+Creates 'actual-sync' folder here, if it 
+doesn't exist`)
+
+	defaults := password.DefaultConfig()
+	viper.SetDefault("password-hash.algorithm", defaults.Algorithm)
+	viper.SetDefault("password-hash.memory", defaults.Argon2.Memory)
+	viper.SetDefault("password-hash.iterations", defaults.Argon2.Iterations)
+	viper.SetDefault("password-hash.parallelism", defaults.Argon2.Parallelism)
+	viper.SetDefault("password-hash.bcrypt-cost", defaults.BcryptCost)
+
+	err = viper.BindPFlag("storage", rootCmd.PersistentFlags().Lookup("storage"))
+	cobra.CheckErr(err)
+	err = viper.BindPFlag("data-path", rootCmd.PersistentFlags().Lookup("data-path"))
+	cobra.CheckErr(err)
+}
+
+// resolveDataPath returns the absolute 'actual-sync' folder inside the
+// configured data path.
+func resolveDataPath() string {
+	dataPath := filepath.Join(viper.GetString("data-path"), "actual-sync")
+
+	if !filepath.IsAbs(dataPath) {
+		path, err := filepath.Abs(dataPath)
+		cobra.CheckErr(err)
+		dataPath = path
+	}
+	return dataPath
+}
+
+// resolveStorageConfig builds the storage configuration for the configured
+// storage type, creating its directories if needed.
+func resolveStorageConfig(dataPath string) core.StorageConfig {
+	options := storage.Options{
+		DataPath:       dataPath,
+		ServerDataPath: viper.GetString("sqlite.server-files"),
+		UserDataPath:   viper.GetString("sqlite.user-files"),
+	}
+
+	return storage.GenerateStorageConfig(viper.GetString("storage"), options)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -79,3 +124,20 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// resolvePasswordConfig builds the password hashing configuration.
+func resolvePasswordConfig() password.Config {
+	config := password.DefaultConfig()
+	config.Algorithm = viper.GetString("password-hash.algorithm")
+	config.Argon2.Memory = viper.GetUint32("password-hash.memory")
+	config.Argon2.Iterations = viper.GetUint32("password-hash.iterations")
+	// Checked before narrowing, as 256 would wrap around to 0.
+	parallelism := viper.GetUint("password-hash.parallelism")
+	if parallelism > password.MaxParallelism {
+		cobra.CheckErr(fmt.Errorf("%w: parallelism must be between 1 and %d",
+			internal_errors.ErrPasswordInvalidConfig, password.MaxParallelism))
+	}
+	config.Argon2.Parallelism = uint8(parallelism)
+	config.BcryptCost = viper.GetInt("password-hash.bcrypt-cost")
+	return config
+}
@@ -2,11 +2,20 @@ package cmd
 
 import (
 	"embed"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/nathanjisaac/actual-server-go/internal"
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	"github.com/nathanjisaac/actual-server-go/internal/core/proxyauth"
+	"github.com/nathanjisaac/actual-server-go/internal/core/throttle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/upload"
 	"github.com/nathanjisaac/actual-server-go/internal/storage"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
@@ -26,16 +35,7 @@ specified configurations along with this command.`,
 		logs := viper.GetBool("logs")
 		debug := viper.GetBool("debug")
 		port := viper.GetInt("port")
-		storageType := viper.GetString("storage")
-		dataPath := viper.GetString("data-path")
-
-		dataPath = filepath.Join(dataPath, "actual-sync")
-
-		if !filepath.IsAbs(dataPath) {
-			path, err := filepath.Abs(dataPath)
-			cobra.CheckErr(err)
-			dataPath = path
-		}
+		dataPath := resolveDataPath()
 		userFiles := filepath.Join(dataPath, "user-files")
 
 		fs := afero.NewOsFs()
@@ -48,22 +48,63 @@ specified configurations along with this command.`,
 			mode = core.Development
 		}
 
-		options := storage.Options{
-			DataPath:       dataPath,
-			ServerDataPath: viper.GetString("sqlite.server-files"),
-			UserDataPath:   viper.GetString("sqlite.user-files"),
+		storageConfig := resolveStorageConfig(dataPath)
+
+		openIDConfig := openid.Config{
+			Issuer:          viper.GetString("openid.issuer"),
+			ClientID:        viper.GetString("openid.client-id"),
+			ClientSecret:    viper.GetString("openid.client-secret"),
+			RedirectURL:     viper.GetString("openid.redirect-url"),
+			AllowedSubjects: viper.GetStringSlice("openid.allowed-subjects"),
+			AllowedEmails:   viper.GetStringSlice("openid.allowed-emails"),
+		}
+		cobra.CheckErr(openIDConfig.Validate())
+
+		trustedProxies, err := parseCIDRs(viper.GetStringSlice("trusted-proxies"))
+		cobra.CheckErr(err)
+
+		proxyAuthProxies, err := parseCIDRs(viper.GetStringSlice("proxy-auth.trusted-proxies"))
+		cobra.CheckErr(err)
+		proxyAuthConfig := proxyauth.Config{
+			Enabled:        viper.GetBool("proxy-auth.enabled"),
+			Header:         viper.GetString("proxy-auth.header"),
+			TrustedProxies: proxyAuthProxies,
 		}
+		cobra.CheckErr(proxyAuthConfig.Validate())
 
-		storageConfig := storage.GenerateStorageConfig(storageType, options)
+		loginThrottle := throttle.DefaultConfig()
+		loginThrottle.PerIP.FreeAttempts = viper.GetInt("login-throttle.free-attempts")
+		loginThrottle.PerIP.BaseDelay = viper.GetDuration("login-throttle.base-delay")
+		loginThrottle.PerIP.MaxDelay = viper.GetDuration("login-throttle.max-delay")
+		loginThrottle.PerIP.LockoutAttempts = viper.GetInt("login-throttle.lockout-attempts")
+		loginThrottle.PerIP.LockoutDuration = viper.GetDuration("login-throttle.lockout-duration")
+		loginThrottle.PerIP.Window = viper.GetDuration("login-throttle.window")
+		loginThrottle.Global.FreeAttempts = viper.GetInt("login-throttle.global-free-attempts")
 
 		config := core.Config{
-			Mode:          mode,
-			Port:          port,
-			Hostname:      "0.0.0.0",
-			Storage:       core.Sqlite,
-			StorageConfig: storageConfig,
-			UserFiles:     userFiles,
-			FileSystem:    fs,
+			Mode:                mode,
+			Port:                port,
+			Hostname:            "0.0.0.0",
+			Storage:             core.Sqlite,
+			StorageConfig:       storageConfig,
+			UserFiles:           userFiles,
+			FileSystem:          fs,
+			SessionTTL:          viper.GetDuration("sessions.ttl"),
+			OpenID:              openIDConfig,
+			TrustedProxies:      trustedProxies,
+			LoginThrottle:       loginThrottle,
+			PasswordHash:        resolvePasswordConfig(),
+			PasswordPolicy:      resolvePasswordPolicy(),
+			ProxyAuth:           proxyAuthConfig,
+			AuditRetention:      viper.GetDuration("audit.retention"),
+			MaxClockDrift:       viper.GetDuration("sync.max-clock-drift"),
+			GroupPoolSize:       viper.GetInt("sync.max-open-files"),
+			GroupIdleTimeout:    viper.GetDuration("sync.idle-timeout"),
+			CompactionRetention: viper.GetDuration("compaction.retention"),
+			CompactionInterval:  viper.GetDuration("compaction.interval"),
+			UploadTimeout:       viper.GetDuration("sync.upload-timeout"),
+			UploadMaxSize:       viper.GetInt64("sync.upload-max-size"),
+			UploadMaxSessions:   viper.GetInt("sync.upload-max-sessions"),
 		}
 
 		internal.StartServer(config, BuildDirectory, headless, logs)
@@ -71,21 +112,34 @@ specified configurations along with this command.`,
 }
 
 func init() {
-	home, err := os.UserHomeDir()
-	cobra.CheckErr(err)
-
 	rootCmd.AddCommand(serveCmd)
 
 	serveCmd.Flags().Bool("headless", false, "Runs actual-sync without the web app")
 	serveCmd.Flags().Bool("debug", false, "Runs actual-sync in development mode")
 	serveCmd.Flags().IntP("port", "p", 5006, "Runs actual-sync at specified port")
 	serveCmd.Flags().BoolP("logs", "l", false, "Displays server logs")
-	serveCmd.Flags().String("storage", "sqlite", "Sets storage type for actual-sync")
-	serveCmd.Flags().StringP("data-path", "d", home, `Sets configuration & data directory path. 
-Creates 'actual-sync' folder here, if it 
-doesn't exist`)
 
-	err = viper.BindPFlag("headless", serveCmd.Flags().Lookup("headless"))
+	defaults := throttle.DefaultConfig()
+	viper.SetDefault("login-throttle.free-attempts", defaults.PerIP.FreeAttempts)
+	viper.SetDefault("login-throttle.base-delay", defaults.PerIP.BaseDelay)
+	viper.SetDefault("login-throttle.max-delay", defaults.PerIP.MaxDelay)
+	viper.SetDefault("login-throttle.lockout-attempts", defaults.PerIP.LockoutAttempts)
+	viper.SetDefault("login-throttle.lockout-duration", defaults.PerIP.LockoutDuration)
+	viper.SetDefault("login-throttle.window", defaults.PerIP.Window)
+	viper.SetDefault("login-throttle.global-free-attempts", defaults.Global.FreeAttempts)
+
+	viper.SetDefault("proxy-auth.header", proxyauth.DefaultHeader)
+	viper.SetDefault("password-policy.min-length", password.DefaultPolicy().MinLength)
+	viper.SetDefault("sync.max-clock-drift", timestamp.DefaultMaxDrift)
+	viper.SetDefault("sync.max-open-files", storage.DefaultGroupPoolSize)
+	viper.SetDefault("sync.idle-timeout", storage.DefaultGroupIdleTimeout)
+	viper.SetDefault("sync.upload-timeout", upload.DefaultTimeout)
+	viper.SetDefault("sync.upload-max-size", upload.DefaultMaxSize)
+	viper.SetDefault("sync.upload-max-sessions", upload.DefaultMaxSessions)
+	viper.SetDefault("compaction.retention", storage.DefaultCompactionRetention)
+	viper.SetDefault("compaction.interval", storage.DefaultCompactionInterval)
+
+	err := viper.BindPFlag("headless", serveCmd.Flags().Lookup("headless"))
 	cobra.CheckErr(err)
 	err = viper.BindPFlag("logs", serveCmd.Flags().Lookup("logs"))
 	cobra.CheckErr(err)
@@ -93,8 +147,48 @@ doesn't exist`)
 	cobra.CheckErr(err)
 	err = viper.BindPFlag("port", serveCmd.Flags().Lookup("port"))
 	cobra.CheckErr(err)
-	err = viper.BindPFlag("storage", serveCmd.Flags().Lookup("storage"))
-	cobra.CheckErr(err)
-	err = viper.BindPFlag("data-path", serveCmd.Flags().Lookup("data-path"))
-	cobra.CheckErr(err)
+}
+
+// parseCIDRs parses networks in CIDR notation. Plain IP addresses are taken
+// as single host networks.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %w", value, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// resolvePasswordPolicy builds the password policy. Denied passwords are
+// taken from the config and from an optional file with one per line.
+func resolvePasswordPolicy() password.Policy {
+	policy := password.Policy{
+		MinLength: viper.GetInt("password-policy.min-length"),
+		DenyList:  viper.GetStringSlice("password-policy.deny-list"),
+	}
+
+	if path := viper.GetString("password-policy.deny-list-file"); path != "" {
+		data, err := os.ReadFile(path)
+		cobra.CheckErr(err)
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				policy.DenyList = append(policy.DenyList, line)
+			}
+		}
+	}
+	return policy
 }
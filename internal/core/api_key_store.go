@@ -0,0 +1,87 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+type APIKeyID = string
+
+type Scope = string
+
+const (
+	ScopeFilesRead  Scope = "files:read"
+	ScopeFilesWrite Scope = "files:write"
+	ScopeSync       Scope = "sync"
+	// ScopeAdmin grants every other scope as well.
+	ScopeAdmin Scope = "admin"
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from session
+// tokens.
+const APIKeyPrefix = "actual_"
+
+func ValidScope(scope Scope) bool {
+	switch scope {
+	case ScopeFilesRead, ScopeFilesWrite, ScopeSync, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIKey is a long-lived credential for automation. Only the SHA-256 of the
+// key is stored. A zero ExpiresAt means the key never expires.
+type APIKey struct {
+	APIKeyID   APIKeyID
+	UserID     UserID
+	Name       string
+	KeyHash    string
+	Scopes     []Scope
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (it *APIKey) Expired(now time.Time) bool {
+	return !it.ExpiresAt.IsZero() && !now.Before(it.ExpiresAt)
+}
+
+func (it *APIKey) HasScope(scope Scope) bool {
+	for _, s := range it.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateAPIKey returns a new random API key.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsAPIKey(token Token) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+type APIKeyStore interface {
+	ForHash(hash string) (*APIKey, error)
+	ForUser(userID UserID) ([]*APIKey, error)
+	All() ([]*APIKey, error)
+	Add(key *APIKey) error
+	Touch(id APIKeyID, lastUsedAt time.Time) error
+	Delete(id APIKeyID) error
+}
@@ -0,0 +1,66 @@
+package core
+
+import "time"
+
+type AuditEntryID = int64
+
+type AuditEvent = string
+
+const (
+	AuditLogin          AuditEvent = "login"
+	AuditBootstrap      AuditEvent = "bootstrap"
+	AuditChangePassword AuditEvent = "change-password"
+	AuditResetFile      AuditEvent = "reset-file"
+	AuditDeleteFile     AuditEvent = "delete-file"
+	AuditUploadFile     AuditEvent = "upload-file"
+	AuditCreateKey      AuditEvent = "create-key"
+)
+
+type AuditOutcome = string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEntry records a security relevant event. Entries are only ever added,
+// and removed once they are older than the retention period. UserID,
+// SessionID and FileID are empty when unknown or not applicable.
+type AuditEntry struct {
+	AuditEntryID AuditEntryID
+	Timestamp    time.Time
+	Event        AuditEvent
+	Outcome      AuditOutcome
+	UserID       UserID
+	ClientIP     string
+	SessionID    SessionID
+	FileID       FileID
+}
+
+// Pages of the audit log hold DefaultAuditPageSize entries unless a size is
+// asked for, and never more than MaxAuditPageSize.
+const (
+	DefaultAuditPageSize = 100
+	MaxAuditPageSize     = 1000
+)
+
+// AuditPageSize clamps a requested page size. Non-positive sizes select the
+// default.
+func AuditPageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultAuditPageSize
+	}
+	if limit > MaxAuditPageSize {
+		return MaxAuditPageSize
+	}
+	return limit
+}
+
+type AuditStore interface {
+	Add(entry *AuditEntry) error
+	// Page returns up to limit entries older than the entry with the id
+	// before, newest first. A before of 0 starts with the newest entry.
+	Page(before AuditEntryID, limit int) ([]*AuditEntry, error)
+	// DeleteBefore removes entries older than t and returns their count.
+	DeleteBefore(t time.Time) (int64, error)
+}
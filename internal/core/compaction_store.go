@@ -0,0 +1,22 @@
+package core
+
+import "time"
+
+// CompactionResult reports the messages a compaction removed from a group.
+type CompactionResult struct {
+	Messages int
+	// Bytes is the size of the timestamps and contents of the messages.
+	Bytes int64
+	// Horizon is the timestamp before which messages are compacted. Clients
+	// that sync from before it have to download the file again.
+	Horizon string
+}
+
+type CompactionStore interface {
+	// SetSnapshot records when a snapshot of the file was uploaded. Messages
+	// received until then are part of it.
+	SetSnapshot(at time.Time) error
+	// Horizon returns the timestamp before which messages were compacted, or
+	// an empty string if none were.
+	Horizon() (string, error)
+}
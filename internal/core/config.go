@@ -1,6 +1,15 @@
 package core
 
-import "github.com/spf13/afero"
+import (
+	"net"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	"github.com/nathanjisaac/actual-server-go/internal/core/proxyauth"
+	"github.com/nathanjisaac/actual-server-go/internal/core/throttle"
+	"github.com/spf13/afero"
+)
 
 type Mode int64
 
@@ -17,6 +26,39 @@ type Config struct {
 	StorageConfig StorageConfig
 	UserFiles     string
 	FileSystem    afero.Fs
+	SessionTTL    time.Duration
+	OpenID        openid.Config
+	// TrustedProxies are the networks whose X-Forwarded-For headers are used
+	// to resolve the client IP. Without any, the peer address is used.
+	TrustedProxies []*net.IPNet
+	LoginThrottle  throttle.Config
+	PasswordHash   password.Config
+	PasswordPolicy password.Policy
+	ProxyAuth      proxyauth.Config
+	// AuditRetention is how long audit log entries are kept. Zero keeps
+	// them forever.
+	AuditRetention time.Duration
+	// MaxClockDrift is how far ahead of the server time synced message
+	// timestamps may be. Zero disables the check.
+	MaxClockDrift time.Duration
+	// GroupPoolSize is how many message databases of synced files are kept
+	// open at most.
+	GroupPoolSize int
+	// GroupIdleTimeout is how long an unused message database is kept open.
+	GroupIdleTimeout time.Duration
+	// CompactionRetention is how much older than the latest snapshot of a
+	// file synced messages have to be before compaction removes them.
+	CompactionRetention time.Duration
+	// CompactionInterval is how often messages are compacted. Zero disables
+	// scheduled compactions.
+	CompactionInterval time.Duration
+	// UploadTimeout is how long a chunked upload may go without a chunk
+	// before it is abandoned.
+	UploadTimeout time.Duration
+	// UploadMaxSize is the largest file a chunked upload may declare.
+	UploadMaxSize int64
+	// UploadMaxSessions is how many chunked uploads a user may have at once.
+	UploadMaxSessions int
 }
 
 func (it Config) ModeString() string {
@@ -5,10 +5,16 @@ import (
 	"math"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/nathanjisaac/actual-server-go/internal/core/crdt"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 )
 
+// keyLength is the length of the base 3 key of a minute for any time
+// between 1997 and 2051.
+const keyLength = 16
+
 type Merkle struct {
 	Hash     uint32
 	Children map[string]*Merkle
@@ -34,6 +40,46 @@ func NewMerkleFromMap(merkle map[string]interface{}) *Merkle {
 	return &Merkle{}
 }
 
+// ParseMerkle parses a merkle trie serialized by ToJSONString or by a client.
+// Unlike NewMerkleFromMap it rejects malformed input instead of panicking.
+func ParseMerkle(jsonString string) (*Merkle, error) {
+	var merkleMap map[string]interface{}
+	err := json.Unmarshal([]byte(jsonString), &merkleMap)
+	if err != nil {
+		return nil, internal_errors.ErrMerkleUnableToParse
+	}
+	return parseMerkleMap(merkleMap)
+}
+
+func parseMerkleMap(merkleMap map[string]interface{}) (*Merkle, error) {
+	trie := NewMerkle(0)
+	for key, value := range merkleMap {
+		if key == "hash" {
+			hash, ok := value.(float64)
+			if !ok || hash != math.Trunc(hash) {
+				return nil, internal_errors.ErrMerkleUnableToParse
+			}
+			// Clients serialize hashes as signed 32 bit integers.
+			trie.Hash = uint32(int64(hash))
+			continue
+		}
+
+		if key != "0" && key != "1" && key != "2" {
+			return nil, internal_errors.ErrMerkleUnableToParse
+		}
+		childMap, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, internal_errors.ErrMerkleUnableToParse
+		}
+		child, err := parseMerkleMap(childMap)
+		if err != nil {
+			return nil, err
+		}
+		trie.Children[key] = child
+	}
+	return trie, nil
+}
+
 func (trie *Merkle) toMapInterface() map[string]interface{} {
 	merkleMap := map[string]interface{}{}
 	if trie.Hash != 0 {
@@ -157,3 +203,67 @@ func (trie *Merkle) Prune() crdt.Merkle {
 	}
 	return newTrie
 }
+
+// Diff returns the time in milliseconds of the earliest minute in which the
+// two tries differ, or false if they hold the same messages. Tries are
+// walked from the root, following the first child whose hash differs. If a
+// child exists in only one trie, everything from the current node on is
+// considered divergent.
+func (trie *Merkle) Diff(other *Merkle) (int64, bool) {
+	if trie.Hash == other.Hash {
+		return 0, false
+	}
+
+	node1 := trie
+	node2 := other
+	key := ""
+	for {
+		keys := map[string]bool{}
+		for k := range node1.Children {
+			keys[k] = true
+		}
+		for k := range node2.Children {
+			keys[k] = true
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for k := range keys {
+			sortedKeys = append(sortedKeys, k)
+		}
+		sort.Strings(sortedKeys)
+
+		diffKey := ""
+		for _, k := range sortedKeys {
+			next1 := node1.Children[k]
+			next2 := node2.Children[k]
+			if next1 == nil || next2 == nil {
+				break
+			}
+			if next1.Hash != next2.Hash {
+				diffKey = k
+				break
+			}
+		}
+
+		if diffKey == "" {
+			return keyToMillis(key), true
+		}
+
+		key += diffKey
+		node1 = node1.Children[diffKey]
+		node2 = node2.Children[diffKey]
+	}
+}
+
+// keyToMillis converts a base 3 key of minutes, or a prefix of one, back to
+// milliseconds. Prefixes are padded with zeros to the start of their range.
+func keyToMillis(key string) int64 {
+	fullKey := key
+	if len(fullKey) < keyLength {
+		fullKey += strings.Repeat("0", keyLength-len(fullKey))
+	}
+	minutes, err := strconv.ParseInt(fullKey, 3, 64)
+	if err != nil {
+		return 0
+	}
+	return minutes * 1000 * 60
+}
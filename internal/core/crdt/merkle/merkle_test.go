@@ -309,3 +309,95 @@ func TestMerkle_Prune(t *testing.T) {
 		assert.Equal(t, jsonString, jsonOutput)
 	})
 }
+
+func TestMerkle_Diff(t *testing.T) {
+	insertAll := func(t *testing.T, stamps map[string]uint32) *merkle.Merkle {
+		t.Helper()
+
+		trie := merkle.NewMerkle(0)
+		for str, hash := range stamps {
+			ts, err := parseTimestampStub(str, hash)
+			assert.NoError(t, err)
+			trie.Insert(ts)
+		}
+		return trie
+	}
+
+	t.Run("given equal tries then returns no difference", func(t *testing.T) {
+		stamps := map[string]uint32{
+			"2018-11-13T13:20:40.122Z-0000-0123456789ABCDEF": 1000,
+			"2018-11-14T13:21:40.122Z-0000-0123456789ABCDEF": 1100,
+		}
+		trie1 := insertAll(t, stamps)
+		trie2 := insertAll(t, stamps)
+
+		_, ok := trie1.Diff(trie2)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given diverged tries then returns the earliest divergent time", func(t *testing.T) {
+		trie1 := insertAll(t, map[string]uint32{
+			"2018-11-13T13:20:40.122Z-0000-0123456789ABCDEF": 1000,
+			"2018-11-14T13:21:40.122Z-0000-0123456789ABCDEF": 1100,
+			"2018-11-15T22:19:00.000Z-0000-0123456789ABCDEF": 1200,
+		})
+		trie2 := insertAll(t, map[string]uint32{
+			"2018-11-13T13:20:40.122Z-0000-0123456789ABCDEF": 1000,
+			"2018-11-14T13:21:40.122Z-0000-0123456789ABCDEF": 1100,
+			"2018-11-20T13:19:40.122Z-0000-0123456789ABCDEF": 1300,
+			"2018-11-25T13:19:40.122Z-0000-0123456789ABCDEF": 1400,
+		})
+		assert.NotEqual(t, trie1.Hash, trie2.Hash)
+
+		// The tries first differ below key 12101002, which starts at
+		// 2018-11-11T19:57:00Z, before the first message only one of them has.
+		millis, ok := trie1.Diff(trie2)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, int64(1541966220000), millis)
+
+		millis, ok = trie2.Diff(trie1)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, int64(1541966220000), millis)
+	})
+
+	t.Run("given an empty trie then diverges from the first message", func(t *testing.T) {
+		trie := insertAll(t, map[string]uint32{
+			"2018-11-13T13:20:40.122Z-0000-0123456789ABCDEF": 1000,
+		})
+
+		millis, ok := trie.Diff(merkle.NewMerkle(0))
+		assert.Equal(t, true, ok)
+		assert.Equal(t, int64(0), millis)
+	})
+}
+
+func TestMerkle_ParseMerkle(t *testing.T) {
+	t.Run("given serialized trie then parses it", func(t *testing.T) {
+		trie := merkle.NewMerkle(0)
+		ts, err := timestamp.ParseTimestamp("2018-11-12T13:21:40.122Z-0000-0123456789ABCDEF")
+		assert.NoError(t, err)
+		trie.Insert(ts)
+		jsonString, err := trie.ToJSONString()
+		assert.NoError(t, err)
+
+		parsed, err := merkle.ParseMerkle(jsonString)
+		assert.NoError(t, err)
+		_, ok := trie.Diff(parsed)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, trie.Hash, parsed.Hash)
+	})
+
+	t.Run("given negative hash then reads it as unsigned", func(t *testing.T) {
+		parsed, err := merkle.ParseMerkle(`{"hash":-1,"1":{"hash":-1}}`)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(4294967295), parsed.Hash)
+		assert.Equal(t, uint32(4294967295), parsed.Children["1"].Hash)
+	})
+
+	t.Run("given malformed trie then returns error", func(t *testing.T) {
+		for _, jsonString := range []string{`[]`, `{"hash":"1"}`, `{"3":{"hash":1}}`, `{"1":1}`, `{"hash":1.5}`} {
+			_, err := merkle.ParseMerkle(jsonString)
+			assert.ErrorIs(t, err, internal_errors.ErrMerkleUnableToParse, jsonString)
+		}
+	})
+}
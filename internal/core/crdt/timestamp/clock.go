@@ -1,14 +1,118 @@
 package timestamp
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/nathanjisaac/actual-server-go/internal/core/crdt"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+const (
+	// DefaultMaxDrift is how far timestamps may run ahead of the physical
+	// clock unless configured otherwise.
+	DefaultMaxDrift = 5 * time.Minute
+	// MaxCounter is the largest counter that fits in the four hex digits of
+	// a serialized timestamp.
+	MaxCounter = 0xFFFF
 )
 
+// Clock is a hybrid logical clock. It orders events by physical time, and by
+// a counter for events within the same millisecond.
 type Clock struct {
 	Timestamp MutableTimestamp
 	Merkle    crdt.Merkle
+	// MaxDrift is how far the clock may run ahead of the physical clock.
+	MaxDrift time.Duration
+
+	mu sync.Mutex
 }
 
 func NewClock(timestamp MutableTimestamp, merkle crdt.Merkle) *Clock {
-	return &Clock{Timestamp: timestamp, Merkle: merkle}
+	return &Clock{Timestamp: timestamp, Merkle: merkle, MaxDrift: DefaultMaxDrift}
+}
+
+// Send advances the clock for a local event at physical time now and returns
+// the timestamp of the event.
+func (c *Clock) Send(now time.Time) (*Timestamp, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	phys := now.UnixMilli()
+	lOld := c.Timestamp.GetMillis()
+	cOld := c.Timestamp.GetCounter()
+
+	lNew := max64(lOld, phys)
+	cNew := int64(0)
+	if lNew == lOld {
+		cNew = cOld + 1
+	}
+
+	return c.set(lNew, cNew, phys)
+}
+
+// Recv advances the clock past a timestamp received from another node at
+// physical time now and returns the new clock time. Timestamps further ahead
+// of now than MaxDrift are rejected and leave the clock untouched.
+func (c *Clock) Recv(msg crdt.Timestamp, now time.Time) (*Timestamp, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	phys := now.UnixMilli()
+	lMsg := msg.GetMillis()
+	cMsg := msg.GetCounter()
+
+	if err := CheckDrift(msg, now, c.MaxDrift); err != nil {
+		return nil, err
+	}
+	if msg.GetNode() == c.Timestamp.GetNode() {
+		return nil, internal_errors.ErrTimestampDuplicateNode
+	}
+
+	lOld := c.Timestamp.GetMillis()
+	cOld := c.Timestamp.GetCounter()
+
+	lNew := max64(max64(lOld, phys), lMsg)
+	var cNew int64
+	switch {
+	case lNew == lOld && lNew == lMsg:
+		cNew = max64(cOld, cMsg) + 1
+	case lNew == lOld:
+		cNew = cOld + 1
+	case lNew == lMsg:
+		cNew = cMsg + 1
+	}
+
+	return c.set(lNew, cNew, phys)
+}
+
+// CheckDrift rejects a timestamp whose physical time is more than maxDrift
+// ahead of now.
+func CheckDrift(msg crdt.Timestamp, now time.Time, maxDrift time.Duration) error {
+	ahead := msg.GetMillis() - now.UnixMilli()
+	if ahead > maxDrift.Milliseconds() {
+		return fmt.Errorf("%w: %d ms ahead", internal_errors.ErrTimestampClockDrift, ahead)
+	}
+	return nil
+}
+
+func (c *Clock) set(millis int64, counter int64, phys int64) (*Timestamp, error) {
+	if millis-phys > c.MaxDrift.Milliseconds() {
+		return nil, fmt.Errorf("%w: %d ms ahead", internal_errors.ErrTimestampClockDrift, millis-phys)
+	}
+	if counter > MaxCounter {
+		return nil, internal_errors.ErrTimestampCounterOverflow
+	}
+
+	c.Timestamp.SetMillis(millis)
+	c.Timestamp.SetCounter(counter)
+	return NewTimestamp(millis, counter, c.Timestamp.GetNode()), nil
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
 }
@@ -0,0 +1,140 @@
+package timestamp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClock(millis int64, counter int64) *timestamp.Clock {
+	return timestamp.NewClock(*timestamp.NewMutableTimestamp(millis, counter, "0000000000000001"), nil)
+}
+
+func TestClock_Send(t *testing.T) {
+	now := time.UnixMilli(1000000000000)
+
+	t.Run("given clock behind physical time then uses physical time", func(t *testing.T) {
+		clock := newTestClock(0, 5)
+
+		ts, err := clock.Send(now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.UnixMilli(), ts.GetMillis())
+		assert.Equal(t, int64(0), ts.GetCounter())
+		assert.Equal(t, "0000000000000001", ts.GetNode())
+	})
+
+	t.Run("given clock at physical time then increments counter", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli(), 5)
+
+		ts, err := clock.Send(now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.UnixMilli(), ts.GetMillis())
+		assert.Equal(t, int64(6), ts.GetCounter())
+		assert.Equal(t, int64(6), clock.Timestamp.GetCounter())
+	})
+
+	t.Run("given clock too far ahead then returns drift error", func(t *testing.T) {
+		clock := newTestClock(now.Add(time.Hour).UnixMilli(), 0)
+
+		_, err := clock.Send(now)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampClockDrift)
+	})
+
+	t.Run("given counter at maximum then returns overflow error", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli(), timestamp.MaxCounter)
+
+		_, err := clock.Send(now)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampCounterOverflow)
+		assert.Equal(t, int64(timestamp.MaxCounter), clock.Timestamp.GetCounter())
+	})
+}
+
+func TestClock_Recv(t *testing.T) {
+	now := time.UnixMilli(1000000000000)
+
+	t.Run("given message ahead of clock then takes its time", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli(), 7)
+		msg := timestamp.NewTimestamp(now.UnixMilli()+1000, 3, "0000000000000002")
+
+		ts, err := clock.Recv(msg, now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.UnixMilli()+1000, ts.GetMillis())
+		assert.Equal(t, int64(4), ts.GetCounter())
+		assert.Equal(t, "0000000000000001", ts.GetNode())
+	})
+
+	t.Run("given message at clock time then takes the larger counter", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli()+1000, 7)
+		msg := timestamp.NewTimestamp(now.UnixMilli()+1000, 9, "0000000000000002")
+
+		ts, err := clock.Recv(msg, now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), ts.GetCounter())
+	})
+
+	t.Run("given message behind clock then increments counter", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli()+1000, 7)
+		msg := timestamp.NewTimestamp(now.UnixMilli()-1000, 9, "0000000000000002")
+
+		ts, err := clock.Recv(msg, now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.UnixMilli()+1000, ts.GetMillis())
+		assert.Equal(t, int64(8), ts.GetCounter())
+	})
+
+	t.Run("given old clock and message then uses physical time", func(t *testing.T) {
+		clock := newTestClock(0, 7)
+		msg := timestamp.NewTimestamp(1000, 9, "0000000000000002")
+
+		ts, err := clock.Recv(msg, now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.UnixMilli(), ts.GetMillis())
+		assert.Equal(t, int64(0), ts.GetCounter())
+	})
+
+	t.Run("given message too far ahead then returns drift error", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli(), 0)
+		clock.MaxDrift = time.Minute
+		msg := timestamp.NewTimestamp(now.Add(2*time.Minute).UnixMilli(), 0, "0000000000000002")
+
+		_, err := clock.Recv(msg, now)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampClockDrift)
+		assert.Equal(t, now.UnixMilli(), clock.Timestamp.GetMillis())
+	})
+
+	t.Run("given message from the same node then returns duplicate node error", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli(), 0)
+		msg := timestamp.NewTimestamp(now.UnixMilli(), 0, "0000000000000001")
+
+		_, err := clock.Recv(msg, now)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampDuplicateNode)
+	})
+
+	t.Run("given counter at maximum then returns overflow error", func(t *testing.T) {
+		clock := newTestClock(now.UnixMilli(), 0)
+		msg := timestamp.NewTimestamp(now.UnixMilli(), timestamp.MaxCounter, "0000000000000002")
+
+		_, err := clock.Recv(msg, now)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampCounterOverflow)
+	})
+}
+
+func TestCheckDrift(t *testing.T) {
+	now := time.UnixMilli(1000000000000)
+
+	t.Run("given timestamp within drift then accepts it", func(t *testing.T) {
+		msg := timestamp.NewTimestamp(now.Add(time.Minute).UnixMilli(), timestamp.MaxCounter, "0000000000000002")
+
+		assert.NoError(t, timestamp.CheckDrift(msg, now, time.Minute))
+	})
+
+	t.Run("given timestamp too far ahead then returns drift error", func(t *testing.T) {
+		msg := timestamp.NewTimestamp(now.Add(time.Minute).UnixMilli()+1, 0, "0000000000000002")
+
+		err := timestamp.CheckDrift(msg, now, time.Minute)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampClockDrift)
+	})
+}
@@ -0,0 +1,118 @@
+// Package events fans out notifications about changed files to the clients
+// subscribed to them.
+package events
+
+import "sync"
+
+// subscriberBuffer is how many events a subscriber may lag behind before
+// further events are dropped for it. Clients resync on any event, so the
+// latest ones carry no more information than the dropped ones.
+const subscriberBuffer = 8
+
+const (
+	TypeSync   = "sync"
+	TypeReset  = "reset"
+	TypeUpload = "upload"
+	TypeDelete = "delete"
+)
+
+// Event tells subscribers that a file changed.
+type Event struct {
+	Type    string `json:"type"`
+	FileID  string `json:"fileId"`
+	GroupID string `json:"groupId"`
+	// Timestamp is the latest message timestamp of sync events.
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// Subscription receives the events of one file until it is closed.
+type Subscription struct {
+	Events <-chan Event
+
+	hub    *Hub
+	fileID string
+	ch     chan Event
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (it *Subscription) Close() {
+	it.hub.unsubscribe(it)
+}
+
+// Hub keeps the subscriptions of every file. Publishing never blocks, so
+// slow or idle subscribers cannot hold up sync requests.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[string]map[*Subscription]struct{}{}}
+}
+
+// Subscribe returns a subscription to the events of a file.
+func (it *Hub) Subscribe(fileID string) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: ch, hub: it, fileID: fileID, ch: ch}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.subs[fileID] == nil {
+		it.subs[fileID] = map[*Subscription]struct{}{}
+	}
+	it.subs[fileID][sub] = struct{}{}
+	return sub
+}
+
+// Publish sends an event to the subscribers of its file.
+func (it *Hub) Publish(event Event) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	for sub := range it.subs[event.FileID] {
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribers returns the number of subscriptions to a file.
+func (it *Hub) Subscribers(fileID string) int {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return len(it.subs[fileID])
+}
+
+// Close ends all subscriptions, which lets their streams finish.
+func (it *Hub) Close() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	for fileID, subs := range it.subs {
+		for sub := range subs {
+			close(sub.ch)
+		}
+		delete(it.subs, fileID)
+	}
+}
+
+func (it *Hub) unsubscribe(sub *Subscription) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	subs, ok := it.subs[sub.fileID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(it.subs, sub.fileID)
+	}
+	close(sub.ch)
+}
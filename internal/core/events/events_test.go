@@ -0,0 +1,72 @@
+package events_test
+
+import (
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub_Publish(t *testing.T) {
+	t.Run("given subscribers then only those of the file receive the event", func(t *testing.T) {
+		hub := events.NewHub()
+		sub1 := hub.Subscribe("f1")
+		defer sub1.Close()
+		sub2 := hub.Subscribe("f2")
+		defer sub2.Close()
+
+		hub.Publish(events.Event{Type: events.TypeSync, FileID: "f1", GroupID: "g1"})
+
+		assert.Equal(t, events.Event{Type: events.TypeSync, FileID: "f1", GroupID: "g1"}, <-sub1.Events)
+		assert.Equal(t, 0, len(sub2.Events))
+	})
+
+	t.Run("given subscriber that does not read then drops events instead of blocking", func(t *testing.T) {
+		hub := events.NewHub()
+		sub := hub.Subscribe("f1")
+		defer sub.Close()
+
+		for i := 0; i < 100; i++ {
+			hub.Publish(events.Event{Type: events.TypeSync, FileID: "f1"})
+		}
+
+		assert.Equal(t, cap(sub.Events), len(sub.Events))
+	})
+}
+
+func TestSubscription_Close(t *testing.T) {
+	t.Run("given closed subscription then removes it and closes its channel", func(t *testing.T) {
+		hub := events.NewHub()
+		sub := hub.Subscribe("f1")
+		other := hub.Subscribe("f1")
+		defer other.Close()
+		assert.Equal(t, 2, hub.Subscribers("f1"))
+
+		sub.Close()
+		sub.Close()
+
+		assert.Equal(t, 1, hub.Subscribers("f1"))
+		_, ok := <-sub.Events
+		assert.Equal(t, false, ok)
+
+		hub.Publish(events.Event{Type: events.TypeDelete, FileID: "f1"})
+		assert.Equal(t, events.TypeDelete, (<-other.Events).Type)
+	})
+}
+
+func TestHub_Close(t *testing.T) {
+	t.Run("given subscriptions then closes all of them", func(t *testing.T) {
+		hub := events.NewHub()
+		sub1 := hub.Subscribe("f1")
+		sub2 := hub.Subscribe("f2")
+
+		hub.Close()
+		sub1.Close()
+
+		_, ok := <-sub1.Events
+		assert.Equal(t, false, ok)
+		_, ok = <-sub2.Events
+		assert.Equal(t, false, ok)
+		assert.Equal(t, 0, hub.Subscribers("f1"))
+	})
+}
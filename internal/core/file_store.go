@@ -12,6 +12,12 @@ type File struct {
 	EncryptTest  string
 	Deleted      bool
 	Name         string
+	Owner        UserID
+	// ContentHash is the hex encoded SHA-256 of the uploaded file, if known.
+	ContentHash string
+	// ContentSize is the size in bytes of the uploaded file, if ContentHash
+	// is known.
+	ContentSize int64
 }
 
 type NewFile struct {
@@ -20,6 +26,7 @@ type NewFile struct {
 	SyncVersion int16
 	EncryptMeta string
 	Name        string
+	Owner       UserID
 }
 
 type FileStore interface {
@@ -27,11 +34,14 @@ type FileStore interface {
 	ForID(id FileID) (*File, error)
 	ForIDAndDelete(id FileID, deleted bool) (*File, error)
 	All() ([]*File, error)
+	ForOwner(owner UserID) ([]*File, error)
 	Update(fileID string, syncVersion int16, encryptMeta string, name string) error
 	Add(file *NewFile) error
 	ClearGroup(id FileID) error
 	Delete(id FileID) error
+	Undelete(id FileID) error
 	UpdateName(id FileID, name string) error
 	UpdateGroup(id FileID, groupID string) error
 	UpdateEncryption(id FileID, salt, keyID, test string) error
+	UpdateContent(id FileID, contentHash string, contentSize int64) error
 }
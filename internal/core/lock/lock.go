@@ -0,0 +1,53 @@
+// Package lock provides mutexes keyed by a string, such as a file ID.
+package lock
+
+import "sync"
+
+type keyedEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// KeyedMutex is a set of mutexes, one per key. Mutexes only exist while
+// they are held or waited for, so keys do not pile up.
+type KeyedMutex struct {
+	mu      sync.Mutex
+	entries map[string]*keyedEntry
+}
+
+func NewKeyedMutex() *KeyedMutex {
+	return &KeyedMutex{entries: map[string]*keyedEntry{}}
+}
+
+// Lock locks the mutex of a key and returns the function that unlocks it.
+func (it *KeyedMutex) Lock(key string) func() {
+	it.mu.Lock()
+	entry, ok := it.entries[key]
+	if !ok {
+		entry = &keyedEntry{}
+		it.entries[key] = entry
+	}
+	entry.refs++
+	it.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+
+		it.mu.Lock()
+		defer it.mu.Unlock()
+
+		entry.refs--
+		if entry.refs == 0 {
+			delete(it.entries, key)
+		}
+	}
+}
+
+// Len returns the number of keys that are locked or waited for.
+func (it *KeyedMutex) Len() int {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return len(it.entries)
+}
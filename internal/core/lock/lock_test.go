@@ -0,0 +1,57 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package lock_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/lock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex_Lock(t *testing.T) {
+	t.Run("given same key then serializes the holders", func(t *testing.T) {
+		locks := lock.NewKeyedMutex()
+
+		counter := 0
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock := locks.Lock("f1")
+				defer unlock()
+
+				value := counter
+				time.Sleep(time.Microsecond)
+				counter = value + 1
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 50, counter)
+		assert.Equal(t, 0, locks.Len())
+	})
+
+	t.Run("given different keys then does not block", func(t *testing.T) {
+		locks := lock.NewKeyedMutex()
+
+		unlock1 := locks.Lock("f1")
+		defer unlock1()
+
+		done := make(chan struct{})
+		go func() {
+			unlock2 := locks.Lock("f2")
+			unlock2()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("lock of another key blocked")
+		}
+		assert.Equal(t, 1, locks.Len())
+	})
+}
@@ -8,5 +8,8 @@ type BinaryMessage struct {
 
 type MessageStore interface {
 	Add(message BinaryMessage) (bool, error)
-	GetSince(timestamp string) ([]*BinaryMessage, error)
+	// GetSince returns up to limit messages newer than timestamp, oldest
+	// first. A limit of zero returns all of them.
+	GetSince(timestamp string, limit int) ([]*BinaryMessage, error)
+	CountSince(timestamp string) (int, error)
 }
@@ -0,0 +1,205 @@
+package openid
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// clockSkew is the leeway allowed between the issuer's clock and ours.
+const clockSkew = time.Minute
+
+type keySet map[string]*rsa.PublicKey
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience accepts both the single string and the array form of `aud`.
+type audience []string
+
+func (it *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*it = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*it = many
+	return nil
+}
+
+type tokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	AuthorizedBy  string   `json:"azp"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", internal_errors.ErrOpenIDInvalidToken, reason)
+}
+
+func (it *Client) verifyIDToken(ctx context.Context, raw, nonce string) (*Identity, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed token")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+	if header.Alg != "RS256" {
+		return nil, invalidToken(fmt.Sprintf("unsupported algorithm '%s'", header.Alg))
+	}
+
+	key, err := it.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, invalidToken("bad signature")
+	}
+
+	var claims tokenClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+
+	now := it.now()
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(it.config.Issuer, "/"):
+		return nil, invalidToken("wrong issuer")
+	case !claims.Audience.contains(it.config.ClientID):
+		return nil, invalidToken("wrong audience")
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != it.config.ClientID:
+		return nil, invalidToken("wrong authorized party")
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, invalidToken("expired")
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, invalidToken("issued in the future")
+	case claims.Nonce != nonce:
+		return nil, invalidToken("wrong nonce")
+	case claims.Subject == "":
+		return nil, invalidToken("missing subject")
+	}
+
+	return &Identity{
+		Issuer:        strings.TrimSuffix(claims.Issuer, "/"),
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+func (it audience) contains(clientID string) bool {
+	for _, aud := range it {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the signing key with the given id, refreshing the issuer's key
+// set once if the key is unknown, to pick up rotated keys.
+func (it *Client) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	it.mu.Lock()
+	key, ok := it.keys[kid]
+	it.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	keys, err := it.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	it.mu.Lock()
+	it.keys = keys
+	it.mu.Unlock()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, invalidToken(fmt.Sprintf("unknown key '%s'", kid))
+	}
+	return key, nil
+}
+
+func (it *Client) fetchKeys(ctx context.Context) (keySet, error) {
+	doc, err := it.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, doc.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = it.doJSON(req, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(keySet, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,334 @@
+// Package openid implements the OpenID Connect authorization code flow
+// (with PKCE) against a single issuer, including discovery and verification
+// of RS256 signed ID tokens.
+package openid
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// loginTTL is how long a started login may take to come back to the callback.
+const loginTTL = 10 * time.Minute
+
+// twoFactorTTL is how long a login may wait for its second factor.
+const twoFactorTTL = 5 * time.Minute
+
+// RequestTimeout bounds requests to the issuer, so a provider that stops
+// answering does not hold logins open.
+const RequestTimeout = 10 * time.Second
+
+type Config struct {
+	Issuer          string
+	ClientID        string
+	ClientSecret    string
+	RedirectURL     string
+	AllowedSubjects []string
+	AllowedEmails   []string
+}
+
+func (it Config) Enabled() bool {
+	return it.Issuer != ""
+}
+
+// Validate checks that an enabled configuration is complete. At least one
+// allowed subject or email is required, so enabling OpenID never lets every
+// account of the issuer in.
+func (it Config) Validate() error {
+	if !it.Enabled() {
+		return nil
+	}
+	if it.ClientID == "" || it.RedirectURL == "" {
+		return fmt.Errorf("%w: client id and redirect url are required", internal_errors.ErrOpenIDInvalidConfig)
+	}
+	if len(it.AllowedSubjects) == 0 && len(it.AllowedEmails) == 0 {
+		return fmt.Errorf("%w: no allowed subjects or emails", internal_errors.ErrOpenIDInvalidConfig)
+	}
+	return nil
+}
+
+// Identity is the authenticated user as asserted by the issuer.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// ExternalID identifies the user across logins. Subjects are only unique
+// per issuer, so the issuer is part of it.
+func (it *Identity) ExternalID() string {
+	return "openid:" + it.Issuer + "#" + it.Subject
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type pendingLogin struct {
+	nonce     string
+	verifier  string
+	returnURL string
+	expiresAt time.Time
+}
+
+type heldLogin struct {
+	userID    string
+	expiresAt time.Time
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      keySet
+	pending   map[string]*pendingLogin
+	held      map[string]*heldLogin
+}
+
+func NewClient(config Config, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: RequestTimeout}
+	}
+	return &Client{
+		config:     config,
+		httpClient: httpClient,
+		now:        time.Now,
+		pending:    make(map[string]*pendingLogin),
+		held:       make(map[string]*heldLogin),
+	}
+}
+
+// AuthURL starts a login and returns the issuer URL to redirect the browser
+// to. The returnURL is handed back by Exchange once the login completes.
+func (it *Client) AuthURL(ctx context.Context, returnURL string) (string, error) {
+	doc, err := it.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	now := it.now()
+	it.mu.Lock()
+	for key, login := range it.pending {
+		if now.After(login.expiresAt) {
+			delete(it.pending, key)
+		}
+	}
+	it.pending[state] = &pendingLogin{
+		nonce:     nonce,
+		verifier:  verifier,
+		returnURL: returnURL,
+		expiresAt: now.Add(loginTTL),
+	}
+	it.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {it.config.ClientID},
+		"redirect_uri":          {it.config.RedirectURL},
+		"scope":                 {"openid email"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange completes the login started with the given state by redeeming the
+// authorization code and verifying the returned ID token.
+func (it *Client) Exchange(ctx context.Context, state, code string) (*Identity, string, error) {
+	it.mu.Lock()
+	login, ok := it.pending[state]
+	delete(it.pending, state)
+	it.mu.Unlock()
+	if !ok || it.now().After(login.expiresAt) {
+		return nil, "", internal_errors.ErrOpenIDInvalidState
+	}
+
+	doc, err := it.discover(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {it.config.RedirectURL},
+		"code_verifier": {login.verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(it.config.ClientID), url.QueryEscape(it.config.ClientSecret))
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err = it.doJSON(req, &tokens); err != nil {
+		return nil, "", err
+	}
+	if tokens.IDToken == "" {
+		return nil, "", fmt.Errorf("%w: token response has no id token", internal_errors.ErrOpenIDProvider)
+	}
+
+	identity, err := it.verifyIDToken(ctx, tokens.IDToken, login.nonce)
+	if err != nil {
+		return nil, "", err
+	}
+	return identity, login.returnURL, nil
+}
+
+// Allowed reports whether the identity is in the configured allow lists.
+// Emails only count once the issuer has verified them.
+func (it *Client) Allowed(identity *Identity) bool {
+	for _, subject := range it.config.AllowedSubjects {
+		if subject == identity.Subject {
+			return true
+		}
+	}
+	if !identity.EmailVerified || identity.Email == "" {
+		return false
+	}
+	for _, email := range it.config.AllowedEmails {
+		if strings.EqualFold(email, identity.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold parks a completed login of a user who still has to pass a second
+// factor and returns the token to finish it with.
+func (it *Client) Hold(userID string) (string, error) {
+	token, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	now := it.now()
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	for key, login := range it.held {
+		if now.After(login.expiresAt) {
+			delete(it.held, key)
+		}
+	}
+	it.held[token] = &heldLogin{userID: userID, expiresAt: now.Add(twoFactorTTL)}
+	return token, nil
+}
+
+// Held returns the user of a parked login.
+func (it *Client) Held(token string) (string, bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	login, ok := it.held[token]
+	if !ok || it.now().After(login.expiresAt) {
+		return "", false
+	}
+	return login.userID, true
+}
+
+// Release forgets a parked login once it is finished.
+func (it *Client) Release(token string) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	delete(it.held, token)
+}
+
+func (it *Client) discover(ctx context.Context) (*discoveryDocument, error) {
+	it.mu.Lock()
+	doc := it.discovery
+	it.mu.Unlock()
+	if doc != nil {
+		return doc, nil
+	}
+
+	issuer := strings.TrimSuffix(it.config.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	doc = new(discoveryDocument)
+	if err = it.doJSON(req, doc); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%w: discovered issuer '%s' does not match", internal_errors.ErrOpenIDProvider, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", internal_errors.ErrOpenIDProvider)
+	}
+
+	it.mu.Lock()
+	it.discovery = doc
+	it.mu.Unlock()
+	return doc, nil
+}
+
+func (it *Client) doJSON(req *http.Request, v any) error {
+	res, err := it.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", internal_errors.ErrOpenIDProvider, err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%w: %s returned %d: %s", internal_errors.ErrOpenIDProvider, req.URL, res.StatusCode, body)
+	}
+	if err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s", internal_errors.ErrOpenIDProvider, err.Error())
+	}
+	return nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,193 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package openid_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid/openidtest"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(provider *openidtest.Provider) *openid.Client {
+	return openid.NewClient(openid.Config{
+		Issuer:          provider.Issuer(),
+		ClientID:        openidtest.ClientID,
+		ClientSecret:    openidtest.ClientSecret,
+		RedirectURL:     "http://localhost:5006/openid/callback",
+		AllowedSubjects: []string{"subject-1"},
+	}, nil)
+}
+
+func login(t *testing.T, provider *openidtest.Provider, client *openid.Client) (*openid.Identity, string, error) {
+	t.Helper()
+
+	authURL, err := client.AuthURL(context.Background(), "/return")
+	assert.NoError(t, err)
+
+	callback := provider.Authorize(t, authURL)
+	assert.Equal(t, "/openid/callback", callback.Path)
+
+	return client.Exchange(context.Background(), callback.Query().Get("state"), callback.Query().Get("code"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("given disabled config then returns no error", func(t *testing.T) {
+		assert.NoError(t, openid.Config{}.Validate())
+	})
+
+	t.Run("given config without client id then returns error", func(t *testing.T) {
+		err := openid.Config{
+			Issuer:          "http://issuer",
+			RedirectURL:     "http://localhost/openid/callback",
+			AllowedSubjects: []string{"s"},
+		}.Validate()
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidConfig)
+	})
+
+	t.Run("given config without allow lists then returns error", func(t *testing.T) {
+		err := openid.Config{
+			Issuer:      "http://issuer",
+			ClientID:    "id",
+			RedirectURL: "http://localhost/openid/callback",
+		}.Validate()
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidConfig)
+	})
+}
+
+func TestClient_Exchange(t *testing.T) {
+	t.Run("given valid login then returns identity and return url", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		client := newTestClient(provider)
+
+		identity, returnURL, err := login(t, provider, client)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/return", returnURL)
+		assert.Equal(t, &openid.Identity{
+			Issuer:        provider.Issuer(),
+			Subject:       "subject-1",
+			Email:         "user@example.com",
+			EmailVerified: true,
+		}, identity)
+	})
+
+	t.Run("given unknown state then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		client := newTestClient(provider)
+
+		_, _, err := client.Exchange(context.Background(), "unknown", "code")
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidState)
+	})
+
+	t.Run("given state used twice then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		client := newTestClient(provider)
+
+		authURL, err := client.AuthURL(context.Background(), "/")
+		assert.NoError(t, err)
+		callback := provider.Authorize(t, authURL)
+		state, code := callback.Query().Get("state"), callback.Query().Get("code")
+
+		_, _, err = client.Exchange(context.Background(), state, code)
+		assert.NoError(t, err)
+		_, _, err = client.Exchange(context.Background(), state, code)
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidState)
+	})
+
+	t.Run("given wrong nonce then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		provider.Claims["nonce"] = "other"
+		client := newTestClient(provider)
+
+		_, _, err := login(t, provider, client)
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidToken)
+	})
+
+	t.Run("given wrong audience then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		provider.Claims["aud"] = []string{"other"}
+		client := newTestClient(provider)
+
+		_, _, err := login(t, provider, client)
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidToken)
+	})
+
+	t.Run("given expired token then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		provider.Claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		client := newTestClient(provider)
+
+		_, _, err := login(t, provider, client)
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDInvalidToken)
+	})
+
+	t.Run("given unreachable issuer then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		provider.Server.Close()
+		client := newTestClient(provider)
+
+		_, err := client.AuthURL(context.Background(), "/")
+
+		assert.ErrorIs(t, err, internal_errors.ErrOpenIDProvider)
+	})
+}
+
+func TestClient_Allowed(t *testing.T) {
+	client := openid.NewClient(openid.Config{
+		AllowedSubjects: []string{"subject-1"},
+		AllowedEmails:   []string{"User@Example.com"},
+	}, nil)
+
+	t.Run("given allowed subject then returns true", func(t *testing.T) {
+		assert.Equal(t, true, client.Allowed(&openid.Identity{Subject: "subject-1"}))
+	})
+
+	t.Run("given allowed verified email then returns true", func(t *testing.T) {
+		identity := &openid.Identity{Subject: "s2", Email: "user@example.com", EmailVerified: true}
+		assert.Equal(t, true, client.Allowed(identity))
+	})
+
+	t.Run("given allowed unverified email then returns false", func(t *testing.T) {
+		identity := &openid.Identity{Subject: "s2", Email: "user@example.com"}
+		assert.Equal(t, false, client.Allowed(identity))
+	})
+
+	t.Run("given unknown identity then returns false", func(t *testing.T) {
+		identity := &openid.Identity{Subject: "s2", Email: "other@example.com", EmailVerified: true}
+		assert.Equal(t, false, client.Allowed(identity))
+	})
+}
+
+func TestClient_Hold(t *testing.T) {
+	t.Run("given held login then returns its user until released", func(t *testing.T) {
+		client := openid.NewClient(openid.Config{}, nil)
+
+		token, err := client.Hold("u1")
+		assert.NoError(t, err)
+
+		userID, ok := client.Held(token)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, "u1", userID)
+
+		client.Release(token)
+		_, ok = client.Held(token)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given unknown token then returns false", func(t *testing.T) {
+		client := openid.NewClient(openid.Config{}, nil)
+
+		_, ok := client.Held("unknown")
+		assert.Equal(t, false, ok)
+	})
+}
@@ -0,0 +1,219 @@
+// Package openidtest provides a local stand-in OpenID Connect issuer for tests.
+package openidtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	ClientID     = "actual-test"
+	ClientSecret = "secret"
+	KeyID        = "test-key"
+)
+
+type authorization struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// Provider is an issuer serving discovery, authorize, token and JWKS
+// endpoints. Every authorization is granted for the identity configured in
+// Claims.
+type Provider struct {
+	Server *httptest.Server
+	Key    *rsa.PrivateKey
+	// Claims are added to every issued ID token, on top of the standard ones.
+	Claims map[string]any
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+func NewProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provider{
+		Key: key,
+		Claims: map[string]any{
+			"sub":            "subject-1",
+			"email":          "user@example.com",
+			"email_verified": true,
+		},
+		codes: make(map[string]*authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+
+	return p
+}
+
+func (it *Provider) Issuer() string {
+	return it.Server.URL
+}
+
+// Authorize follows the redirect to the issuer for the given authorization
+// URL and returns the callback URL the issuer redirects back to.
+func (it *Provider) Authorize(t *testing.T, authURL string) *url.URL {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	location, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+// IDToken signs the given claims with the provider key.
+func (it *Provider) IDToken(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": KeyID})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, it.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (it *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 it.Issuer(),
+		"authorization_endpoint": it.Issuer() + "/authorize",
+		"token_endpoint":         it.Issuer() + "/token",
+		"jwks_uri":               it.Issuer() + "/jwks",
+	})
+}
+
+func (it *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	it.mu.Lock()
+	it.codes[code] = &authorization{
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+	}
+	it.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect uri", http.StatusBadRequest)
+		return
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (it *Provider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != ClientID || secret != ClientSecret {
+		http.Error(w, "invalid client", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	it.mu.Lock()
+	auth, ok := it.codes[r.PostForm.Get("code")]
+	delete(it.codes, r.PostForm.Get("code"))
+	it.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok ||
+		auth.redirectURI != r.PostForm.Get("redirect_uri") ||
+		auth.challenge != base64.RawURLEncoding.EncodeToString(verifier[:]) {
+		http.Error(w, "invalid grant", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   it.Issuer(),
+		"aud":   ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": auth.nonce,
+	}
+	for k, v := range it.Claims {
+		claims[k] = v
+	}
+
+	writeJSON(w, map[string]string{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"id_token":     it.IDToken(claims),
+	})
+}
+
+func (it *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(it.Key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(it.Key.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,208 @@
+// Package password hashes and verifies user passwords. New hashes are
+// written in PHC string format, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+//
+// while bcrypt hashes keep their own `$2a$` format, so rows written before
+// argon2id was introduced still verify.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// MaxParallelism is the most threads argon2id can use.
+const MaxParallelism = 255
+
+type Argon2Params struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type Config struct {
+	Algorithm  string
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// DefaultConfig uses the argon2id parameters recommended by RFC 9106 for
+// memory constrained environments.
+func DefaultConfig() Config {
+	return Config{
+		Algorithm: Argon2id,
+		Argon2: Argon2Params{
+			Memory:      64 * 1024,
+			Iterations:  3,
+			Parallelism: 4,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		BcryptCost: 12,
+	}
+}
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes of any supported algorithm.
+type Hasher struct {
+	config Config
+}
+
+// NewHasher returns an error for parameters that would fail when hashing, so
+// they are rejected at startup rather than on the first login.
+func NewHasher(config Config) (*Hasher, error) {
+	var err error
+	switch config.Algorithm {
+	case Argon2id:
+		err = config.Argon2.validate()
+	case Bcrypt:
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			err = fmt.Errorf("%w: bcrypt cost must be between %d and %d",
+				internal_errors.ErrPasswordInvalidConfig, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: '%s'", internal_errors.ErrPasswordUnknownScheme, config.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Hasher{config: config}, nil
+}
+
+// validate checks the limits of argon2, which panics below them.
+func (it Argon2Params) validate() error {
+	switch {
+	case it.Iterations < 1:
+		return fmt.Errorf("%w: iterations must be at least 1", internal_errors.ErrPasswordInvalidConfig)
+	case it.Parallelism < 1:
+		return fmt.Errorf("%w: parallelism must be between 1 and %d", internal_errors.ErrPasswordInvalidConfig, MaxParallelism)
+	case it.Memory < 8*uint32(it.Parallelism):
+		return fmt.Errorf("%w: memory must be at least 8 KiB per thread", internal_errors.ErrPasswordInvalidConfig)
+	case it.SaltLength < 1:
+		return fmt.Errorf("%w: salt length must be at least 1", internal_errors.ErrPasswordInvalidConfig)
+	case it.KeyLength < 1:
+		return fmt.Errorf("%w: key length must be at least 1", internal_errors.ErrPasswordInvalidConfig)
+	}
+	return nil
+}
+
+func (it *Hasher) Hash(password string) (string, error) {
+	if it.config.Algorithm == Bcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), it.config.BcryptCost)
+		return string(hashed), err
+	}
+
+	p := it.config.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf(
+		"$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id,
+		argon2.Version,
+		p.Memory,
+		p.Iterations,
+		p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks the password against the hash. If it matches, needsRehash
+// reports whether the hash uses an outdated algorithm or parameters and
+// should be replaced with a fresh Hash of the password. An empty hash never
+// matches.
+func (it *Hasher) Verify(hash string, password string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case hash == "":
+		return false, false, nil
+	case strings.HasPrefix(hash, "$"+Argon2id+"$"):
+		return it.verifyArgon2id(hash, password)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return it.verifyBcrypt(hash, password)
+	default:
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+}
+
+func (it *Hasher) verifyBcrypt(hash string, password string) (bool, bool, error) {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("%w: %s", internal_errors.ErrPasswordUnknownHash, err.Error())
+	}
+
+	if it.config.Algorithm != Bcrypt {
+		return true, true, nil
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true, true, nil
+	}
+	return true, cost != it.config.BcryptCost, nil
+}
+
+func (it *Hasher) verifyArgon2id(hash string, password string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return false, false, internal_errors.ErrPasswordUnknownHash
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, computed) != 1 {
+		return false, false, nil
+	}
+
+	want := it.config.Argon2
+	needsRehash := it.config.Algorithm != Argon2id ||
+		p.Memory != want.Memory ||
+		p.Iterations != want.Iterations ||
+		p.Parallelism != want.Parallelism ||
+		p.KeyLength != want.KeyLength ||
+		p.SaltLength < want.SaltLength
+	return true, needsRehash, nil
+}
@@ -0,0 +1,205 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testConfig() password.Config {
+	return password.Config{
+		Algorithm: password.Argon2id,
+		Argon2: password.Argon2Params{
+			Memory:      1024,
+			Iterations:  1,
+			Parallelism: 1,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+		BcryptCost: bcrypt.MinCost,
+	}
+}
+
+func newTestHasher(t *testing.T, config password.Config) *password.Hasher {
+	t.Helper()
+
+	hasher, err := password.NewHasher(config)
+	assert.NoError(t, err)
+	return hasher
+}
+
+func TestNewHasher(t *testing.T) {
+	t.Run("given unknown algorithm then returns error", func(t *testing.T) {
+		config := testConfig()
+		config.Algorithm = "md5"
+
+		_, err := password.NewHasher(config)
+
+		assert.ErrorIs(t, err, internal_errors.ErrPasswordUnknownScheme)
+	})
+
+	tests := []struct {
+		name   string
+		modify func(config *password.Config)
+	}{
+		{"zero iterations", func(c *password.Config) { c.Argon2.Iterations = 0 }},
+		{"zero parallelism", func(c *password.Config) { c.Argon2.Parallelism = 0 }},
+		{"memory below 8 KiB per thread", func(c *password.Config) { c.Argon2.Parallelism = 4; c.Argon2.Memory = 31 }},
+		{"zero salt length", func(c *password.Config) { c.Argon2.SaltLength = 0 }},
+		{"zero key length", func(c *password.Config) { c.Argon2.KeyLength = 0 }},
+		{"bcrypt cost below minimum", func(c *password.Config) { c.Algorithm = password.Bcrypt; c.BcryptCost = bcrypt.MinCost - 1 }},
+		{"bcrypt cost above maximum", func(c *password.Config) { c.Algorithm = password.Bcrypt; c.BcryptCost = bcrypt.MaxCost + 1 }},
+	}
+	for _, test := range tests {
+		t.Run("given "+test.name+" then returns error", func(t *testing.T) {
+			config := testConfig()
+			test.modify(&config)
+
+			_, err := password.NewHasher(config)
+
+			assert.ErrorIs(t, err, internal_errors.ErrPasswordInvalidConfig)
+		})
+	}
+
+	t.Run("given lowest valid parameters then returns hasher", func(t *testing.T) {
+		config := testConfig()
+		config.Argon2.Memory = 8
+
+		_, err := password.NewHasher(config)
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestHasher_Hash(t *testing.T) {
+	t.Run("given argon2id then returns PHC string", func(t *testing.T) {
+		hasher := newTestHasher(t, testConfig())
+
+		hash, err := hasher.Hash("password123")
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+		assert.Equal(t, 6, len(strings.Split(hash, "$")))
+	})
+
+	t.Run("given same password twice then salts differ", func(t *testing.T) {
+		hasher := newTestHasher(t, testConfig())
+
+		first, err := hasher.Hash("password123")
+		assert.NoError(t, err)
+		second, err := hasher.Hash("password123")
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("given bcrypt then returns bcrypt hash", func(t *testing.T) {
+		config := testConfig()
+		config.Algorithm = password.Bcrypt
+		hasher := newTestHasher(t, config)
+
+		hash, err := hasher.Hash("password123")
+
+		assert.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("password123")))
+	})
+}
+
+func TestHasher_Verify(t *testing.T) {
+	t.Run("given argon2id hash then verifies password", func(t *testing.T) {
+		hasher := newTestHasher(t, testConfig())
+		hash, err := hasher.Hash("password123")
+		assert.NoError(t, err)
+
+		ok, needsRehash, err := hasher.Verify(hash, "password123")
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, false, needsRehash)
+
+		ok, _, err = hasher.Verify(hash, "wrong")
+		assert.NoError(t, err)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given argon2id hash with outdated parameters then needs rehash", func(t *testing.T) {
+		hash, err := newTestHasher(t, testConfig()).Hash("password123")
+		assert.NoError(t, err)
+
+		config := testConfig()
+		config.Argon2.Iterations = 2
+		ok, needsRehash, err := newTestHasher(t, config).Verify(hash, "password123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, true, needsRehash)
+	})
+
+	t.Run("given bcrypt hash and argon2id config then needs rehash", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+
+		ok, needsRehash, err := newTestHasher(t, testConfig()).Verify(string(hash), "password123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, true, needsRehash)
+	})
+
+	t.Run("given bcrypt hash with outdated cost then needs rehash", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+
+		config := testConfig()
+		config.Algorithm = password.Bcrypt
+		config.BcryptCost = bcrypt.MinCost + 1
+		ok, needsRehash, err := newTestHasher(t, config).Verify(string(hash), "password123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, true, needsRehash)
+	})
+
+	t.Run("given wrong password for bcrypt hash then does not match", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+
+		ok, needsRehash, err := newTestHasher(t, testConfig()).Verify(string(hash), "wrong")
+
+		assert.NoError(t, err)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, false, needsRehash)
+	})
+
+	t.Run("given empty hash then does not match", func(t *testing.T) {
+		ok, _, err := newTestHasher(t, testConfig()).Verify("", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given unknown hash format then returns error", func(t *testing.T) {
+		ok, _, err := newTestHasher(t, testConfig()).Verify("plaintext", "plaintext")
+
+		assert.ErrorIs(t, err, internal_errors.ErrPasswordUnknownHash)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given malformed argon2id hash then returns error", func(t *testing.T) {
+		ok, _, err := newTestHasher(t, testConfig()).Verify("$argon2id$v=19$m=1,t=1,p=1$!!$!!", "password123")
+
+		assert.ErrorIs(t, err, internal_errors.ErrPasswordUnknownHash)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given argon2id hash with zero iterations then returns error", func(t *testing.T) {
+		ok, _, err := newTestHasher(t, testConfig()).Verify("$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$a2V5a2V5", "password123")
+
+		assert.ErrorIs(t, err, internal_errors.ErrPasswordUnknownHash)
+		assert.Equal(t, false, ok)
+	})
+}
@@ -0,0 +1,37 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// Policy restricts the passwords users may choose. The zero value allows any
+// password.
+type Policy struct {
+	MinLength int
+	// DenyList holds passwords that are too common to be allowed. They are
+	// compared ignoring case.
+	DenyList []string
+}
+
+// DefaultPolicy requires the minimum length NIST SP 800-63B recommends.
+func DefaultPolicy() Policy {
+	return Policy{MinLength: 8}
+}
+
+// Check returns ErrPasswordTooShort or ErrPasswordDenied if the password
+// breaks the policy.
+func (it Policy) Check(password string) error {
+	if utf8.RuneCountInString(password) < it.MinLength {
+		return fmt.Errorf("%w: at least %d characters are required", internal_errors.ErrPasswordTooShort, it.MinLength)
+	}
+	for _, denied := range it.DenyList {
+		if strings.EqualFold(password, denied) {
+			return internal_errors.ErrPasswordDenied
+		}
+	}
+	return nil
+}
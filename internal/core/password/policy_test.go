@@ -0,0 +1,35 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package password_test
+
+import (
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	policy := password.Policy{MinLength: 8, DenyList: []string{"password123"}}
+
+	t.Run("given zero policy then allows any password", func(t *testing.T) {
+		assert.NoError(t, password.Policy{}.Check("a"))
+	})
+
+	t.Run("given short password then returns error", func(t *testing.T) {
+		assert.ErrorIs(t, policy.Check("short"), internal_errors.ErrPasswordTooShort)
+	})
+
+	t.Run("given multi-byte password then counts characters", func(t *testing.T) {
+		assert.ErrorIs(t, policy.Check("äöüäöü"), internal_errors.ErrPasswordTooShort)
+		assert.NoError(t, policy.Check("äöüäöüäö"))
+	})
+
+	t.Run("given denied password in other case then returns error", func(t *testing.T) {
+		assert.ErrorIs(t, policy.Check("PassWord123"), internal_errors.ErrPasswordDenied)
+	})
+
+	t.Run("given long enough password then returns no error", func(t *testing.T) {
+		assert.NoError(t, policy.Check("correct horse battery staple"))
+	})
+}
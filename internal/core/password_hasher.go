@@ -0,0 +1,9 @@
+package core
+
+// PasswordHasher hashes new passwords and verifies stored hashes. Verify
+// reports through needsRehash that a matching hash is outdated and should be
+// replaced.
+type PasswordHasher interface {
+	Hash(password Password) (string, error)
+	Verify(hash string, password Password) (ok bool, needsRehash bool, err error)
+}
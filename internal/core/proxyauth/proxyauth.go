@@ -0,0 +1,65 @@
+// Package proxyauth trusts the user name an authenticating reverse proxy sets
+// in a request header, for requests that come directly from that proxy.
+package proxyauth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// DefaultHeader is the header Authelia, oauth2-proxy and others set.
+const DefaultHeader = "Remote-User"
+
+type Config struct {
+	Enabled bool
+	Header  string
+	// TrustedProxies are the networks allowed to assert the header. It is
+	// ignored on requests from any other peer.
+	TrustedProxies []*net.IPNet
+}
+
+// Validate checks that an enabled configuration names the proxies to trust,
+// so enabling it never lets any client pick the user it signs in as.
+func (it Config) Validate() error {
+	if !it.Enabled {
+		return nil
+	}
+	if it.Header == "" {
+		return fmt.Errorf("%w: header is required", internal_errors.ErrProxyAuthInvalidConfig)
+	}
+	if len(it.TrustedProxies) == 0 {
+		return fmt.Errorf("%w: no trusted proxies", internal_errors.ErrProxyAuthInvalidConfig)
+	}
+	return nil
+}
+
+// UserName returns the user name asserted by the proxy. The peer address of
+// the connection is checked, never a forwarded one, as clients can set those.
+func (it Config) UserName(r *http.Request) (string, bool) {
+	if !it.Enabled {
+		return "", false
+	}
+	userName := strings.TrimSpace(r.Header.Get(it.Header))
+	if userName == "" {
+		return "", false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false
+	}
+	for _, ipNet := range it.TrustedProxies {
+		if ipNet.Contains(ip) {
+			return userName, true
+		}
+	}
+	return "", false
+}
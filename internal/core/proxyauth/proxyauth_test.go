@@ -0,0 +1,92 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package proxyauth_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/proxyauth"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig(t *testing.T) proxyauth.Config {
+	t.Helper()
+
+	_, ipNet, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+	return proxyauth.Config{
+		Enabled:        true,
+		Header:         proxyauth.DefaultHeader,
+		TrustedProxies: []*net.IPNet{ipNet},
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("given disabled config then returns no error", func(t *testing.T) {
+		assert.NoError(t, proxyauth.Config{}.Validate())
+	})
+
+	t.Run("given enabled config without trusted proxies then returns error", func(t *testing.T) {
+		err := proxyauth.Config{Enabled: true, Header: proxyauth.DefaultHeader}.Validate()
+
+		assert.ErrorIs(t, err, internal_errors.ErrProxyAuthInvalidConfig)
+	})
+
+	t.Run("given enabled config without header then returns error", func(t *testing.T) {
+		config := testConfig(t)
+		config.Header = ""
+
+		assert.ErrorIs(t, config.Validate(), internal_errors.ErrProxyAuthInvalidConfig)
+	})
+
+	t.Run("given complete config then returns no error", func(t *testing.T) {
+		assert.NoError(t, testConfig(t).Validate())
+	})
+}
+
+func TestConfig_UserName(t *testing.T) {
+	t.Run("given header from trusted proxy then returns user name", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:4567"
+		req.Header.Set("Remote-User", "alice")
+
+		userName, ok := testConfig(t).UserName(req)
+
+		assert.Equal(t, true, ok)
+		assert.Equal(t, "alice", userName)
+	})
+
+	t.Run("given header from untrusted peer then returns false", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.2:4567"
+		req.Header.Set("Remote-User", "alice")
+		req.Header.Set("X-Forwarded-For", "10.1.2.3")
+
+		_, ok := testConfig(t).UserName(req)
+
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given no header then returns false", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:4567"
+
+		_, ok := testConfig(t).UserName(req)
+
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given disabled config then returns false", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:4567"
+		req.Header.Set("Remote-User", "alice")
+		config := testConfig(t)
+		config.Enabled = false
+
+		_, ok := config.UserName(req)
+
+		assert.Equal(t, false, ok)
+	})
+}
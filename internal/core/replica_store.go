@@ -0,0 +1,18 @@
+package core
+
+// ReplicaValue is the current value of a single cell of an unencrypted file,
+// written by the message with the newest timestamp for that cell.
+type ReplicaValue struct {
+	Dataset   string
+	Row       string
+	Column    string
+	Value     string
+	Timestamp string
+}
+
+// ReplicaStore reads the server-side replica of an unencrypted file, which
+// sync-full keeps up to date.
+type ReplicaStore interface {
+	Get(dataset, row, column string) (*ReplicaValue, error)
+	ForDataset(dataset string) ([]*ReplicaValue, error)
+}
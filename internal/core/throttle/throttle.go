@@ -0,0 +1,243 @@
+// Package throttle tracks failed authentication attempts per client and in
+// total, and tells callers when further attempts have to wait.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often entries of clients that stopped failing are
+// dropped.
+const sweepInterval = time.Minute
+
+// pendingTTL is how long an allowed attempt counts as pending at most, should
+// its outcome never be recorded.
+const pendingTTL = time.Minute
+
+// Policy describes how failures are penalised. After FreeAttempts failures
+// every further attempt has to wait BaseDelay, doubling with each failure up
+// to MaxDelay. Reaching LockoutAttempts failures blocks all attempts for
+// LockoutDuration. Failures are forgotten once none happened for Window.
+type Policy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAttempts int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+type Config struct {
+	PerIP  Policy
+	Global Policy
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PerIP: Policy{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAttempts: 10,
+			LockoutDuration: 15 * time.Minute,
+			Window:          15 * time.Minute,
+		},
+		Global: Policy{
+			FreeAttempts:    50,
+			BaseDelay:       time.Second,
+			MaxDelay:        10 * time.Second,
+			LockoutAttempts: 0,
+			Window:          5 * time.Minute,
+		},
+	}
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+	// pending counts allowed attempts whose outcome is not known yet. They
+	// count as failures until settled, so a burst of parallel attempts
+	// cannot get past the policy before the first failure is recorded.
+	pending     int
+	lastAllowed time.Time
+}
+
+// retryAt returns when the next attempt is allowed under the policy.
+func (it *entry) retryAt(policy Policy, now time.Time) time.Time {
+	if it.lockedUntil.After(it.lastFailure) {
+		return it.lockedUntil
+	}
+
+	failures := it.failures
+	if now.Sub(it.lastFailure) > policy.Window {
+		failures = 0
+	}
+	attempts := failures + it.pending
+	last := it.lastFailure
+	if it.pending > 0 && it.lastAllowed.After(last) {
+		last = it.lastAllowed
+	}
+	if it.pending > 0 && policy.LockoutAttempts > 0 && attempts >= policy.LockoutAttempts {
+		return last.Add(policy.LockoutDuration)
+	}
+	if attempts <= policy.FreeAttempts {
+		return time.Time{}
+	}
+
+	delay := policy.BaseDelay
+	for i := policy.FreeAttempts + 1; i < attempts && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	return last.Add(delay)
+}
+
+func (it *entry) reserve(now time.Time) {
+	it.pending++
+	it.lastAllowed = now
+}
+
+// expire takes attempts still pending after pendingTTL to be abandoned.
+func (it *entry) expire(now time.Time) {
+	if it.pending > 0 && now.Sub(it.lastAllowed) > pendingTTL {
+		it.pending = 0
+	}
+}
+
+func (it *entry) settle() {
+	if it.pending > 0 {
+		it.pending--
+	}
+}
+
+func (it *entry) fail(policy Policy, now time.Time) {
+	it.settle()
+	if now.Sub(it.lastFailure) > policy.Window {
+		it.failures = 0
+	}
+	it.failures++
+	it.lastFailure = now
+	if policy.LockoutAttempts > 0 && it.failures >= policy.LockoutAttempts {
+		it.lockedUntil = now.Add(policy.LockoutDuration)
+	}
+}
+
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*entry
+	global    entry
+	lastSweep time.Time
+}
+
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{
+		config:  config,
+		now:     time.Now,
+		clients: make(map[string]*entry),
+	}
+}
+
+// NewLimiterWithClock is NewLimiter with a custom time source, for tests.
+func NewLimiterWithClock(config Config, now func() time.Time) *Limiter {
+	l := NewLimiter(config)
+	l.now = now
+	return l
+}
+
+// Allow reports whether the client may attempt to authenticate now. If not,
+// it returns how long the client has to wait. An allowed attempt counts as a
+// failure until it is settled by Success, Failure or Cancel.
+func (it *Limiter) Allow(client string) (time.Duration, bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	now := it.now()
+	it.sweep(now)
+
+	e, ok := it.clients[client]
+	if !ok {
+		e = new(entry)
+	}
+	e.expire(now)
+	it.global.expire(now)
+
+	retryAt := it.global.retryAt(it.config.Global, now)
+	if clientRetryAt := e.retryAt(it.config.PerIP, now); clientRetryAt.After(retryAt) {
+		retryAt = clientRetryAt
+	}
+	if retryAt.After(now) {
+		return retryAt.Sub(now), false
+	}
+
+	e.reserve(now)
+	it.global.reserve(now)
+	it.clients[client] = e
+	return 0, true
+}
+
+// Failure records a failed attempt of the client.
+func (it *Limiter) Failure(client string) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	now := it.now()
+	e, ok := it.clients[client]
+	if !ok {
+		e = new(entry)
+		it.clients[client] = e
+	}
+	e.fail(it.config.PerIP, now)
+	it.global.fail(it.config.Global, now)
+}
+
+// Success forgets the failures of the client. Global failures are kept, so a
+// successful login cannot be used to hide a spraying attack.
+func (it *Limiter) Success(client string) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.global.settle()
+	e, ok := it.clients[client]
+	if !ok {
+		return
+	}
+	e.settle()
+	if e.pending > 0 {
+		*e = entry{pending: e.pending, lastAllowed: e.lastAllowed}
+		return
+	}
+	delete(it.clients, client)
+}
+
+// Cancel settles an allowed attempt that ended without an outcome, such as a
+// login that still needs a second factor.
+func (it *Limiter) Cancel(client string) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.global.settle()
+	if e, ok := it.clients[client]; ok {
+		e.settle()
+	}
+}
+
+func (it *Limiter) sweep(now time.Time) {
+	if now.Sub(it.lastSweep) < sweepInterval {
+		return
+	}
+	it.lastSweep = now
+
+	for client, e := range it.clients {
+		e.expire(now)
+		if now.Sub(e.lastFailure) > it.config.PerIP.Window && !e.lockedUntil.After(now) && e.pending == 0 {
+			delete(it.clients, client)
+		}
+	}
+}
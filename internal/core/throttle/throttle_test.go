@@ -0,0 +1,213 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package throttle_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/throttle"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (it *testClock) Now() time.Time {
+	return it.now
+}
+
+func newTestLimiter() (*throttle.Limiter, *testClock) {
+	clock := &testClock{now: time.Unix(1_000_000, 0)}
+	config := throttle.Config{
+		PerIP: throttle.Policy{
+			FreeAttempts:    2,
+			BaseDelay:       time.Second,
+			MaxDelay:        4 * time.Second,
+			LockoutAttempts: 6,
+			LockoutDuration: time.Hour,
+			Window:          time.Hour,
+		},
+		Global: throttle.Policy{
+			FreeAttempts: 10,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Second,
+			Window:       time.Hour,
+		},
+	}
+	return throttle.NewLimiterWithClock(config, clock.Now), clock
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	t.Run("given free attempts then allows", func(t *testing.T) {
+		limiter, _ := newTestLimiter()
+
+		limiter.Failure("1.1.1.1")
+		limiter.Failure("1.1.1.1")
+		_, ok := limiter.Allow("1.1.1.1")
+
+		assert.Equal(t, true, ok)
+	})
+
+	t.Run("given failures beyond free attempts then backs off exponentially", func(t *testing.T) {
+		limiter, clock := newTestLimiter()
+
+		for i := 0; i < 3; i++ {
+			limiter.Failure("1.1.1.1")
+		}
+		wait, ok := limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+		assert.Equal(t, time.Second, wait)
+
+		clock.now = clock.now.Add(time.Second)
+		limiter.Failure("1.1.1.1")
+		wait, ok = limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+		assert.Equal(t, 2*time.Second, wait)
+
+		clock.now = clock.now.Add(2 * time.Second)
+		limiter.Failure("1.1.1.1")
+		wait, ok = limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+		assert.Equal(t, 4*time.Second, wait)
+
+		clock.now = clock.now.Add(4 * time.Second)
+		_, ok = limiter.Allow("1.1.1.1")
+		assert.Equal(t, true, ok)
+	})
+
+	t.Run("given other client failing then allows", func(t *testing.T) {
+		limiter, _ := newTestLimiter()
+
+		for i := 0; i < 3; i++ {
+			limiter.Failure("1.1.1.1")
+		}
+		_, ok := limiter.Allow("2.2.2.2")
+
+		assert.Equal(t, true, ok)
+	})
+
+	t.Run("given lockout attempts then locks out", func(t *testing.T) {
+		limiter, clock := newTestLimiter()
+
+		for i := 0; i < 6; i++ {
+			limiter.Failure("1.1.1.1")
+		}
+		wait, ok := limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+		assert.Equal(t, time.Hour, wait)
+
+		clock.now = clock.now.Add(time.Hour)
+		_, ok = limiter.Allow("1.1.1.1")
+		assert.Equal(t, true, ok)
+	})
+
+	t.Run("given success then forgets client failures", func(t *testing.T) {
+		limiter, _ := newTestLimiter()
+
+		for i := 0; i < 3; i++ {
+			limiter.Failure("1.1.1.1")
+		}
+		limiter.Success("1.1.1.1")
+		_, ok := limiter.Allow("1.1.1.1")
+
+		assert.Equal(t, true, ok)
+	})
+
+	t.Run("given failures spread over many clients then throttles globally", func(t *testing.T) {
+		limiter, _ := newTestLimiter()
+
+		for i := 0; i < 11; i++ {
+			limiter.Failure(string(rune('a' + i)))
+		}
+		wait, ok := limiter.Allow("2.2.2.2")
+
+		assert.Equal(t, false, ok)
+		assert.Equal(t, time.Second, wait)
+	})
+
+	t.Run("given failures older than window then forgets them", func(t *testing.T) {
+		limiter, clock := newTestLimiter()
+
+		for i := 0; i < 3; i++ {
+			limiter.Failure("1.1.1.1")
+		}
+		clock.now = clock.now.Add(2 * time.Hour)
+		limiter.Failure("1.1.1.1")
+		_, ok := limiter.Allow("1.1.1.1")
+
+		assert.Equal(t, true, ok)
+	})
+}
+
+func TestLimiter_Pending(t *testing.T) {
+	t.Run("given burst of parallel attempts then allows only the free ones", func(t *testing.T) {
+		limiter, _ := newTestLimiter()
+
+		var allowed int32
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, ok := limiter.Allow("1.1.1.1"); ok {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(3), allowed)
+	})
+
+	t.Run("given pending attempts settled then allows again", func(t *testing.T) {
+		limiter, _ := newTestLimiter()
+
+		for i := 0; i < 3; i++ {
+			_, ok := limiter.Allow("1.1.1.1")
+			assert.Equal(t, true, ok)
+		}
+		_, ok := limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+
+		limiter.Cancel("1.1.1.1")
+		limiter.Success("1.1.1.1")
+		_, ok = limiter.Allow("1.1.1.1")
+		assert.Equal(t, true, ok)
+	})
+
+	t.Run("given pending attempts reaching lockout then blocks until settled", func(t *testing.T) {
+		limiter, clock := newTestLimiter()
+
+		for i := 0; i < 6; i++ {
+			_, ok := limiter.Allow("1.1.1.1")
+			assert.Equal(t, true, ok)
+			clock.now = clock.now.Add(4 * time.Second)
+		}
+		wait, ok := limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+		assert.Equal(t, time.Hour-4*time.Second, wait)
+
+		for i := 0; i < 6; i++ {
+			limiter.Failure("1.1.1.1")
+		}
+		wait, ok = limiter.Allow("1.1.1.1")
+		assert.Equal(t, false, ok)
+		assert.Equal(t, time.Hour, wait)
+	})
+
+	t.Run("given pending attempts never settled then expires them", func(t *testing.T) {
+		limiter, clock := newTestLimiter()
+
+		for i := 0; i < 3; i++ {
+			_, ok := limiter.Allow("1.1.1.1")
+			assert.Equal(t, true, ok)
+		}
+		clock.now = clock.now.Add(2 * time.Minute)
+		_, ok := limiter.Allow("1.1.1.1")
+
+		assert.Equal(t, true, ok)
+	})
+}
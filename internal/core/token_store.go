@@ -1,9 +1,33 @@
 package core
 
+import "time"
+
 type Token = string
 
+type SessionID = string
+
+// Session is a token issued to a single device on login. A zero ExpiresAt
+// means the session never expires.
+type Session struct {
+	SessionID  SessionID
+	Token      Token
+	UserID     UserID
+	Device     string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (it *Session) Expired(now time.Time) bool {
+	return !it.ExpiresAt.IsZero() && !now.Before(it.ExpiresAt)
+}
+
 type TokenStore interface {
-	First() (Token, error)
-	Add(token Token) error
-	Has(token Token) (bool, error)
+	ForToken(token Token) (*Session, error)
+	ForUser(userID UserID) ([]*Session, error)
+	Add(session *Session) error
+	Touch(token Token, lastUsedAt time.Time) error
+	Delete(id SessionID) error
+	DeleteExpired(now time.Time) error
 }
@@ -0,0 +1,95 @@
+// Package totp implements time-based one-time passwords as specified in
+// RFC 6238, with the parameters authenticator apps use by default: HMAC-SHA1,
+// 6 digits and a 30 second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint: gosec // RFC 6238 defaults to HMAC-SHA1, which authenticator apps expect.
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// Skew is the number of periods before and after the current one in
+	// which a code is still accepted, to allow for clock drift.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns the otpauth URI authenticator apps enrol with, usually shown as
+// a QR code.
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period.Seconds()))},
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Counter returns the time step t falls into.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code for the given time step.
+func Code(secret string, counter int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1_000_000), nil
+}
+
+// Validate checks the code against the time steps around now and returns the
+// time step it matched. Callers should reject codes whose time step is not
+// after the last one accepted, so a code cannot be replayed.
+func Validate(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Counter(now)
+	for counter := current - Skew; counter <= current+Skew; counter++ {
+		expected, err := Code(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
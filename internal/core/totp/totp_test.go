@@ -0,0 +1,88 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// Test vectors from RFC 6238 Appendix B, truncated to 6 digits.
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		code, err := totp.Code(rfcSecret, totp.Counter(time.Unix(unix, 0)))
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	t.Run("given current code then returns its time step", func(t *testing.T) {
+		counter, ok := totp.Validate(rfcSecret, "050471", now)
+
+		assert.Equal(t, true, ok)
+		assert.Equal(t, totp.Counter(now), counter)
+	})
+
+	t.Run("given code of previous period then accepts it", func(t *testing.T) {
+		code, err := totp.Code(rfcSecret, totp.Counter(now)-1)
+		assert.NoError(t, err)
+
+		counter, ok := totp.Validate(rfcSecret, code, now)
+
+		assert.Equal(t, true, ok)
+		assert.Equal(t, totp.Counter(now)-1, counter)
+	})
+
+	t.Run("given code outside skew then rejects it", func(t *testing.T) {
+		code, err := totp.Code(rfcSecret, totp.Counter(now)-2)
+		assert.NoError(t, err)
+
+		_, ok := totp.Validate(rfcSecret, code, now)
+
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("given malformed code then rejects it", func(t *testing.T) {
+		_, ok := totp.Validate(rfcSecret, "12345", now)
+
+		assert.Equal(t, false, ok)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+
+	_, err = totp.Code(secret, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 32, len(secret))
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(totp.URI("Actual", "admin", "SECRET"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Actual:admin", uri.Path)
+	assert.Equal(t, "SECRET", uri.Query().Get("secret"))
+	assert.Equal(t, "Actual", uri.Query().Get("issuer"))
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of recovery codes handed out when 2FA is
+// enabled.
+const RecoveryCodeCount = 10
+
+// TwoFactor is the TOTP enrolment of a user. It only guards logins once
+// Enabled, which happens after the first code was verified. LastCounter is
+// the time step of the last accepted code, so that no code is accepted twice.
+// Recovery codes are stored as SHA-256 hashes and used up on login.
+type TwoFactor struct {
+	UserID        UserID
+	Secret        string
+	Enabled       bool
+	LastCounter   int64
+	RecoveryCodes []string
+}
+
+// GenerateRecoveryCodes returns new random recovery codes formatted as
+// "xxxxx-xxxxx".
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed by the user, ignoring
+// case, spaces and dashes.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+type TwoFactorStore interface {
+	ForUser(userID UserID) (*TwoFactor, error)
+	Save(twoFactor *TwoFactor) error
+	Delete(userID UserID) error
+}
@@ -0,0 +1,334 @@
+// Package upload assembles files uploaded in chunks. Chunks are written to a
+// temporary file, which only replaces its destination once the whole file
+// arrived with the expected checksum.
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/spf13/afero"
+)
+
+// DefaultTimeout is how long a session may go without receiving a chunk
+// before it is abandoned.
+const DefaultTimeout = time.Hour
+
+// DefaultMaxSize is the largest file a session may declare.
+const DefaultMaxSize int64 = 256 << 20
+
+// DefaultMaxSessions is how many sessions a user may have at once.
+const DefaultMaxSessions = 4
+
+// tempSuffix names the temporary files of sessions.
+const tempSuffix = ".upload"
+
+// Session is an upload of one file by one user.
+type Session struct {
+	ID     string
+	UserID string
+	FileID string
+	// Total is the declared size of the file, which chunks may not exceed.
+	Total int64
+	// Size is the number of bytes received so far, and so the offset of
+	// the next chunk.
+	Size int64
+}
+
+type entry struct {
+	// mu serializes the chunks of a session.
+	mu       sync.Mutex
+	session  Session
+	lastUsed time.Time
+	done     bool
+}
+
+type Sessions struct {
+	fs          afero.Fs
+	dir         string
+	timeout     time.Duration
+	maxSize     int64
+	maxSessions int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*entry
+}
+
+// NewSessions keeps the temporary files of sessions in dir. Sessions that
+// received no chunk for timeout are removed by Sweep. Files larger than
+// maxSize are refused, as are sessions beyond maxSessions per user.
+// Non-positive values select the defaults.
+func NewSessions(fs afero.Fs, dir string, timeout time.Duration, maxSize int64, maxSessions int) *Sessions {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxSessions <= 0 {
+		maxSessions = DefaultMaxSessions
+	}
+	return &Sessions{
+		fs:          fs,
+		dir:         dir,
+		timeout:     timeout,
+		maxSize:     maxSize,
+		maxSessions: maxSessions,
+		now:         time.Now,
+		sessions:    make(map[string]*entry),
+	}
+}
+
+// NewSessionsWithClock is NewSessions with a custom time source, for tests.
+func NewSessionsWithClock(
+	fs afero.Fs,
+	dir string,
+	timeout time.Duration,
+	maxSize int64,
+	maxSessions int,
+	now func() time.Time,
+) *Sessions {
+	s := NewSessions(fs, dir, timeout, maxSize, maxSessions)
+	s.now = now
+	return s
+}
+
+func (it *Sessions) path(id string) string {
+	return filepath.Join(it.dir, id+tempSuffix)
+}
+
+// Create starts an upload of a file of total bytes.
+func (it *Sessions) Create(userID, fileID string, total int64) (Session, error) {
+	if total > it.maxSize {
+		return Session{}, fmt.Errorf("%w: at most %d bytes", internal_errors.ErrUploadTooLarge, it.maxSize)
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return Session{}, err
+	}
+	session := Session{ID: id.String(), UserID: userID, FileID: fileID, Total: total}
+
+	// The session is counted before its file exists, so concurrent
+	// requests cannot exceed the limit.
+	it.mu.Lock()
+	active := 0
+	for _, e := range it.sessions {
+		if e.session.UserID == userID {
+			active++
+		}
+	}
+	if active >= it.maxSessions {
+		it.mu.Unlock()
+		return Session{}, fmt.Errorf("%w: at most %d", internal_errors.ErrUploadTooMany, it.maxSessions)
+	}
+	e := &entry{session: session, lastUsed: it.now()}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	it.sessions[session.ID] = e
+	it.mu.Unlock()
+
+	file, err := it.fs.Create(it.path(session.ID))
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		it.end(e)
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// lock returns the entry of a session of the user, locked.
+func (it *Sessions) lock(id, userID string) (*entry, error) {
+	it.mu.Lock()
+	e, ok := it.sessions[id]
+	it.mu.Unlock()
+	if !ok || e.session.UserID != userID {
+		return nil, internal_errors.ErrUploadNotFound
+	}
+
+	e.mu.Lock()
+	// The session may have ended while waiting for the lock.
+	if e.done {
+		e.mu.Unlock()
+		return nil, internal_errors.ErrUploadNotFound
+	}
+	return e, nil
+}
+
+// Get returns a session of the user, which tells clients resuming an upload
+// where to continue.
+func (it *Sessions) Get(id, userID string) (Session, error) {
+	e, err := it.lock(id, userID)
+	if err != nil {
+		return Session{}, err
+	}
+	defer e.mu.Unlock()
+
+	return e.session, nil
+}
+
+// Write appends a chunk at offset, which has to be the size received so
+// far. Chunks cut off by a dropped connection are kept as far as they
+// arrived, so the returned session tells where to resume even on errors.
+func (it *Sessions) Write(id, userID string, offset int64, chunk io.Reader) (Session, error) {
+	e, err := it.lock(id, userID)
+	if err != nil {
+		return Session{}, err
+	}
+	defer e.mu.Unlock()
+
+	if offset != e.session.Size {
+		return e.session, fmt.Errorf("%w: expected %d", internal_errors.ErrUploadOffsetMismatch, e.session.Size)
+	}
+
+	file, err := it.fs.OpenFile(it.path(id), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return e.session, err
+	}
+	// One byte more than the declared size tells if the chunk exceeds it.
+	n, err := io.Copy(file, io.LimitReader(chunk, e.session.Total-e.session.Size+1))
+	if err == nil && e.session.Size+n > e.session.Total {
+		n--
+		err = file.Truncate(e.session.Total)
+		if err == nil {
+			err = fmt.Errorf("%w: declared %d bytes", internal_errors.ErrUploadTooLarge, e.session.Total)
+		}
+	}
+	e.session.Size += n
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	it.mu.Lock()
+	e.lastUsed = it.now()
+	it.mu.Unlock()
+	return e.session, err
+}
+
+// Finalize ends a session. If the received file matches the hex encoded
+// SHA-256 checksum, replace is called with the path of the file to move it
+// into place. Otherwise the file is discarded and the upload has to start
+// over. Files replace does not move are discarded too.
+func (it *Sessions) Finalize(id, userID, checksum string, replace func(session Session, path string) error) (Session, error) {
+	e, err := it.lock(id, userID)
+	if err != nil {
+		return Session{}, err
+	}
+	defer e.mu.Unlock()
+	defer it.end(e)
+
+	sum, err := it.checksum(id)
+	if err != nil {
+		return e.session, err
+	}
+	if !strings.EqualFold(sum, checksum) {
+		return e.session, internal_errors.ErrUploadChecksumMismatch
+	}
+
+	return e.session, replace(e.session, it.path(id))
+}
+
+func (it *Sessions) checksum(id string) (string, error) {
+	file, err := it.fs.Open(it.path(id))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// end removes a session and its temporary file, if still there. Callers must
+// hold the lock of the entry.
+func (it *Sessions) end(e *entry) {
+	e.done = true
+	it.mu.Lock()
+	delete(it.sessions, e.session.ID)
+	it.mu.Unlock()
+	_ = it.fs.Remove(it.path(e.session.ID))
+}
+
+// Len returns the number of sessions.
+func (it *Sessions) Len() int {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return len(it.sessions)
+}
+
+// RemoveOrphans removes the temporary files no session knows of, whatever
+// their age. It is meant to run at startup, when any such file was left by
+// an earlier process.
+func (it *Sessions) RemoveOrphans() error {
+	return it.removeTempFiles(0)
+}
+
+// Sweep removes sessions that received no chunk for the timeout, along with
+// temporary files no session knows of, such as those left by an earlier
+// process.
+func (it *Sessions) Sweep() error {
+	now := it.now()
+
+	it.mu.Lock()
+	abandoned := make([]*entry, 0)
+	for _, e := range it.sessions {
+		if now.Sub(e.lastUsed) >= it.timeout {
+			abandoned = append(abandoned, e)
+		}
+	}
+	it.mu.Unlock()
+
+	for _, e := range abandoned {
+		e.mu.Lock()
+		// A chunk may have arrived while waiting for the lock.
+		it.mu.Lock()
+		idle := now.Sub(e.lastUsed) >= it.timeout
+		it.mu.Unlock()
+		if !e.done && idle {
+			it.end(e)
+		}
+		e.mu.Unlock()
+	}
+
+	return it.removeTempFiles(it.timeout)
+}
+
+// removeTempFiles removes the temporary files no session knows of that were
+// last written at least minAge ago. A zero minAge removes them all.
+func (it *Sessions) removeTempFiles(minAge time.Duration) error {
+	now := it.now()
+	infos, err := afero.ReadDir(it.fs, it.dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, tempSuffix) || (minAge > 0 && now.Sub(info.ModTime()) < minAge) {
+			continue
+		}
+		it.mu.Lock()
+		_, active := it.sessions[strings.TrimSuffix(name, tempSuffix)]
+		it.mu.Unlock()
+		if !active {
+			if err := it.fs.Remove(filepath.Join(it.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package upload_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/upload"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (it *testClock) Now() time.Time {
+	return it.now
+}
+
+func newTestSessions(t *testing.T) (*upload.Sessions, afero.Fs, *testClock) {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, fs.MkdirAll("files", 0o755))
+	clock := &testClock{now: time.Now()}
+	return upload.NewSessionsWithClock(fs, "files", time.Hour, 1024, 3, clock.Now), fs, clock
+}
+
+// moveTo returns a replace function for Finalize that moves the file to dst.
+func moveTo(fs afero.Fs, dst string) func(upload.Session, string) error {
+	return func(_ upload.Session, path string) error {
+		return fs.Rename(path, dst)
+	}
+}
+
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSessions_Create(t *testing.T) {
+	t.Run("given size above the maximum then returns error", func(t *testing.T) {
+		sessions, fs, _ := newTestSessions(t)
+
+		_, err := sessions.Create("u1", "f1", 1025)
+
+		assert.ErrorIs(t, err, internal_errors.ErrUploadTooLarge)
+		infos, err := afero.ReadDir(fs, "files")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(infos))
+	})
+
+	t.Run("given too many sessions of the user then returns error", func(t *testing.T) {
+		sessions, _, _ := newTestSessions(t)
+		for i := 0; i < 3; i++ {
+			_, err := sessions.Create("u1", "f1", 1024)
+			assert.NoError(t, err)
+		}
+
+		_, err := sessions.Create("u1", "f2", 1024)
+		assert.ErrorIs(t, err, internal_errors.ErrUploadTooMany)
+		_, err = sessions.Create("u2", "f3", 1024)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, sessions.Len())
+	})
+}
+
+func TestSessions_Write(t *testing.T) {
+	t.Run("given chunks at their offsets then appends them", func(t *testing.T) {
+		sessions, _, _ := newTestSessions(t)
+		session, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+
+		_, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("hello "))
+		assert.NoError(t, err)
+		session, err = sessions.Write(session.ID, "u1", 6, strings.NewReader("world"))
+		assert.NoError(t, err)
+
+		assert.Equal(t, int64(11), session.Size)
+	})
+
+	t.Run("given wrong offset then returns the offset to resume from", func(t *testing.T) {
+		sessions, _, _ := newTestSessions(t)
+		session, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+		_, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("hello "))
+		assert.NoError(t, err)
+
+		session, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("hello "))
+
+		assert.ErrorIs(t, err, internal_errors.ErrUploadOffsetMismatch)
+		assert.Equal(t, int64(6), session.Size)
+	})
+
+	t.Run("given session of another user then returns error", func(t *testing.T) {
+		sessions, _, _ := newTestSessions(t)
+		session, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+
+		_, err = sessions.Write(session.ID, "u2", 0, strings.NewReader("hello"))
+
+		assert.ErrorIs(t, err, internal_errors.ErrUploadNotFound)
+	})
+
+	t.Run("given chunk beyond the declared size then keeps only the declared bytes", func(t *testing.T) {
+		sessions, fs, _ := newTestSessions(t)
+		session, err := sessions.Create("u1", "f1", 5)
+		assert.NoError(t, err)
+
+		session, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("hello world"))
+
+		assert.ErrorIs(t, err, internal_errors.ErrUploadTooLarge)
+		assert.Equal(t, int64(5), session.Size)
+		data, err := afero.ReadFile(fs, "files/"+session.ID+".upload")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+	})
+}
+
+func TestSessions_Finalize(t *testing.T) {
+	t.Run("given matching checksum then replaces the destination", func(t *testing.T) {
+		sessions, fs, _ := newTestSessions(t)
+		assert.NoError(t, afero.WriteFile(fs, "files/f1.blob", []byte("old"), 0o600))
+		session, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+		_, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("new"))
+		assert.NoError(t, err)
+
+		_, err = sessions.Finalize(session.ID, "u1", checksum("new"), moveTo(fs, "files/f1.blob"))
+
+		assert.NoError(t, err)
+		data, err := afero.ReadFile(fs, "files/f1.blob")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", string(data))
+		assert.Equal(t, 0, sessions.Len())
+		_, err = sessions.Get(session.ID, "u1")
+		assert.ErrorIs(t, err, internal_errors.ErrUploadNotFound)
+	})
+
+	t.Run("given failing replace then discards the upload", func(t *testing.T) {
+		sessions, fs, _ := newTestSessions(t)
+		session, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+		_, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("new"))
+		assert.NoError(t, err)
+
+		_, err = sessions.Finalize(session.ID, "u1", checksum("new"), func(upload.Session, string) error {
+			return errors.New("failed")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 0, sessions.Len())
+		infos, err := afero.ReadDir(fs, "files")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(infos))
+	})
+
+	t.Run("given wrong checksum then keeps the destination and discards the upload", func(t *testing.T) {
+		sessions, fs, _ := newTestSessions(t)
+		assert.NoError(t, afero.WriteFile(fs, "files/f1.blob", []byte("old"), 0o600))
+		session, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+		_, err = sessions.Write(session.ID, "u1", 0, strings.NewReader("ne"))
+		assert.NoError(t, err)
+
+		_, err = sessions.Finalize(session.ID, "u1", checksum("new"), moveTo(fs, "files/f1.blob"))
+
+		assert.ErrorIs(t, err, internal_errors.ErrUploadChecksumMismatch)
+		data, err := afero.ReadFile(fs, "files/f1.blob")
+		assert.NoError(t, err)
+		assert.Equal(t, "old", string(data))
+		assert.Equal(t, 0, sessions.Len())
+		infos, err := afero.ReadDir(fs, "files")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(infos))
+	})
+}
+
+func TestSessions_Sweep(t *testing.T) {
+	t.Run("given abandoned session then removes it and its file", func(t *testing.T) {
+		sessions, fs, clock := newTestSessions(t)
+		abandoned, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+		clock.now = clock.now.Add(30 * time.Minute)
+		active, err := sessions.Create("u1", "f2", 1024)
+		assert.NoError(t, err)
+		clock.now = clock.now.Add(45 * time.Minute)
+
+		err = sessions.Sweep()
+
+		assert.NoError(t, err)
+		_, err = sessions.Get(abandoned.ID, "u1")
+		assert.ErrorIs(t, err, internal_errors.ErrUploadNotFound)
+		_, err = sessions.Get(active.ID, "u1")
+		assert.NoError(t, err)
+		infos, err := afero.ReadDir(fs, "files")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(infos))
+	})
+
+	t.Run("given file left by an earlier process then removes it", func(t *testing.T) {
+		sessions, fs, clock := newTestSessions(t)
+		assert.NoError(t, afero.WriteFile(fs, "files/old.upload", []byte("partial"), 0o600))
+		assert.NoError(t, afero.WriteFile(fs, "files/f1.blob", []byte("budget"), 0o600))
+		clock.now = clock.now.Add(2 * time.Hour)
+
+		err := sessions.Sweep()
+
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fs, "files/old.upload")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		exists, err = afero.Exists(fs, "files/f1.blob")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestSessions_RemoveOrphans(t *testing.T) {
+	t.Run("given recent file left by an earlier process then removes it", func(t *testing.T) {
+		sessions, fs, _ := newTestSessions(t)
+		assert.NoError(t, afero.WriteFile(fs, "files/old.upload", []byte("partial"), 0o600))
+		active, err := sessions.Create("u1", "f1", 1024)
+		assert.NoError(t, err)
+
+		err = sessions.RemoveOrphans()
+
+		assert.NoError(t, err)
+		exists, err := afero.Exists(fs, "files/old.upload")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		exists, err = afero.Exists(fs, "files/"+active.ID+".upload")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
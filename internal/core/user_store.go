@@ -0,0 +1,30 @@
+package core
+
+type UserID = string
+
+type Password = string
+
+// DefaultUserName is the name given to the bootstrap user. Login requests
+// without a user name are treated as requests for this user, which keeps
+// single-user clients working.
+const DefaultUserName = "admin"
+
+// User is an account of the server. Users provisioned through an external
+// identity have no password and carry the identity as ExternalID.
+type User struct {
+	UserID     UserID
+	UserName   string
+	Password   Password
+	IsAdmin    bool
+	ExternalID string
+}
+
+type UserStore interface {
+	Count() (int, error)
+	ForID(id UserID) (*User, error)
+	ForUserName(name string) (*User, error)
+	ForExternalID(externalID string) (*User, error)
+	All() ([]*User, error)
+	Add(user *User) error
+	SetPassword(id UserID, password Password) error
+}
@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var (
+	ErrBlobCorrupted = errors.New("uploaded file does not match its recorded content")
+)
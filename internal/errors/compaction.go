@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var (
+	ErrMessagesCompacted = errors.New("messages before the compaction horizon")
+)
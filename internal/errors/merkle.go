@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var (
+	ErrMerkleUnableToParse = errors.New("unable to parse merkle")
+)
@@ -0,0 +1,10 @@
+package errors
+
+import "errors"
+
+var (
+	ErrOpenIDInvalidConfig = errors.New("invalid openid configuration")
+	ErrOpenIDInvalidState  = errors.New("unknown or expired openid login state")
+	ErrOpenIDProvider      = errors.New("openid provider request failed")
+	ErrOpenIDInvalidToken  = errors.New("invalid openid id token")
+)
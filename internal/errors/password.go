@@ -0,0 +1,11 @@
+package errors
+
+import "errors"
+
+var (
+	ErrPasswordUnknownHash   = errors.New("unknown password hash format")
+	ErrPasswordUnknownScheme = errors.New("unknown password hash algorithm")
+	ErrPasswordTooShort      = errors.New("password is too short")
+	ErrPasswordDenied        = errors.New("password is too common")
+	ErrPasswordInvalidConfig = errors.New("invalid password hash parameters")
+)
@@ -0,0 +1,5 @@
+package errors
+
+import "errors"
+
+var ErrProxyAuthInvalidConfig = errors.New("invalid proxy auth configuration")
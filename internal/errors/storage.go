@@ -5,4 +5,5 @@ import "errors"
 var (
 	ErrStorageRecordNotFound  = errors.New("record not found")
 	ErrStorageNoRecordUpdated = errors.New("no record updated")
+	ErrGroupReset             = errors.New("sync group of the file was reset")
 )
@@ -3,5 +3,8 @@ package errors
 import "errors"
 
 var (
-	ErrTimestampUnableToParse = errors.New("unable to parse timestamp")
+	ErrTimestampUnableToParse   = errors.New("unable to parse timestamp")
+	ErrTimestampClockDrift      = errors.New("timestamp is too far ahead of the clock")
+	ErrTimestampCounterOverflow = errors.New("timestamp counter overflow")
+	ErrTimestampDuplicateNode   = errors.New("timestamp from a node with the same id")
 )
@@ -0,0 +1,11 @@
+package errors
+
+import "errors"
+
+var (
+	ErrUploadNotFound         = errors.New("upload session not found")
+	ErrUploadOffsetMismatch   = errors.New("chunk offset does not match the received size")
+	ErrUploadChecksumMismatch = errors.New("upload checksum does not match")
+	ErrUploadTooLarge         = errors.New("upload is too large")
+	ErrUploadTooMany          = errors.New("too many uploads in progress")
+)
@@ -0,0 +1,5 @@
+package errors
+
+import "errors"
+
+var ErrUserNameTaken = errors.New("user name is taken by another user")
@@ -1,16 +1,19 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/nathanjisaac/actual-server-go/internal/core"
-	"golang.org/x/crypto/bcrypt"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 )
 
 type NeedsBootstrapData struct {
-	Bootstrapped bool `json:"bootstrapped"`
+	Bootstrapped bool     `json:"bootstrapped"`
+	LoginMethods []string `json:"loginMethods"`
 }
 
 type NeedsBootstrapResponse struct {
@@ -19,7 +22,7 @@ type NeedsBootstrapResponse struct {
 }
 
 func (it *RouteHandler) NeedsBootstrap(c echo.Context) error {
-	count, err := it.PasswordStore.Count()
+	count, err := it.UserStore.Count()
 
 	if err != nil {
 		c.Echo().Logger.Error(err)
@@ -30,6 +33,7 @@ func (it *RouteHandler) NeedsBootstrap(c echo.Context) error {
 		SuccessResponse: SuccessResponse{Status: "ok"},
 		Data: NeedsBootstrapData{
 			Bootstrapped: count > 0,
+			LoginMethods: it.loginMethods(),
 		},
 	}
 
@@ -37,7 +41,9 @@ func (it *RouteHandler) NeedsBootstrap(c echo.Context) error {
 }
 
 type BootstrapRequestBody struct {
+	UserName string        `json:"userName"`
 	Password core.Password `json:"password"`
+	Device   string        `json:"device"`
 }
 
 type BootstrapData struct {
@@ -55,6 +61,10 @@ func (it *RouteHandler) Bootstrap(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
+	if wait, ok := it.allowAttempt(c); !ok {
+		return it.tooManyAttempts(c, wait)
+	}
+	defer it.attemptEnded(c)
 
 	if req.Password == "" {
 		r := &ErrorResponse{
@@ -63,13 +73,22 @@ func (it *RouteHandler) Bootstrap(c echo.Context) error {
 		}
 		return c.JSON(http.StatusBadRequest, r)
 	}
+	if reason := it.passwordPolicyReason(req.Password); reason != "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: reason,
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
 
-	count, err := it.PasswordStore.Count()
+	count, err := it.UserStore.Count()
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
 	if count != 0 {
+		it.attemptFailed(c)
+		it.audit(c, &core.AuditEntry{Event: core.AuditBootstrap, Outcome: core.AuditFailure})
 		r := &ErrorResponse{
 			Status: "error",
 			Reason: "already-bootstrapped",
@@ -77,32 +96,45 @@ func (it *RouteHandler) Bootstrap(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, r)
 	}
 
-	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), 12)
+	hashed, err := it.passwords().Hash(req.Password)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	err = it.PasswordStore.Add(string(hashed))
+	if req.UserName == "" {
+		req.UserName = core.DefaultUserName
+	}
+	user := &core.User{
+		UserID:   uuid.NewString(),
+		UserName: req.UserName,
+		Password: hashed,
+		IsAdmin:  true,
+	}
+	err = it.UserStore.Add(user)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
 
-	token := uuid.NewString()
-	err = it.TokenStore.Add(token)
+	session, err := it.newSession(c, user.UserID, req.Device)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
+	it.audit(c, &core.AuditEntry{Event: core.AuditBootstrap, Outcome: core.AuditSuccess})
 	r := &BootstrapResponse{
 		SuccessResponse: SuccessResponse{Status: "ok"},
-		Data:            BootstrapData{Token: token},
+		Data:            BootstrapData{Token: session.Token},
 	}
 	return c.JSON(http.StatusOK, r)
 }
 
 type LoginRequestBody struct {
+	UserName string        `json:"userName"`
 	Password core.Password `json:"password"`
+	Device   string        `json:"device"`
+	// Code is a TOTP or recovery code, required once 2FA is enabled.
+	Code string `json:"code"`
 }
 
 type LoginData struct {
@@ -131,46 +163,143 @@ func (it *RouteHandler) Login(c echo.Context) error {
 		return err
 	}
 
-	hashedPass, err := it.PasswordStore.First()
+	// The authenticating proxy already checked the user, so no password
+	// is needed.
+	proxyUser, err := it.proxyUser(c)
+	if errors.Is(err, internal_errors.ErrUserNameTaken) {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "user-exists",
+		}
+		return c.JSON(http.StatusForbidden, r)
+	} else if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if proxyUser != nil {
+		tf, err := it.enabledTwoFactor(proxyUser.UserID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		if tf != nil {
+			if wait, ok := it.allowAttempt(c); !ok {
+				return it.tooManyAttempts(c, wait)
+			}
+			defer it.attemptEnded(c)
+			if ok, err := it.checkTwoFactor(c, tf, req.Code); !ok {
+				return err
+			}
+			it.attemptSucceeded(c)
+		}
+
+		if req.Device == "" {
+			req.Device = "proxy"
+		}
+		session, err := it.newSession(c, proxyUser.UserID, req.Device)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		it.audit(c, &core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditSuccess})
+		r := &LoginSuccessResponse{
+			SuccessResponse: SuccessResponse{Status: "ok"},
+			Data:            LoginSuccessData{Token: session.Token},
+		}
+		return c.JSON(http.StatusOK, r)
+	}
+
+	if wait, ok := it.allowAttempt(c); !ok {
+		return it.tooManyAttempts(c, wait)
+	}
+	defer it.attemptEnded(c)
+
+	if req.UserName == "" {
+		req.UserName = core.DefaultUserName
+	}
+	user, err := it.UserStore.ForUserName(req.UserName)
 	if err != nil {
+		it.attemptFailed(c)
+		it.audit(c, &core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditFailure})
 		r := &LoginFailResponse{Status: "ok", Data: LoginData{Token: nil}}
 		return c.JSON(http.StatusOK, r)
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(hashedPass), []byte(req.Password)); err == nil {
-		// Right now, tokens are permanent and there's just one in the
-		// system. In the future this should probably evolve to be a
-		// "session" that times out after a long time or something, and
-		// maybe each device has a different token
-		token, err := it.TokenStore.First()
+	ok, needsRehash, err := it.passwords().Verify(user.Password, req.Password)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+	}
+	if ok {
+		tf, err := it.enabledTwoFactor(user.UserID)
 		if err != nil {
 			c.Echo().Logger.Error(err)
 			return err
 		}
+		if tf != nil {
+			if ok, err := it.checkTwoFactor(c, tf, req.Code); !ok {
+				return err
+			}
+		}
+
+		it.attemptSucceeded(c)
+
+		if needsRehash {
+			it.rehashPassword(c, user.UserID, req.Password)
+		}
+
+		// Every login is a new session, so each device can be listed
+		// and revoked on its own.
+		session, err := it.newSession(c, user.UserID, req.Device)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		it.audit(c, &core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditSuccess})
 		r := &LoginSuccessResponse{
 			SuccessResponse: SuccessResponse{Status: "ok"},
-			Data:            LoginSuccessData{Token: token},
+			Data:            LoginSuccessData{Token: session.Token},
 		}
 		return c.JSON(http.StatusOK, r)
 	}
 
+	it.attemptFailed(c)
+	it.audit(c, &core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditFailure, UserID: user.UserID})
 	r := &LoginFailResponse{Status: "ok", Data: LoginData{Token: nil}}
 	return c.JSON(http.StatusOK, r)
 }
 
 type ChangePassRequestBody struct {
-	Token    core.Token    `json:"token"`
-	Password core.Password `json:"password"`
+	Token       core.Token    `json:"token"`
+	OldPassword core.Password `json:"oldPassword"`
+	Password    core.Password `json:"password"`
 }
 
+type ChangePassData struct {
+	Token core.Token `json:"token"`
+}
+
+type ChangePassResponse struct {
+	SuccessResponse
+	Data ChangePassData `json:"data"`
+}
+
+// ChangePassword sets a new password after checking the current one. All
+// sessions of the user end, and the caller continues with the new session
+// token returned, so a stolen token is useless afterwards.
 func (it *RouteHandler) ChangePassword(c echo.Context) error {
 	req := new(ChangePassRequestBody)
 	if err := c.Bind(req); err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	if wait, ok := it.allowAttempt(c); !ok {
+		return it.tooManyAttempts(c, wait)
+	}
+	defer it.attemptEnded(c)
+	session, val := it.authenticateSession(c, req.Token)
 	if !val {
+		it.attemptFailed(c)
+		it.audit(c, &core.AuditEntry{Event: core.AuditChangePassword, Outcome: core.AuditFailure})
 		r := &ErrorResponse{
 			Status: "error",
 			Reason: "auth-error",
@@ -185,21 +314,68 @@ func (it *RouteHandler) ChangePassword(c echo.Context) error {
 		}
 		return c.JSON(http.StatusBadRequest, r)
 	}
+	if reason := it.passwordPolicyReason(req.Password); reason != "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: reason,
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 12)
+	user, err := it.UserStore.ForID(session.UserID)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	// Note that this doesn't have an ID/USERNAME to set password. This table only ever
-	// has 1 row (maybe that will change in the future? if this this will not work)
-	err = it.PasswordStore.Set(string(hash))
+	ok, _, err := it.passwords().Verify(user.Password, req.OldPassword)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+	}
+	if !ok {
+		it.attemptFailed(c)
+		it.audit(c, &core.AuditEntry{Event: core.AuditChangePassword, Outcome: core.AuditFailure})
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-old-password",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+	it.attemptSucceeded(c)
+
+	hash, err := it.passwords().Hash(req.Password)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	err = it.UserStore.SetPassword(user.UserID, hash)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
 
-	r := &SuccessResponse{Status: "ok", Data: nil}
+	sessions, err := it.TokenStore.ForUser(user.UserID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	for _, s := range sessions {
+		err = it.TokenStore.Delete(s.SessionID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+	}
+	newSession, err := it.newSession(c, user.UserID, session.Device)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	it.audit(c, &core.AuditEntry{Event: core.AuditChangePassword, Outcome: core.AuditSuccess})
+
+	r := &ChangePassResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            ChangePassData{Token: newSession.Token},
+	}
 	return c.JSON(http.StatusOK, r)
 }
 
@@ -221,7 +397,7 @@ func (it *RouteHandler) ValidateUser(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	_, val := it.authenticateUser(c, req.Token)
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -234,16 +410,64 @@ func (it *RouteHandler) ValidateUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, r)
 }
 
-func (it *RouteHandler) authenticateUser(c echo.Context, token core.Token) bool {
+// authenticateUser resolves the session token, taken from the request body or
+// the `x-actual-token` and `Authorization` headers, to the user it was issued
+// for. On routes guarded by RequireScope an API key authenticates as well, and
+// with proxy auth enabled the user asserted by a trusted proxy takes
+// precedence over any token.
+func (it *RouteHandler) authenticateUser(c echo.Context, token core.Token) (core.UserID, bool) {
+	if key, ok := c.Get(apiKeyContextKey).(*core.APIKey); ok {
+		return key.UserID, true
+	}
+	// Users with 2FA need a session from Login, which asks for their code.
+	if user, err := it.proxyUser(c); err != nil {
+		c.Echo().Logger.Error(err)
+	} else if user != nil {
+		tf, err := it.enabledTwoFactor(user.UserID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+		} else if tf == nil {
+			return user.UserID, true
+		}
+	}
+
+	session, ok := it.authenticateSession(c, token)
+	if !ok {
+		return "", false
+	}
+	return session.UserID, true
+}
+
+// authenticateSession looks up the session for the token, taken from the
+// request body or the `x-actual-token` and `Authorization` headers. Expired
+// sessions are removed and rejected.
+func (it *RouteHandler) authenticateSession(c echo.Context, token core.Token) (*core.Session, bool) {
 	if token == "" {
-		token = c.Request().Header.Get("x-actual-token")
+		token = headerToken(c)
 	}
 	if token == "" {
-		return false
+		return nil, false
 	}
-	res, err := it.TokenStore.Has(token)
+	session, err := it.TokenStore.ForToken(token)
 	if err != nil {
-		return false
+		return nil, false
+	}
+
+	now := time.Now()
+	if session.Expired(now) {
+		err = it.TokenStore.Delete(session.SessionID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+		}
+		return nil, false
+	}
+	if now.Sub(session.LastUsedAt) >= sessionTouchInterval {
+		err = it.TokenStore.Touch(token, now)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+		}
+		session.LastUsedAt = now
 	}
-	return res
+	c.Set(sessionContextKey, session)
+	return session, true
 }
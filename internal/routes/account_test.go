@@ -8,17 +8,20 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	"github.com/nathanjisaac/actual-server-go/internal/core/throttle"
 	"github.com/nathanjisaac/actual-server-go/internal/routes"
 	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func setupAccountTestHandler(body string, pstore core.PasswordStore, tstore core.TokenStore) (
+func setupAccountTestHandler(body string, ustore core.UserStore, tstore core.TokenStore) (
 	*routes.RouteHandler,
 	echo.Context,
 	*httptest.ResponseRecorder,
@@ -32,32 +35,32 @@ func setupAccountTestHandler(body string, pstore core.PasswordStore, tstore core
 		Config: core.Config{
 			Mode: core.Development,
 		},
-		FileStore:     nil,
-		PasswordStore: nil,
-		TokenStore:    nil,
+		FileStore:  nil,
+		UserStore:  nil,
+		TokenStore: nil,
 	}
-	switch pstore {
+	switch ustore {
 	case nil:
 		if tstore != nil {
 			h = &routes.RouteHandler{
-				FileStore:     nil,
-				PasswordStore: nil,
-				TokenStore:    tstore,
+				FileStore:  nil,
+				UserStore:  nil,
+				TokenStore: tstore,
 			}
 		}
 	default:
 		switch tstore {
 		case nil:
 			h = &routes.RouteHandler{
-				FileStore:     nil,
-				PasswordStore: pstore,
-				TokenStore:    nil,
+				FileStore:  nil,
+				UserStore:  ustore,
+				TokenStore: nil,
 			}
 		default:
 			h = &routes.RouteHandler{
-				FileStore:     nil,
-				PasswordStore: pstore,
-				TokenStore:    tstore,
+				FileStore:  nil,
+				UserStore:  ustore,
+				TokenStore: tstore,
 			}
 		}
 	}
@@ -66,7 +69,7 @@ func setupAccountTestHandler(body string, pstore core.PasswordStore, tstore core
 
 func TestNeedsBootstrap(t *testing.T) {
 	t.Run("given no passwords then return not bootstrapped", func(t *testing.T) {
-		store := memory.NewPasswordStore()
+		store := memory.NewUserStore()
 		h, c, rec := setupAccountTestHandler("", store, nil)
 
 		var res routes.NeedsBootstrapResponse
@@ -77,13 +80,14 @@ func TestNeedsBootstrap(t *testing.T) {
 		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
 
 		assert.Equal(t, false, res.Data.Bootstrapped)
+		assert.Equal(t, []string{"password"}, res.Data.LoginMethods)
 	})
 
 	t.Run("given a password then return bootstrapped", func(t *testing.T) {
-		store := memory.NewPasswordStore()
+		store := memory.NewUserStore()
 		h, c, rec := setupAccountTestHandler("", store, nil)
 
-		err := store.Add("password")
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password", IsAdmin: true})
 		assert.NoError(t, err)
 
 		var res routes.NeedsBootstrapResponse
@@ -112,10 +116,10 @@ func TestBootstrap(t *testing.T) {
 	})
 
 	t.Run("given already bootstrapped then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
-		h, c, rec := setupAccountTestHandler(`{"password":"pass"}`, pStore, nil)
+		uStore := memory.NewUserStore()
+		h, c, rec := setupAccountTestHandler(`{"password":"pass"}`, uStore, nil)
 
-		err := pStore.Add("password")
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password", IsAdmin: true})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -129,9 +133,9 @@ func TestBootstrap(t *testing.T) {
 	})
 
 	t.Run("given not bootstrapped then returns token", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
-		h, c, rec := setupAccountTestHandler(`{"password":"pass"}`, pStore, tStore)
+		h, c, rec := setupAccountTestHandler(`{"password":"pass"}`, uStore, tStore)
 
 		var res routes.BootstrapResponse
 		err := h.Bootstrap(c)
@@ -147,15 +151,15 @@ func TestBootstrap(t *testing.T) {
 
 func TestLogin(t *testing.T) {
 	t.Run("given empty password then returns no token", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(uuid.NewString())
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: uuid.NewString(), UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.LoginFailResponse
@@ -170,9 +174,9 @@ func TestLogin(t *testing.T) {
 	})
 
 	t.Run("given not bootstrapped then returns no token", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		var res routes.LoginFailResponse
 		err := h.Login(c)
@@ -186,15 +190,15 @@ func TestLogin(t *testing.T) {
 	})
 
 	t.Run("given wrong password then returns no token", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
-		h, c, rec := setupAccountTestHandler(`{"password":"pass"}`, pStore, tStore)
+		h, c, rec := setupAccountTestHandler(`{"password":"pass"}`, uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(uuid.NewString())
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: uuid.NewString(), UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.LoginFailResponse
@@ -208,17 +212,17 @@ func TestLogin(t *testing.T) {
 		assert.Equal(t, nil, res.Data.Token)
 	})
 
-	t.Run("given correct password then returns token", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+	t.Run("given correct password then returns new session token", func(t *testing.T) {
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
-		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, pStore, tStore)
+		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
 		token := uuid.NewString()
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.LoginSuccessResponse
@@ -229,22 +233,52 @@ func TestLogin(t *testing.T) {
 		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
 		assert.Equal(t, "ok", res.Status)
 		assert.NotEqual(t, nil, res.Data)
-		assert.Equal(t, token, res.Data.Token)
+		assert.NotEqual(t, token, res.Data.Token)
+
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "u1", session.UserID)
+		assert.Equal(t, 2, len(tStore.Sessions))
+	})
+
+	t.Run("given device and user agent then stores them on the session", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"password":"password123","device":"laptop"}`, uStore, tStore)
+		h.Config.SessionTTL = time.Hour
+		c.Request().Header.Set("User-Agent", "test-agent")
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "laptop", session.Device)
+		assert.Equal(t, "test-agent", session.UserAgent)
+		assert.WithinDuration(t, session.CreatedAt.Add(time.Hour), session.ExpiresAt, time.Second)
 	})
 }
 
 func TestChangePassword(t *testing.T) {
 	t.Run("given no token in body/header then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -258,16 +292,16 @@ func TestChangePassword(t *testing.T) {
 	})
 
 	t.Run("given empty password with token in body then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s"}`, token), pStore, tStore)
+		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s"}`, token), uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -281,16 +315,16 @@ func TestChangePassword(t *testing.T) {
 	})
 
 	t.Run("given empty password with token in header then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", token)
 
@@ -304,66 +338,173 @@ func TestChangePassword(t *testing.T) {
 		assert.Equal(t, "invalid-password", res.Reason)
 	})
 
-	t.Run("given password with token in body then returns success", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+	t.Run("given password with token in body then returns new token", func(t *testing.T) {
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s","password":"password456"}`, token), pStore, tStore)
+		body := fmt.Sprintf(`{"token":"%s","oldPassword":"password123","password":"password456"}`, token)
+		h, c, rec := setupAccountTestHandler(body, uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 
-		var res routes.SuccessResponse
+		var res routes.ChangePassResponse
 		err = h.ChangePassword(c)
 		assert.NoError(t, err)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
 		assert.Equal(t, "ok", res.Status)
-		assert.Equal(t, nil, res.Data)
+		assert.NotEqual(t, token, res.Data.Token)
+		_, err = tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		_, err = tStore.ForToken(token)
+		assert.Error(t, err)
 	})
 
-	t.Run("given password with token in header then returns success", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+	t.Run("given password with token in header then returns new token", func(t *testing.T) {
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler(`{"password":"password456"}`, pStore, tStore)
+		h, c, rec := setupAccountTestHandler(`{"oldPassword":"password123","password":"password456"}`, uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", token)
 
-		var res routes.SuccessResponse
+		var res routes.ChangePassResponse
 		err = h.ChangePassword(c)
 		assert.NoError(t, err)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
 		assert.Equal(t, "ok", res.Status)
-		assert.Equal(t, nil, res.Data)
+		assert.NotEqual(t, token, res.Data.Token)
+		_, err = tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		_, err = tStore.ForToken(token)
+		assert.Error(t, err)
+	})
+}
+
+func TestChangePassword_Hardened(t *testing.T) {
+	setup := func(t *testing.T, body string) (*routes.RouteHandler, echo.Context, *httptest.ResponseRecorder, *memory.TokenStore) {
+		t.Helper()
+
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(body, uStore, tStore)
+		h.Config.PasswordPolicy = password.Policy{MinLength: 8, DenyList: []string{"qwertyuiop"}}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1", Device: "laptop"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s2", Token: "t2", UserID: "u1", Device: "phone"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s3", Token: "t3", UserID: "u2", Device: "laptop"})
+		assert.NoError(t, err)
+		return h, c, rec, tStore
+	}
+
+	t.Run("given wrong old password then returns error and keeps sessions", func(t *testing.T) {
+		h, c, rec, tStore := setup(t, `{"token":"t1","oldPassword":"wrong","password":"password456"}`)
+
+		var res routes.ErrorResponse
+		err := h.ChangePassword(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "invalid-old-password", res.Reason)
+		assert.Equal(t, 3, len(tStore.Sessions))
+	})
+
+	t.Run("given too short password then returns error", func(t *testing.T) {
+		h, c, rec, _ := setup(t, `{"token":"t1","oldPassword":"password123","password":"short"}`)
+
+		var res routes.ErrorResponse
+		err := h.ChangePassword(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "password-too-short", res.Reason)
+	})
+
+	t.Run("given denied password then returns error", func(t *testing.T) {
+		h, c, rec, _ := setup(t, `{"token":"t1","oldPassword":"password123","password":"QWERTYUIOP"}`)
+
+		var res routes.ErrorResponse
+		err := h.ChangePassword(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "password-too-common", res.Reason)
+	})
+
+	t.Run("given valid change then ends all sessions of the user but the new one", func(t *testing.T) {
+		h, c, rec, tStore := setup(t, `{"token":"t1","oldPassword":"password123","password":"password456"}`)
+
+		var res routes.ChangePassResponse
+		err := h.ChangePassword(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		sessions, err := tStore.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(sessions))
+		assert.Equal(t, res.Data.Token, sessions[0].Token)
+		assert.Equal(t, "laptop", sessions[0].Device)
+		_, err = tStore.ForToken("t3")
+		assert.NoError(t, err)
+	})
+
+	t.Run("given valid change then keeps the API keys of the user", func(t *testing.T) {
+		h, c, rec, _ := setup(t, `{"token":"t1","oldPassword":"password123","password":"password456"}`)
+		kStore := memory.NewAPIKeyStore()
+		h.APIKeyStore = kStore
+		assert.NoError(t, kStore.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", KeyHash: "h1"}))
+		assert.NoError(t, kStore.Add(&core.APIKey{APIKeyID: "k2", UserID: "u1", KeyHash: "h2"}))
+		assert.NoError(t, kStore.Add(&core.APIKey{APIKeyID: "k3", UserID: "u2", KeyHash: "h3"}))
+
+		err := h.ChangePassword(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		keys, err := kStore.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(keys))
+		keys, err = kStore.ForUser("u2")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keys))
 	})
 }
 
 func TestValidateUser(t *testing.T) {
 	t.Run("given no token in body/header then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -377,16 +518,16 @@ func TestValidateUser(t *testing.T) {
 	})
 
 	t.Run("given invalid token in body then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s"}`, token), pStore, tStore)
+		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s"}`, token), uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(uuid.NewString())
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: uuid.NewString(), UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -400,16 +541,16 @@ func TestValidateUser(t *testing.T) {
 	})
 
 	t.Run("given invalid token in header then returns error", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", uuid.NewString())
 
@@ -424,16 +565,16 @@ func TestValidateUser(t *testing.T) {
 	})
 
 	t.Run("given valid token in body then returns success", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s"}`, token), pStore, tStore)
+		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"token":"%s"}`, token), uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ValidateUserResponse
@@ -448,16 +589,16 @@ func TestValidateUser(t *testing.T) {
 	})
 
 	t.Run("given valid token in header then returns success", func(t *testing.T) {
-		pStore := memory.NewPasswordStore()
+		uStore := memory.NewUserStore()
 		tStore := memory.NewTokenStore()
 		token := uuid.NewString()
-		h, c, rec := setupAccountTestHandler("", pStore, tStore)
+		h, c, rec := setupAccountTestHandler("", uStore, tStore)
 
 		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
 		assert.NoError(t, err)
-		err = pStore.Add(string(hash))
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
 		assert.NoError(t, err)
-		err = tStore.Add(token)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: token, UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", token)
 
@@ -472,3 +613,219 @@ func TestValidateUser(t *testing.T) {
 		assert.Equal(t, true, res.Data.Validated)
 	})
 }
+
+func TestLogin_UserName(t *testing.T) {
+	t.Run("given user name of second user then returns their token", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"userName":"bob","password":"password456"}`, uStore, tStore)
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+		hash, err = bcrypt.GenerateFromPassword([]byte("password456"), 12)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u2", UserName: "bob", Password: string(hash)})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: uuid.NewString(), UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "ok", res.Status)
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "u2", session.UserID)
+	})
+
+	t.Run("given password of another user then returns no token", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"userName":"bob","password":"password123"}`, uStore, tStore)
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), 12)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+		hash, err = bcrypt.GenerateFromPassword([]byte("password456"), 12)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u2", UserName: "bob", Password: string(hash)})
+		assert.NoError(t, err)
+
+		var res routes.LoginFailResponse
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, nil, res.Data.Token)
+	})
+}
+
+func TestValidateUser_Expiry(t *testing.T) {
+	t.Run("given expired token then returns error and removes session", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1"}`, uStore, tStore)
+
+		err := tStore.Add(&core.Session{
+			SessionID: "s1",
+			Token:     "t1",
+			UserID:    "u1",
+			ExpiresAt: time.Now().Add(-time.Minute),
+		})
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		err = h.ValidateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "auth-error", res.Reason)
+		assert.Equal(t, 0, len(tStore.Sessions))
+	})
+
+	t.Run("given unexpired token then returns success and touches session", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1"}`, uStore, tStore)
+
+		lastUsed := time.Now().Add(-time.Hour)
+		err := tStore.Add(&core.Session{
+			SessionID:  "s1",
+			Token:      "t1",
+			UserID:     "u1",
+			LastUsedAt: lastUsed,
+			ExpiresAt:  time.Now().Add(time.Hour),
+		})
+		assert.NoError(t, err)
+
+		err = h.ValidateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, tStore.Sessions[0].LastUsedAt.After(lastUsed))
+	})
+}
+
+func TestLogin_Throttle(t *testing.T) {
+	t.Run("given repeated failures then returns too many attempts", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		limiter := throttle.NewLimiter(throttle.Config{
+			PerIP: throttle.Policy{FreeAttempts: 1, BaseDelay: time.Hour, MaxDelay: time.Hour, Window: time.Hour},
+		})
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			h, c, rec := setupAccountTestHandler(`{"password":"wrong"}`, uStore, memory.NewTokenStore())
+			h.AuthLimiter = limiter
+			err = h.Login(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, uStore, memory.NewTokenStore())
+		h.AuthLimiter = limiter
+
+		var res routes.ErrorResponse
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "3600", rec.Header().Get("Retry-After"))
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "too-many-attempts", res.Reason)
+	})
+
+	t.Run("given failures from another client then logs in", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		limiter := throttle.NewLimiter(throttle.Config{
+			PerIP: throttle.Policy{FreeAttempts: 1, BaseDelay: time.Hour, MaxDelay: time.Hour, Window: time.Hour},
+		})
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			h, c, _ := setupAccountTestHandler(`{"password":"wrong"}`, uStore, memory.NewTokenStore())
+			c.Request().RemoteAddr = "192.0.2.1:1234"
+			h.AuthLimiter = limiter
+			err = h.Login(c)
+			assert.NoError(t, err)
+		}
+
+		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, uStore, memory.NewTokenStore())
+		c.Request().RemoteAddr = "192.0.2.2:1234"
+		h.AuthLimiter = limiter
+
+		var res routes.LoginSuccessResponse
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.NotEqual(t, "", res.Data.Token)
+	})
+}
+
+func TestBootstrap_Throttle(t *testing.T) {
+	t.Run("given repeated bootstrap of bootstrapped server then returns too many attempts", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		limiter := throttle.NewLimiter(throttle.Config{
+			PerIP: throttle.Policy{FreeAttempts: 1, BaseDelay: time.Hour, MaxDelay: time.Hour, Window: time.Hour},
+		})
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+
+		codes := []int{}
+		for i := 0; i < 3; i++ {
+			h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, uStore, memory.NewTokenStore())
+			h.AuthLimiter = limiter
+			err = h.Bootstrap(c)
+			assert.NoError(t, err)
+			codes = append(codes, rec.Code)
+		}
+
+		assert.Equal(t, []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusTooManyRequests}, codes)
+	})
+}
+
+func TestLogin_Rehash(t *testing.T) {
+	t.Run("given bcrypt hash then upgrades it to argon2id", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, uStore, memory.NewTokenStore())
+		hasher, err := password.NewHasher(password.Config{
+			Algorithm: password.Argon2id,
+			Argon2:    password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		})
+		assert.NoError(t, err)
+		h.Passwords = hasher
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, strings.HasPrefix(uStore.Users[0].Password, "$argon2id$"))
+		ok, needsRehash, err := hasher.Verify(uStore.Users[0].Password, "password123")
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, false, needsRehash)
+	})
+}
@@ -0,0 +1,299 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+// apiKeyContextKey holds the API key a request was authenticated with.
+const apiKeyContextKey = "apiKey"
+
+// headerToken returns the credential from the `x-actual-token` header or
+// from an `Authorization: Bearer` header.
+func headerToken(c echo.Context) core.Token {
+	header := c.Request().Header
+	if token := header.Get("x-actual-token"); token != "" {
+		return token
+	}
+
+	auth := header.Get(echo.HeaderAuthorization)
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
+
+// RequireScope lets API keys with the given scope use a route. Requests with
+// a session token pass through untouched, as sessions hold every scope.
+// Routes without this middleware cannot be used with API keys at all.
+func (it *RouteHandler) RequireScope(scope core.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := headerToken(c)
+			if !core.IsAPIKey(token) {
+				return next(c)
+			}
+
+			key, ok := it.authenticateAPIKey(c, token)
+			if !ok {
+				r := &ErrorResponse{
+					Status: "error",
+					Reason: "auth-error",
+				}
+				return c.JSON(http.StatusUnauthorized, r)
+			}
+			if !key.HasScope(scope) {
+				r := &ErrorResponse{
+					Status: "error",
+					Reason: "insufficient-scope",
+				}
+				return c.JSON(http.StatusForbidden, r)
+			}
+
+			c.Set(apiKeyContextKey, key)
+			return next(c)
+		}
+	}
+}
+
+func (it *RouteHandler) authenticateAPIKey(c echo.Context, token core.Token) (*core.APIKey, bool) {
+	if it.APIKeyStore == nil {
+		return nil, false
+	}
+	key, err := it.APIKeyStore.ForHash(core.HashAPIKey(token))
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, false
+	}
+	if now.Sub(key.LastUsedAt) >= sessionTouchInterval {
+		err = it.APIKeyStore.Touch(key.APIKeyID, now)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+		}
+		key.LastUsedAt = now
+	}
+	return key, true
+}
+
+type APIKeyResponseData struct {
+	APIKeyID   core.APIKeyID `json:"id"`
+	Name       string        `json:"name"`
+	Scopes     []core.Scope  `json:"scopes"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt"`
+	ExpiresAt  *time.Time    `json:"expiresAt"`
+}
+
+func newAPIKeyResponseData(key *core.APIKey) APIKeyResponseData {
+	data := APIKeyResponseData{
+		APIKeyID:  key.APIKeyID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.LastUsedAt.IsZero() {
+		lastUsedAt := key.LastUsedAt
+		data.LastUsedAt = &lastUsedAt
+	}
+	if !key.ExpiresAt.IsZero() {
+		expiresAt := key.ExpiresAt
+		data.ExpiresAt = &expiresAt
+	}
+	return data
+}
+
+type ListAPIKeysResponse struct {
+	SuccessResponse
+	Data []APIKeyResponseData `json:"data"`
+}
+
+func (it *RouteHandler) ListAPIKeys(c echo.Context) error {
+	req := new(TokenRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	keys, err := it.APIKeyStore.ForUser(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	keysRes := make([]APIKeyResponseData, 0, len(keys))
+	for _, key := range keys {
+		keysRes = append(keysRes, newAPIKeyResponseData(key))
+	}
+
+	r := &ListAPIKeysResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            keysRes,
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type CreateAPIKeyRequestBody struct {
+	Token     core.Token   `json:"token"`
+	Name      string       `json:"name"`
+	Scopes    []core.Scope `json:"scopes"`
+	ExpiresAt *time.Time   `json:"expiresAt"`
+}
+
+type CreateAPIKeyData struct {
+	APIKeyResponseData
+	// Key is only ever returned here, the server keeps just its hash.
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyResponse struct {
+	SuccessResponse
+	Data CreateAPIKeyData `json:"data"`
+}
+
+func (it *RouteHandler) CreateAPIKey(c echo.Context) error {
+	req := new(CreateAPIKeyRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-name",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+	if len(req.Scopes) == 0 {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-scope",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+	for _, scope := range req.Scopes {
+		if !core.ValidScope(scope) {
+			r := &ErrorResponse{
+				Status: "error",
+				Reason: "invalid-scope",
+			}
+			return c.JSON(http.StatusBadRequest, r)
+		}
+		if scope == core.ScopeAdmin {
+			admin, err := it.isAdmin(userID)
+			if err != nil {
+				c.Echo().Logger.Error(err)
+				return err
+			}
+			if !admin {
+				r := &ErrorResponse{
+					Status: "error",
+					Reason: "permission-denied",
+				}
+				return c.JSON(http.StatusForbidden, r)
+			}
+		}
+	}
+
+	secret, err := core.GenerateAPIKey()
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	key := &core.APIKey{
+		APIKeyID:  uuid.NewString(),
+		UserID:    userID,
+		Name:      req.Name,
+		KeyHash:   core.HashAPIKey(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpiresAt != nil {
+		key.ExpiresAt = *req.ExpiresAt
+	}
+	err = it.APIKeyStore.Add(key)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	r := &CreateAPIKeyResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data: CreateAPIKeyData{
+			APIKeyResponseData: newAPIKeyResponseData(key),
+			Key:                secret,
+		},
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type RevokeAPIKeyRequestBody struct {
+	Token    core.Token    `json:"token"`
+	APIKeyID core.APIKeyID `json:"id"`
+}
+
+func (it *RouteHandler) RevokeAPIKey(c echo.Context) error {
+	req := new(RevokeAPIKeyRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	keys, err := it.APIKeyStore.ForUser(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	for _, key := range keys {
+		if key.APIKeyID != req.APIKeyID {
+			continue
+		}
+
+		err = it.APIKeyStore.Delete(key.APIKeyID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		r := &SuccessResponse{Status: "ok"}
+		return c.JSON(http.StatusOK, r)
+	}
+
+	r := &ErrorResponse{
+		Status: "error",
+		Reason: "api-key-not-found",
+	}
+	return c.JSON(http.StatusBadRequest, r)
+}
@@ -0,0 +1,291 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupScopedTestServer registers the sync routes with the same scopes as
+// the server does.
+func setupScopedTestServer(t *testing.T) (*echo.Echo, *routes.RouteHandler) {
+	t.Helper()
+
+	db, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	h := &routes.RouteHandler{
+		Config: core.Config{
+			Mode:       core.Development,
+			FileSystem: afero.NewMemMapFs(),
+			UserFiles:  "",
+		},
+		FileStore:   sqlite.NewFileStore(db),
+		UserStore:   sqlite.NewUserStore(db),
+		TokenStore:  sqlite.NewTokenStore(db),
+		APIKeyStore: sqlite.NewAPIKeyStore(db),
+	}
+
+	e := echo.New()
+	account := e.Group("/account")
+	account.GET("/sessions", h.ListSessions)
+	account.GET("/list-users", h.ListUsers, h.RequireScope(core.ScopeAdmin))
+
+	sync := e.Group("/sync")
+	filesRead := h.RequireScope(core.ScopeFilesRead)
+	filesWrite := h.RequireScope(core.ScopeFilesWrite)
+	sync.POST("/sync", h.SyncFile, h.RequireScope(core.ScopeSync))
+	sync.GET("/list-user-files", h.ListUserFiles, filesRead)
+	sync.GET("/download-user-file", h.DownloadUserFile, filesRead)
+	sync.POST("/upload-user-file", h.UploadUserFile, filesWrite)
+	sync.POST("/delete-user-file", h.DeleteUserFile, filesWrite)
+
+	err = h.FileStore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u1"})
+	assert.NoError(t, err)
+	err = afero.WriteFile(h.Config.FileSystem, "f1.blob", []byte("testing"), 0o644)
+	assert.NoError(t, err)
+
+	return e, h
+}
+
+func addTestAPIKey(t *testing.T, store core.APIKeyStore, userID core.UserID, scopes ...core.Scope) string {
+	t.Helper()
+
+	secret, err := core.GenerateAPIKey()
+	assert.NoError(t, err)
+	err = store.Add(&core.APIKey{
+		APIKeyID:  secret[len(secret)-8:],
+		UserID:    userID,
+		Name:      "script",
+		KeyHash:   core.HashAPIKey(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	})
+	assert.NoError(t, err)
+	return secret
+}
+
+func serveWithKey(e *echo.Echo, method, target, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+key)
+	req.Header.Set("x-actual-file-id", "f1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireScope(t *testing.T) {
+	t.Run("given read only key then reads files", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		key := addTestAPIKey(t, h.APIKeyStore, "u1", core.ScopeFilesRead)
+
+		rec := serveWithKey(e, http.MethodGet, "/sync/list-user-files", "", key)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var res routes.ListFilesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 1, len(res.Data))
+
+		rec = serveWithKey(e, http.MethodGet, "/sync/download-user-file", "", key)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "testing", rec.Body.String())
+	})
+
+	t.Run("given read only key then writes and syncs are forbidden", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		key := addTestAPIKey(t, h.APIKeyStore, "u1", core.ScopeFilesRead)
+
+		for _, target := range []string{"/sync/upload-user-file", "/sync/delete-user-file", "/sync/sync"} {
+			rec := serveWithKey(e, http.MethodPost, target, `{"fileId":"f1"}`, key)
+
+			var res routes.ErrorResponse
+			assert.Equal(t, http.StatusForbidden, rec.Code, target)
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, "insufficient-scope", res.Reason)
+		}
+
+		f, err := h.FileStore.ForID("f1")
+		assert.NoError(t, err)
+		assert.Equal(t, false, f.Deleted)
+	})
+
+	t.Run("given write key then deletes file", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		key := addTestAPIKey(t, h.APIKeyStore, "u1", core.ScopeFilesWrite)
+
+		rec := serveWithKey(e, http.MethodPost, "/sync/delete-user-file", `{"fileId":"f1"}`, key)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		f, err := h.FileStore.ForID("f1")
+		assert.NoError(t, err)
+		assert.Equal(t, true, f.Deleted)
+	})
+
+	t.Run("given key of another user then does not see files", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		key := addTestAPIKey(t, h.APIKeyStore, "u2", core.ScopeFilesRead)
+
+		rec := serveWithKey(e, http.MethodGet, "/sync/list-user-files", "", key)
+
+		var res routes.ListFilesResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 0, len(res.Data))
+	})
+
+	t.Run("given unknown key then returns auth error", func(t *testing.T) {
+		e, _ := setupScopedTestServer(t)
+
+		rec := serveWithKey(e, http.MethodGet, "/sync/list-user-files", "", core.APIKeyPrefix+"unknown")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("given expired key then returns auth error", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		key := addTestAPIKey(t, h.APIKeyStore, "u1", core.ScopeFilesRead)
+		keys, err := h.APIKeyStore.ForUser("u1")
+		assert.NoError(t, err)
+		assert.NoError(t, h.APIKeyStore.Delete(keys[0].APIKeyID))
+		keys[0].ExpiresAt = time.Now().Add(-time.Minute)
+		assert.NoError(t, h.APIKeyStore.Add(keys[0]))
+
+		rec := serveWithKey(e, http.MethodGet, "/sync/list-user-files", "", key)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("given admin key then it cannot use unscoped routes", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		key := addTestAPIKey(t, h.APIKeyStore, "u1", core.ScopeAdmin)
+
+		rec := serveWithKey(e, http.MethodGet, "/account/sessions", "", key)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("given session token as bearer then passes", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		err := h.TokenStore.Add(&core.Session{SessionID: "s1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+
+		rec := serveWithKey(e, http.MethodPost, "/sync/delete-user-file", `{"fileId":"f1"}`, "token123")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	t.Run("given invalid scope then returns error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","name":"backup","scopes":["files:delete"]}`, uStore, tStore)
+		h.APIKeyStore = memory.NewAPIKeyStore()
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		err = h.CreateAPIKey(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "invalid-scope", res.Reason)
+	})
+
+	t.Run("given admin scope for non admin then returns error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","name":"backup","scopes":["admin"]}`, uStore, tStore)
+		h.APIKeyStore = memory.NewAPIKeyStore()
+
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "user", Password: "hash"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+
+		err = h.CreateAPIKey(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("given valid request then returns key and stores only its hash", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		kStore := memory.NewAPIKeyStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","name":"backup","scopes":["files:read"]}`, uStore, tStore)
+		h.APIKeyStore = kStore
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.CreateAPIKeyResponse
+		err = h.CreateAPIKey(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.True(t, strings.HasPrefix(res.Data.Key, core.APIKeyPrefix))
+		assert.Equal(t, []core.Scope{core.ScopeFilesRead}, res.Data.Scopes)
+		assert.Equal(t, 1, len(kStore.Keys))
+		assert.Equal(t, core.HashAPIKey(res.Data.Key), kStore.Keys[0].KeyHash)
+		assert.Equal(t, "u1", kStore.Keys[0].UserID)
+	})
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	t.Run("given key of another user then returns error", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		kStore := memory.NewAPIKeyStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","id":"k1"}`, nil, tStore)
+		h.APIKeyStore = kStore
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+		err = kStore.Add(&core.APIKey{APIKeyID: "k1", UserID: "u2", KeyHash: "h1"})
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		err = h.RevokeAPIKey(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "api-key-not-found", res.Reason)
+		assert.Equal(t, 1, len(kStore.Keys))
+	})
+
+	t.Run("given own key then revokes it", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		kStore := memory.NewAPIKeyStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","id":"k1"}`, nil, tStore)
+		h.APIKeyStore = kStore
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+		err = kStore.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", KeyHash: "h1"})
+		assert.NoError(t, err)
+
+		err = h.RevokeAPIKey(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 0, len(kStore.Keys))
+	})
+}
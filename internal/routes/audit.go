@@ -0,0 +1,141 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+// audit records an event in the audit log. The user and session are taken
+// from the request's session or API key unless set on the entry. Failing to
+// write the entry is logged but does not fail the request.
+func (it *RouteHandler) audit(c echo.Context, entry *core.AuditEntry) {
+	if it.AuditStore == nil {
+		return
+	}
+
+	entry.Timestamp = time.Now()
+	entry.ClientIP = c.RealIP()
+	if session, ok := c.Get(sessionContextKey).(*core.Session); ok {
+		if entry.UserID == "" {
+			entry.UserID = session.UserID
+		}
+		if entry.SessionID == "" {
+			entry.SessionID = session.SessionID
+		}
+	}
+	if key, ok := c.Get(apiKeyContextKey).(*core.APIKey); ok && entry.UserID == "" {
+		entry.UserID = key.UserID
+	}
+
+	if err := it.AuditStore.Add(entry); err != nil {
+		c.Echo().Logger.Error(err)
+	}
+}
+
+type AuditEntryResponseData struct {
+	ID        core.AuditEntryID `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	Event     core.AuditEvent   `json:"event"`
+	Outcome   core.AuditOutcome `json:"outcome"`
+	UserID    core.UserID       `json:"userId"`
+	ClientIP  string            `json:"clientIp"`
+	SessionID core.SessionID    `json:"sessionId"`
+	FileID    core.FileID       `json:"fileId"`
+}
+
+type AuditLogData struct {
+	Entries []AuditEntryResponseData `json:"entries"`
+	// Before is passed as `before` to fetch the next page. It is null on
+	// the last page.
+	Before *core.AuditEntryID `json:"before"`
+}
+
+type AuditLogResponse struct {
+	SuccessResponse
+	Data AuditLogData `json:"data"`
+}
+
+// ListAuditLog returns audit log entries newest first, one page at a time.
+// The `limit` query parameter sets the page size, which is clamped to
+// core.MaxAuditPageSize, and `before` continues after the previous page.
+func (it *RouteHandler) ListAuditLog(c echo.Context) error {
+	userID, val := it.authenticateUser(c, "")
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	admin, err := it.isAdmin(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if !admin {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "permission-denied",
+		}
+		return c.JSON(http.StatusForbidden, r)
+	}
+
+	limit := 0
+	if param := c.QueryParam("limit"); param != "" {
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit < 0 {
+			r := &ErrorResponse{
+				Status: "error",
+				Reason: "invalid-limit",
+			}
+			return c.JSON(http.StatusBadRequest, r)
+		}
+	}
+	limit = core.AuditPageSize(limit)
+	var before core.AuditEntryID
+	if param := c.QueryParam("before"); param != "" {
+		before, err = strconv.ParseInt(param, 10, 64)
+		if err != nil || before <= 0 {
+			r := &ErrorResponse{
+				Status: "error",
+				Reason: "invalid-before",
+			}
+			return c.JSON(http.StatusBadRequest, r)
+		}
+	}
+
+	entries, err := it.AuditStore.Page(before, limit)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	data := AuditLogData{Entries: make([]AuditEntryResponseData, 0, len(entries))}
+	for _, e := range entries {
+		data.Entries = append(data.Entries, AuditEntryResponseData{
+			ID:        e.AuditEntryID,
+			Timestamp: e.Timestamp,
+			Event:     e.Event,
+			Outcome:   e.Outcome,
+			UserID:    e.UserID,
+			ClientIP:  e.ClientIP,
+			SessionID: e.SessionID,
+			FileID:    e.FileID,
+		})
+	}
+	if len(entries) == limit {
+		next := entries[len(entries)-1].AuditEntryID
+		data.Before = &next
+	}
+
+	r := &AuditLogResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            data,
+	}
+	return c.JSON(http.StatusOK, r)
+}
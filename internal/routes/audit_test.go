@@ -0,0 +1,195 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLogin_Audit(t *testing.T) {
+	t.Run("given failed and successful login then records both", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		aStore := memory.NewAuditStore()
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+		assert.NoError(t, err)
+
+		h, c, _ := setupAccountTestHandler(`{"password":"wrong"}`, uStore, tStore)
+		h.AuditStore = aStore
+		c.Request().RemoteAddr = "10.0.0.1:1234"
+		assert.NoError(t, h.Login(c))
+
+		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, uStore, tStore)
+		h.AuditStore = aStore
+		c.Request().RemoteAddr = "10.0.0.2:1234"
+		assert.NoError(t, h.Login(c))
+
+		var res routes.LoginSuccessResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, len(aStore.Entries))
+		failed, succeeded := aStore.Entries[0], aStore.Entries[1]
+		assert.Equal(t, core.AuditLogin, failed.Event)
+		assert.Equal(t, core.AuditFailure, failed.Outcome)
+		assert.Equal(t, "u1", failed.UserID)
+		assert.Equal(t, "10.0.0.1", failed.ClientIP)
+		assert.Equal(t, "", failed.SessionID)
+		assert.Equal(t, core.AuditLogin, succeeded.Event)
+		assert.Equal(t, core.AuditSuccess, succeeded.Outcome)
+		assert.Equal(t, "u1", succeeded.UserID)
+		assert.Equal(t, "10.0.0.2", succeeded.ClientIP)
+		assert.Equal(t, session.SessionID, succeeded.SessionID)
+		assert.False(t, succeeded.Timestamp.IsZero())
+	})
+}
+
+func TestDeleteUserFile_Audit(t *testing.T) {
+	t.Run("given delete with session then records file and session", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		aStore := memory.NewAuditStore()
+		h.AuditStore = aStore
+		err := h.TokenStore.Add(&core.Session{SessionID: "s1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+
+		rec := serveWithKey(e, http.MethodPost, "/sync/delete-user-file", `{"fileId":"f1"}`, "token123")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, len(aStore.Entries))
+		assert.Equal(t, core.AuditDeleteFile, aStore.Entries[0].Event)
+		assert.Equal(t, core.AuditSuccess, aStore.Entries[0].Outcome)
+		assert.Equal(t, "u1", aStore.Entries[0].UserID)
+		assert.Equal(t, "s1", aStore.Entries[0].SessionID)
+		assert.Equal(t, "f1", aStore.Entries[0].FileID)
+	})
+
+	t.Run("given file of another user then records failure", func(t *testing.T) {
+		e, h := setupScopedTestServer(t)
+		aStore := memory.NewAuditStore()
+		h.AuditStore = aStore
+		err := h.TokenStore.Add(&core.Session{SessionID: "s2", Token: "token456", UserID: "u2"})
+		assert.NoError(t, err)
+
+		rec := serveWithKey(e, http.MethodPost, "/sync/delete-user-file", `{"fileId":"f1"}`, "token456")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, 1, len(aStore.Entries))
+		assert.Equal(t, core.AuditFailure, aStore.Entries[0].Outcome)
+		assert.Equal(t, "u2", aStore.Entries[0].UserID)
+	})
+}
+
+func TestListAuditLog(t *testing.T) {
+	setup := func(t *testing.T) (*memory.AuditStore, func(target, token string) (int, []byte)) {
+		t.Helper()
+
+		e, h := setupScopedTestServer(t)
+		aStore := memory.NewAuditStore()
+		h.AuditStore = aStore
+		e.GET("/admin/audit", h.ListAuditLog, h.RequireScope(core.ScopeAdmin))
+
+		err := h.UserStore.Add(&core.User{UserID: "u1", UserName: "admin", IsAdmin: true})
+		assert.NoError(t, err)
+		err = h.UserStore.Add(&core.User{UserID: "u2", UserName: "bob"})
+		assert.NoError(t, err)
+		err = h.TokenStore.Add(&core.Session{SessionID: "s1", Token: "admin-token", UserID: "u1"})
+		assert.NoError(t, err)
+		err = h.TokenStore.Add(&core.Session{SessionID: "s2", Token: "bob-token", UserID: "u2"})
+		assert.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			err = aStore.Add(&core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditSuccess, UserID: "u1"})
+			assert.NoError(t, err)
+		}
+
+		return aStore, func(target, token string) (int, []byte) {
+			rec := serveWithKey(e, http.MethodGet, target, "", token)
+			return rec.Code, rec.Body.Bytes()
+		}
+	}
+
+	t.Run("given non admin then returns permission-denied", func(t *testing.T) {
+		_, get := setup(t)
+
+		code, body := get("/admin/audit", "bob-token")
+
+		var res routes.ErrorResponse
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.NoError(t, json.Unmarshal(body, &res))
+		assert.Equal(t, "permission-denied", res.Reason)
+	})
+
+	t.Run("given admin then pages through entries", func(t *testing.T) {
+		_, get := setup(t)
+
+		code, body := get("/admin/audit?limit=2", "admin-token")
+		var first routes.AuditLogResponse
+		assert.Equal(t, http.StatusOK, code)
+		assert.NoError(t, json.Unmarshal(body, &first))
+		assert.Equal(t, 2, len(first.Data.Entries))
+		assert.Equal(t, int64(3), first.Data.Entries[0].ID)
+		assert.Equal(t, int64(2), *first.Data.Before)
+
+		code, body = get("/admin/audit?limit=2&before=2", "admin-token")
+		var last routes.AuditLogResponse
+		assert.Equal(t, http.StatusOK, code)
+		assert.NoError(t, json.Unmarshal(body, &last))
+		assert.Equal(t, 1, len(last.Data.Entries))
+		assert.Equal(t, int64(1), last.Data.Entries[0].ID)
+		assert.Nil(t, last.Data.Before)
+	})
+
+	t.Run("given no limit then returns a page of the default size", func(t *testing.T) {
+		aStore, get := setup(t)
+		for i := 0; i < core.DefaultAuditPageSize; i++ {
+			err := aStore.Add(&core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditSuccess, UserID: "u1"})
+			assert.NoError(t, err)
+		}
+
+		for _, target := range []string{"/admin/audit", "/admin/audit?limit=0"} {
+			code, body := get(target, "admin-token")
+
+			var res routes.AuditLogResponse
+			assert.Equal(t, http.StatusOK, code)
+			assert.NoError(t, json.Unmarshal(body, &res))
+			assert.Equal(t, core.DefaultAuditPageSize, len(res.Data.Entries))
+			assert.NotNil(t, res.Data.Before)
+		}
+	})
+
+	t.Run("given limit above the maximum then returns a page of the maximum size", func(t *testing.T) {
+		aStore, get := setup(t)
+		for i := 0; i < core.MaxAuditPageSize; i++ {
+			err := aStore.Add(&core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditSuccess, UserID: "u1"})
+			assert.NoError(t, err)
+		}
+
+		code, body := get(fmt.Sprintf("/admin/audit?limit=%d", core.MaxAuditPageSize+1), "admin-token")
+
+		var res routes.AuditLogResponse
+		assert.Equal(t, http.StatusOK, code)
+		assert.NoError(t, json.Unmarshal(body, &res))
+		assert.Equal(t, core.MaxAuditPageSize, len(res.Data.Entries))
+	})
+
+	t.Run("given invalid limit then returns error", func(t *testing.T) {
+		_, get := setup(t)
+
+		code, body := get("/admin/audit?limit=-1", "admin-token")
+
+		var res routes.ErrorResponse
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.NoError(t, json.Unmarshal(body, &res))
+		assert.Equal(t, "invalid-limit", res.Reason)
+	})
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core/events"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// eventsKeepAlive is how often idle event streams get a comment. It keeps
+// proxies from closing them and notices clients that went away.
+const eventsKeepAlive = 30 * time.Second
+
+// FileEvents streams changes of a file as server-sent events until the client
+// disconnects. The session token may also be passed as the `token` query
+// parameter, only because EventSource cannot set headers. Request logs leave
+// out the query for that reason.
+func (it *RouteHandler) FileEvents(c echo.Context) error {
+	userID, val := it.authenticateUser(c, c.QueryParam("token"))
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	fileID := c.QueryParam("fileId")
+	_, err := it.userFile(fileID, userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			return c.String(http.StatusBadRequest, "file-not-found")
+		}
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if it.Events == nil {
+		return echo.ErrNotFound
+	}
+
+	sub := it.Events.Subscribe(fileID)
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ticker := time.NewTicker(eventsKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case event, ok := <-sub.Events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintf(res, "event: file-changed\ndata: %s\n\n", data); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if _, err = fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+// publish notifies subscribers of a file about a change, if events are
+// enabled.
+func (it *RouteHandler) publish(event events.Event) {
+	if it.Events != nil {
+		it.Events.Publish(event)
+	}
+}
@@ -0,0 +1,116 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/events"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupEventsTestServer(t *testing.T) (*routes.RouteHandler, *httptest.Server) {
+	t.Helper()
+
+	db, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	tstore := sqlite.NewTokenStore(db)
+	fstore := sqlite.NewFileStore(db)
+	err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+	assert.NoError(t, err)
+	err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u1"})
+	assert.NoError(t, err)
+
+	h := &routes.RouteHandler{
+		Config:     core.Config{Mode: core.Development},
+		FileStore:  fstore,
+		TokenStore: tstore,
+		Events:     events.NewHub(),
+	}
+	e := echo.New()
+	e.GET("/sync/events", h.FileEvents)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return h, server
+}
+
+func waitForSubscribers(t *testing.T, h *routes.RouteHandler, fileID string, n int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		return h.Events.Subscribers(fileID) == n
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFileEvents(t *testing.T) {
+	t.Run("given subscriber then streams file events and cleans up on disconnect", func(t *testing.T) {
+		h, server := setupEventsTestServer(t)
+
+		res, err := http.Get(server.URL + "/sync/events?fileId=f1&token=token123")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get(echo.HeaderContentType))
+		waitForSubscribers(t, h, "f1", 1)
+
+		h.Events.Publish(events.Event{Type: events.TypeSync, FileID: "f1", GroupID: "g1", Timestamp: "ts"})
+
+		reader := bufio.NewReader(res.Body)
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "event: file-changed\n", line)
+		line, err = reader.ReadString('\n')
+		assert.NoError(t, err)
+		var event events.Event
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		assert.Equal(t, events.Event{Type: events.TypeSync, FileID: "f1", GroupID: "g1", Timestamp: "ts"}, event)
+
+		res.Body.Close()
+		waitForSubscribers(t, h, "f1", 0)
+	})
+
+	t.Run("given file of another user then returns file-not-found", func(t *testing.T) {
+		_, server := setupEventsTestServer(t)
+
+		res, err := http.Get(server.URL + "/sync/events?fileId=f2&token=token123")
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("given invalid token then returns auth-error", func(t *testing.T) {
+		_, server := setupEventsTestServer(t)
+
+		res, err := http.Get(server.URL + "/sync/events?fileId=f1&token=token456")
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
+
+func TestDeleteUserFile_PublishesEvent(t *testing.T) {
+	t.Run("given subscriber then delete notifies it", func(t *testing.T) {
+		h, _ := setupEventsTestServer(t)
+		sub := h.Events.Subscribe("f1")
+		defer sub.Close()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"token":"token123","fileId":"f1"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		err := h.DeleteUserFile(e.NewContext(req, rec))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, events.Event{Type: events.TypeDelete, FileID: "f1", GroupID: "g1"}, <-sub.Events)
+	})
+}
@@ -2,13 +2,43 @@ package routes
 
 import (
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/events"
+	"github.com/nathanjisaac/actual-server-go/internal/core/lock"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	"github.com/nathanjisaac/actual-server-go/internal/core/throttle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/upload"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
 )
 
 type RouteHandler struct {
-	Config        core.Config
-	FileStore     core.FileStore
-	PasswordStore core.PasswordStore
-	TokenStore    core.TokenStore
+	Config         core.Config
+	FileStore      core.FileStore
+	UserStore      core.UserStore
+	TokenStore     core.TokenStore
+	APIKeyStore    core.APIKeyStore
+	TwoFactorStore core.TwoFactorStore
+	// AuditStore records security events. Nil disables the audit log.
+	AuditStore core.AuditStore
+	// OpenID is nil unless OpenID Connect login is configured.
+	OpenID *openid.Client
+	// AuthLimiter throttles failed authentication attempts. Nil disables
+	// throttling.
+	AuthLimiter *throttle.Limiter
+	// Passwords hashes and verifies passwords. Nil uses the default argon2id
+	// hasher.
+	Passwords core.PasswordHasher
+	// Events notifies clients about changed files. Nil disables the events
+	// endpoint.
+	Events *events.Hub
+	// Groups keeps the message databases of recently synced files open. Nil
+	// opens them for every request.
+	Groups *storage.GroupManager
+	// FileLocks serializes the sync writes of each file. Nil leaves them to
+	// the locking of the database.
+	FileLocks *lock.KeyedMutex
+	// Uploads keeps the chunked uploads in progress. Nil disables chunked
+	// uploads.
+	Uploads *upload.Sessions
 }
 
 type ErrorResponse struct {
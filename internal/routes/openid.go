@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// defaultOpenIDReturnURL is where the web app picks up the token after an
+// OpenID login.
+const defaultOpenIDReturnURL = "/openid-cb"
+
+// loginMethods lists the login methods enabled on this server.
+func (it *RouteHandler) loginMethods() []string {
+	methods := []string{"password"}
+	if it.OpenID != nil {
+		methods = append(methods, "openid")
+	}
+	if it.Config.ProxyAuth.Enabled {
+		methods = append(methods, "header")
+	}
+	return methods
+}
+
+// OpenIDLogin redirects the browser to the issuer. The optional `returnUrl`
+// query parameter must be a path on this server.
+func (it *RouteHandler) OpenIDLogin(c echo.Context) error {
+	if it.OpenID == nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "openid-disabled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	returnURL := c.QueryParam("returnUrl")
+	if returnURL == "" {
+		returnURL = defaultOpenIDReturnURL
+	}
+	if !isLocalPath(returnURL) {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-return-url",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	authURL, err := it.OpenID.AuthURL(c.Request().Context(), returnURL)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "openid-provider-error",
+		}
+		return c.JSON(http.StatusBadGateway, r)
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OpenIDCallback completes the login, issues a session for the user the
+// identity maps to and redirects back to the web app with its token.
+func (it *RouteHandler) OpenIDCallback(c echo.Context) error {
+	if it.OpenID == nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "openid-disabled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	if c.QueryParam("error") != "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "openid-denied",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	identity, returnURL, err := it.OpenID.Exchange(c.Request().Context(), c.QueryParam("state"), c.QueryParam("code"))
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		reason := "openid-error"
+		if errors.Is(err, internal_errors.ErrOpenIDInvalidState) {
+			reason = "invalid-state"
+		}
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: reason,
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	if !it.OpenID.Allowed(identity) {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "permission-denied",
+		}
+		return c.JSON(http.StatusForbidden, r)
+	}
+
+	user, err := it.openIDUser(identity)
+	if errors.Is(err, internal_errors.ErrUserNameTaken) {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "user-exists",
+		}
+		return c.JSON(http.StatusForbidden, r)
+	} else if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	redirect, err := url.Parse(returnURL)
+	if err != nil {
+		return err
+	}
+	query := redirect.Query()
+
+	// Users with 2FA get a token to finish the login with their code
+	// instead of a session.
+	tf, err := it.enabledTwoFactor(user.UserID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if tf != nil {
+		token, err := it.OpenID.Hold(user.UserID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		query.Set("twoFactorToken", token)
+	} else {
+		session, err := it.newSession(c, user.UserID, "openid")
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		query.Set("token", session.Token)
+	}
+	redirect.RawQuery = query.Encode()
+	return c.Redirect(http.StatusFound, redirect.String())
+}
+
+type OpenIDTwoFactorRequestBody struct {
+	TwoFactorToken string `json:"twoFactorToken"`
+	Code           string `json:"code"`
+}
+
+// OpenIDTwoFactor finishes an OpenID login of a user with 2FA, given the
+// token handed out by the callback and a current or recovery code.
+func (it *RouteHandler) OpenIDTwoFactor(c echo.Context) error {
+	if it.OpenID == nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "openid-disabled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	req := new(OpenIDTwoFactorRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	if wait, ok := it.allowAttempt(c); !ok {
+		return it.tooManyAttempts(c, wait)
+	}
+	defer it.attemptEnded(c)
+
+	userID, ok := it.OpenID.Held(req.TwoFactorToken)
+	if !ok {
+		it.attemptFailed(c)
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-state",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+	tf, err := it.enabledTwoFactor(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if tf != nil {
+		if ok, err := it.checkTwoFactor(c, tf, req.Code); !ok {
+			return err
+		}
+	}
+	it.OpenID.Release(req.TwoFactorToken)
+	it.attemptSucceeded(c)
+
+	session, err := it.newSession(c, userID, "openid")
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	it.audit(c, &core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditSuccess})
+	r := &LoginSuccessResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            LoginSuccessData{Token: session.Token},
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// openIDUser returns the user linked to an identity, provisioning it on first
+// login. New users are named by verified email or else by subject.
+func (it *RouteHandler) openIDUser(identity *openid.Identity) (*core.User, error) {
+	userName := identity.Subject
+	if identity.EmailVerified && identity.Email != "" {
+		userName = strings.ToLower(identity.Email)
+	}
+
+	return it.linkedUser(identity.ExternalID(), userName)
+}
+
+func isLocalPath(s string) bool {
+	return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/\\")
+}
@@ -0,0 +1,285 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid/openidtest"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupOpenIDTestHandler(t *testing.T, provider *openidtest.Provider) (
+	*routes.RouteHandler,
+	*memory.UserStore,
+	*memory.TokenStore,
+) {
+	t.Helper()
+
+	uStore := memory.NewUserStore()
+	tStore := memory.NewTokenStore()
+	h := &routes.RouteHandler{
+		Config:     core.Config{Mode: core.Development},
+		UserStore:  uStore,
+		TokenStore: tStore,
+		OpenID: openid.NewClient(openid.Config{
+			Issuer:        provider.Issuer(),
+			ClientID:      openidtest.ClientID,
+			ClientSecret:  openidtest.ClientSecret,
+			RedirectURL:   "http://localhost:5006/openid/callback",
+			AllowedEmails: []string{"user@example.com"},
+		}, nil),
+	}
+	return h, uStore, tStore
+}
+
+func openIDTwoFactorRequest(body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/openid/2fa", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func openIDRequest(target string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+// openIDLogin starts a login through the handler and returns the callback
+// request the issuer redirects the browser to.
+func openIDLogin(t *testing.T, h *routes.RouteHandler, provider *openidtest.Provider, target string) (
+	echo.Context,
+	*httptest.ResponseRecorder,
+) {
+	t.Helper()
+
+	c, rec := openIDRequest(target)
+	err := h.OpenIDLogin(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	callback := provider.Authorize(t, rec.Header().Get(echo.HeaderLocation))
+	return openIDRequest(callback.RequestURI())
+}
+
+func TestOpenIDLogin(t *testing.T) {
+	t.Run("given openid disabled then returns error", func(t *testing.T) {
+		h := &routes.RouteHandler{}
+		c, rec := openIDRequest("/openid/login")
+
+		var res routes.ErrorResponse
+		err := h.OpenIDLogin(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "openid-disabled", res.Reason)
+	})
+
+	t.Run("given external return url then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, _, _ := setupOpenIDTestHandler(t, provider)
+		c, rec := openIDRequest("/openid/login?returnUrl=//evil.example.com/")
+
+		var res routes.ErrorResponse
+		err := h.OpenIDLogin(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "invalid-return-url", res.Reason)
+	})
+
+	t.Run("given openid enabled then redirects to issuer", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, _, _ := setupOpenIDTestHandler(t, provider)
+		c, rec := openIDRequest("/openid/login")
+
+		err := h.OpenIDLogin(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Contains(t, rec.Header().Get(echo.HeaderLocation), provider.Issuer()+"/authorize?")
+	})
+}
+
+func TestOpenIDCallback(t *testing.T) {
+	t.Run("given first login then provisions linked user and redirects with token", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, uStore, tStore := setupOpenIDTestHandler(t, provider)
+		c, rec := openIDLogin(t, h, provider, "/openid/login?returnUrl=/done")
+
+		err := h.OpenIDCallback(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		location, err := rec.Result().Location()
+		assert.NoError(t, err)
+		assert.Equal(t, "/done", location.Path)
+
+		session, err := tStore.ForToken(location.Query().Get("token"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(uStore.Users))
+		assert.Equal(t, uStore.Users[0].UserID, session.UserID)
+		assert.Equal(t, "user@example.com", uStore.Users[0].UserName)
+		assert.Equal(t, "openid:"+provider.Issuer()+"#subject-1", uStore.Users[0].ExternalID)
+		assert.Equal(t, false, uStore.Users[0].IsAdmin)
+	})
+
+	t.Run("given linked user then issues session for that user", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, uStore, tStore := setupOpenIDTestHandler(t, provider)
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u2", UserName: "alice", ExternalID: "openid:" + provider.Issuer() + "#subject-1"})
+		assert.NoError(t, err)
+		c, rec := openIDLogin(t, h, provider, "/openid/login")
+
+		err = h.OpenIDCallback(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		location, err := rec.Result().Location()
+		assert.NoError(t, err)
+		assert.Equal(t, "/openid-cb", location.Path)
+
+		session, err := tStore.ForToken(location.Query().Get("token"))
+		assert.NoError(t, err)
+		assert.Equal(t, "u2", session.UserID)
+		assert.Equal(t, 2, len(uStore.Users))
+	})
+
+	t.Run("given name of a password user then refuses to sign in as that user", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		provider.Claims["email"] = "Admin"
+		h, uStore, tStore := setupOpenIDTestHandler(t, provider)
+		h.OpenID = openid.NewClient(openid.Config{
+			Issuer:          provider.Issuer(),
+			ClientID:        openidtest.ClientID,
+			ClientSecret:    openidtest.ClientSecret,
+			RedirectURL:     "http://localhost:5006/openid/callback",
+			AllowedSubjects: []string{"subject-1"},
+		}, nil)
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+		c, rec := openIDLogin(t, h, provider, "/openid/login")
+
+		var res routes.ErrorResponse
+		err = h.OpenIDCallback(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "user-exists", res.Reason)
+		assert.Equal(t, 1, len(uStore.Users))
+		assert.Equal(t, 0, len(tStore.Sessions))
+	})
+
+	t.Run("given user with 2FA then redirects with a token for the second factor", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, uStore, tStore := setupOpenIDTestHandler(t, provider)
+		tfStore := memory.NewTwoFactorStore()
+		h.TwoFactorStore = tfStore
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "alice", ExternalID: "openid:" + provider.Issuer() + "#subject-1"})
+		assert.NoError(t, err)
+		err = tfStore.Save(&core.TwoFactor{UserID: "u1", Secret: testTwoFactorSecret, Enabled: true})
+		assert.NoError(t, err)
+		c, rec := openIDLogin(t, h, provider, "/openid/login")
+
+		err = h.OpenIDCallback(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		location, err := rec.Result().Location()
+		assert.NoError(t, err)
+		assert.Equal(t, "", location.Query().Get("token"))
+		assert.Equal(t, 0, len(tStore.Sessions))
+		twoFactorToken := location.Query().Get("twoFactorToken")
+		assert.NotEqual(t, "", twoFactorToken)
+
+		c, rec = openIDTwoFactorRequest(fmt.Sprintf(`{"twoFactorToken":"%s","code":"000000"}`, twoFactorToken))
+		err = h.OpenIDTwoFactor(c)
+		assert.NoError(t, err)
+		status, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "invalid-2fa-code", reason)
+
+		body := fmt.Sprintf(`{"twoFactorToken":"%s","code":"%s"}`, twoFactorToken, currentCode(t, testTwoFactorSecret))
+		c, rec = openIDTwoFactorRequest(body)
+		err = h.OpenIDTwoFactor(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "u1", session.UserID)
+
+		c, _ = openIDTwoFactorRequest(body)
+		err = h.OpenIDTwoFactor(c)
+		assert.NoError(t, err)
+		status, reason = responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "invalid-state", reason)
+	})
+
+	t.Run("given identity not allowed then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		provider.Claims["email"] = "other@example.com"
+		h, uStore, tStore := setupOpenIDTestHandler(t, provider)
+		c, rec := openIDLogin(t, h, provider, "/openid/login")
+
+		var res routes.ErrorResponse
+		err := h.OpenIDCallback(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "permission-denied", res.Reason)
+		assert.Equal(t, 0, len(uStore.Users))
+		assert.Equal(t, 0, len(tStore.Sessions))
+	})
+
+	t.Run("given unknown state then returns error", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, _, _ := setupOpenIDTestHandler(t, provider)
+		c, rec := openIDRequest("/openid/callback?state=unknown&code=code")
+
+		var res routes.ErrorResponse
+		err := h.OpenIDCallback(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "invalid-state", res.Reason)
+	})
+}
+
+func TestNeedsBootstrap_LoginMethods(t *testing.T) {
+	t.Run("given openid enabled then advertises openid", func(t *testing.T) {
+		provider := openidtest.NewProvider(t)
+		h, _, _ := setupOpenIDTestHandler(t, provider)
+		c, rec := openIDRequest("/account/needs-bootstrap")
+
+		var res routes.NeedsBootstrapResponse
+		err := h.NeedsBootstrap(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, []string{"password", "openid"}, res.Data.LoginMethods)
+	})
+}
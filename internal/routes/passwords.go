@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+var defaultPasswords, _ = password.NewHasher(password.DefaultConfig())
+
+func (it *RouteHandler) passwords() core.PasswordHasher {
+	if it.Passwords == nil {
+		return defaultPasswords
+	}
+	return it.Passwords
+}
+
+// rehashPassword replaces an outdated hash of a verified password. Failing to
+// do so is logged but does not fail the login, the old hash still works.
+func (it *RouteHandler) rehashPassword(c echo.Context, userID core.UserID, pw core.Password) {
+	hash, err := it.passwords().Hash(pw)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return
+	}
+	if err = it.UserStore.SetPassword(userID, hash); err != nil {
+		c.Echo().Logger.Error(err)
+	}
+}
+
+// passwordPolicyReason returns the error reason for a new password that breaks
+// the password policy, or an empty string if it is allowed.
+func (it *RouteHandler) passwordPolicyReason(pw core.Password) string {
+	err := it.Config.PasswordPolicy.Check(pw)
+	switch {
+	case errors.Is(err, internal_errors.ErrPasswordTooShort):
+		return "password-too-short"
+	case errors.Is(err, internal_errors.ErrPasswordDenied):
+		return "password-too-common"
+	}
+	return ""
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+// proxyUser returns the user asserted by a trusted authenticating proxy,
+// provisioning it on first sight, or nil if the request has none. Proxy
+// identities are linked to users of their own, never to password accounts.
+func (it *RouteHandler) proxyUser(c echo.Context) (*core.User, error) {
+	userName, ok := it.Config.ProxyAuth.UserName(c.Request())
+	if !ok {
+		return nil, nil
+	}
+
+	return it.linkedUser("proxy:"+userName, userName)
+}
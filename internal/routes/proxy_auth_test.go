@@ -0,0 +1,222 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/proxyauth"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func testProxyAuthConfig(t *testing.T) proxyauth.Config {
+	t.Helper()
+
+	_, ipNet, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+	return proxyauth.Config{
+		Enabled:        true,
+		Header:         proxyauth.DefaultHeader,
+		TrustedProxies: []*net.IPNet{ipNet},
+	}
+}
+
+func TestLogin_ProxyAuth(t *testing.T) {
+	t.Run("given header from trusted proxy then provisions user and returns session token", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, tStore)
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "proxy", session.Device)
+
+		user, err := uStore.ForUserName("alice")
+		assert.NoError(t, err)
+		assert.Equal(t, user.UserID, session.UserID)
+		assert.Equal(t, "proxy:alice", user.ExternalID)
+		assert.Equal(t, false, user.IsAdmin)
+	})
+
+	t.Run("given header from linked user then does not provision another", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", IsAdmin: true})
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u2", UserName: "alice", ExternalID: "proxy:alice"})
+		assert.NoError(t, err)
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, tStore)
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "u2", session.UserID)
+		assert.Equal(t, 2, len(uStore.Users))
+	})
+
+	t.Run("given header naming a password user then refuses to sign in as that user", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, tStore)
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "admin")
+
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "user-exists", res.Reason)
+		assert.Equal(t, 1, len(uStore.Users))
+		assert.Equal(t, 0, len(tStore.Sessions))
+	})
+
+	t.Run("given linked user with 2FA then requires a code", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		tfStore := memory.NewTwoFactorStore()
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "alice", ExternalID: "proxy:alice"})
+		assert.NoError(t, err)
+		err = tfStore.Save(&core.TwoFactor{UserID: "u1", Secret: testTwoFactorSecret, Enabled: true})
+		assert.NoError(t, err)
+		h, c, _ := setupAccountTestHandler(`{}`, uStore, tStore)
+		h.TwoFactorStore = tfStore
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err = h.Login(c)
+		assert.NoError(t, err)
+		status, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "2fa-required", reason)
+		assert.Equal(t, 0, len(tStore.Sessions))
+
+		h, c, rec := setupAccountTestHandler(fmt.Sprintf(`{"code":"%s"}`, currentCode(t, testTwoFactorSecret)), uStore, tStore)
+		h.TwoFactorStore = tfStore
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		session, err := tStore.ForToken(res.Data.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, "u1", session.UserID)
+	})
+
+	t.Run("given header from untrusted peer then requires password", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, tStore)
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "192.168.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginFailResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, nil, res.Data.Token)
+		assert.Equal(t, 0, len(uStore.Users))
+		assert.Equal(t, 0, len(tStore.Sessions))
+	})
+
+	t.Run("given proxy auth disabled then ignores header", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, tStore)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginFailResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, nil, res.Data.Token)
+		assert.Equal(t, 0, len(uStore.Users))
+	})
+}
+
+func TestValidateUser_ProxyAuth(t *testing.T) {
+	t.Run("given header from trusted proxy and no token then validates", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, memory.NewTokenStore())
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err := h.ValidateUser(c)
+		assert.NoError(t, err)
+
+		var res routes.ValidateUserResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, true, res.Data.Validated)
+	})
+
+	t.Run("given header of a user with 2FA and no token then returns auth-error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tfStore := memory.NewTwoFactorStore()
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "alice", ExternalID: "proxy:alice"})
+		assert.NoError(t, err)
+		err = tfStore.Save(&core.TwoFactor{UserID: "u1", Secret: testTwoFactorSecret, Enabled: true})
+		assert.NoError(t, err)
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, memory.NewTokenStore())
+		h.TwoFactorStore = tfStore
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "10.0.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err = h.ValidateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("given header from untrusted peer and no token then returns auth-error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		h, c, rec := setupAccountTestHandler(`{}`, uStore, memory.NewTokenStore())
+		h.Config.ProxyAuth = testProxyAuthConfig(t)
+		c.Request().RemoteAddr = "192.168.0.2:40000"
+		c.Request().Header.Set("Remote-User", "alice")
+
+		err := h.ValidateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -0,0 +1,183 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+// sessionTouchInterval limits how often the last-used time of a session is
+// written back, so that not every authenticated request turns into a write.
+const sessionTouchInterval = time.Minute
+
+// sessionContextKey holds the session a request was authenticated with, or
+// the session it created.
+const sessionContextKey = "session"
+
+func (it *RouteHandler) newSession(c echo.Context, userID core.UserID, device string) (*core.Session, error) {
+	now := time.Now()
+
+	// Sweep sessions that expired without ever being used again
+	err := it.TokenStore.DeleteExpired(now)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &core.Session{
+		SessionID:  uuid.NewString(),
+		Token:      uuid.NewString(),
+		UserID:     userID,
+		Device:     device,
+		UserAgent:  c.Request().UserAgent(),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if it.Config.SessionTTL > 0 {
+		session.ExpiresAt = now.Add(it.Config.SessionTTL)
+	}
+
+	err = it.TokenStore.Add(session)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(sessionContextKey, session)
+	return session, nil
+}
+
+func (it *RouteHandler) Logout(c echo.Context) error {
+	req := new(TokenRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	session, val := it.authenticateSession(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	err := it.TokenStore.Delete(session.SessionID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	r := &SuccessResponse{Status: "ok"}
+	return c.JSON(http.StatusOK, r)
+}
+
+type SessionResponseData struct {
+	SessionID  core.SessionID `json:"id"`
+	Device     string         `json:"device"`
+	UserAgent  string         `json:"userAgent"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	LastUsedAt time.Time      `json:"lastUsedAt"`
+	ExpiresAt  *time.Time     `json:"expiresAt"`
+	Current    bool           `json:"current"`
+}
+
+type ListSessionsResponse struct {
+	SuccessResponse
+	Data []SessionResponseData `json:"data"`
+}
+
+func (it *RouteHandler) ListSessions(c echo.Context) error {
+	req := new(TokenRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	current, val := it.authenticateSession(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	sessions, err := it.TokenStore.ForUser(current.UserID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	now := time.Now()
+	sessionsRes := make([]SessionResponseData, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Expired(now) {
+			continue
+		}
+		data := SessionResponseData{
+			SessionID:  session.SessionID,
+			Device:     session.Device,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.SessionID == current.SessionID,
+		}
+		if !session.ExpiresAt.IsZero() {
+			expiresAt := session.ExpiresAt
+			data.ExpiresAt = &expiresAt
+		}
+		sessionsRes = append(sessionsRes, data)
+	}
+
+	r := &ListSessionsResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            sessionsRes,
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type RevokeSessionRequestBody struct {
+	Token     core.Token     `json:"token"`
+	SessionID core.SessionID `json:"sessionId"`
+}
+
+func (it *RouteHandler) RevokeSession(c echo.Context) error {
+	req := new(RevokeSessionRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	sessions, err := it.TokenStore.ForUser(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	for _, session := range sessions {
+		if session.SessionID != req.SessionID {
+			continue
+		}
+
+		err = it.TokenStore.Delete(session.SessionID)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+		r := &SuccessResponse{Status: "ok"}
+		return c.JSON(http.StatusOK, r)
+	}
+
+	r := &ErrorResponse{
+		Status: "error",
+		Reason: "session-not-found",
+	}
+	return c.JSON(http.StatusBadRequest, r)
+}
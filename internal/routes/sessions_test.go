@@ -0,0 +1,125 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogout(t *testing.T) {
+	t.Run("given no token then returns error", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler("", nil, tStore)
+
+		err := h.Logout(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("given token then removes only that session", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1"}`, nil, tStore)
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s2", Token: "t2", UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.SuccessResponse
+		err = h.Logout(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "ok", res.Status)
+
+		_, err = tStore.ForToken("t1")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+		_, err = tStore.ForToken("t2")
+		assert.NoError(t, err)
+	})
+}
+
+func TestListSessions(t *testing.T) {
+	t.Run("given sessions of two users then returns own unexpired sessions", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1"}`, nil, tStore)
+
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1", Device: "laptop"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s2", Token: "t2", UserID: "u1", Device: "phone", ExpiresAt: expiresAt})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{
+			SessionID: "s3",
+			Token:     "t3",
+			UserID:    "u1",
+			ExpiresAt: time.Now().Add(-time.Hour),
+		})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s4", Token: "t4", UserID: "u2"})
+		assert.NoError(t, err)
+
+		var res routes.ListSessionsResponse
+		err = h.ListSessions(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 2, len(res.Data))
+		assert.Equal(t, "s1", res.Data[0].SessionID)
+		assert.Equal(t, "laptop", res.Data[0].Device)
+		assert.Equal(t, true, res.Data[0].Current)
+		assert.Nil(t, res.Data[0].ExpiresAt)
+		assert.Equal(t, "s2", res.Data[1].SessionID)
+		assert.Equal(t, false, res.Data[1].Current)
+		assert.True(t, expiresAt.Equal(*res.Data[1].ExpiresAt))
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	t.Run("given session of another user then returns error", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","sessionId":"s2"}`, nil, tStore)
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s2", Token: "t2", UserID: "u2"})
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		err = h.RevokeSession(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "session-not-found", res.Reason)
+		assert.Equal(t, 2, len(tStore.Sessions))
+	})
+
+	t.Run("given own session then revokes it", func(t *testing.T) {
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","sessionId":"s2"}`, nil, tStore)
+
+		err := tStore.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s2", Token: "t2", UserID: "u1"})
+		assert.NoError(t, err)
+
+		err = h.RevokeSession(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		_, err = tStore.ForToken("t2")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+}
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,29 +10,50 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/events"
 	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
 	"google.golang.org/protobuf/proto"
 )
 
-// This is a version representing the internal format of sync
-// messages. When this changes, all sync files need to be reset. We
+// This is the newest version of the internal format of sync messages.
+// Files of older supported versions keep syncing, files of versions
+// below storage.MinSyncFormatVersion need to be reset. We
 // will check this version when syncing and notify the user if they
 // need to reset.
-const ActualSyncFormatVersion = 2
+const ActualSyncFormatVersion = storage.MaxSyncFormatVersion
+
+// supportedFormat tells if a sync format version is one the server syncs.
+func supportedFormat(version int16) bool {
+	return version >= storage.MinSyncFormatVersion && version <= storage.MaxSyncFormatVersion
+}
+
+type syncFormats struct {
+	MinVersion int16 `json:"minVersion"`
+	MaxVersion int16 `json:"maxVersion"`
+}
+
+// SyncFormats returns the range of sync format versions the server accepts.
+func (it *RouteHandler) SyncFormats(c echo.Context) error {
+	return c.JSON(http.StatusOK, &SuccessResponse{
+		Status: "ok",
+		Data:   &syncFormats{MinVersion: storage.MinSyncFormatVersion, MaxVersion: storage.MaxSyncFormatVersion},
+	})
+}
 
 type encryptMetaType struct {
 	KeyID string `json:"keyId"`
 }
 
 func (it *RouteHandler) SyncFile(c echo.Context) error {
-	val := it.authenticateUser(c, "")
+	userID, val := it.authenticateUser(c, "")
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -57,7 +80,7 @@ func (it *RouteHandler) SyncFile(c echo.Context) error {
 		return echo.ErrInternalServerError
 	}
 
-	currentFile, err := it.FileStore.ForID(pbRequest.GetFileId())
+	currentFile, err := it.userFile(pbRequest.GetFileId(), userID)
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
 			return c.String(http.StatusBadRequest, "file-not-found")
@@ -66,9 +89,21 @@ func (it *RouteHandler) SyncFile(c echo.Context) error {
 		return err
 	}
 
-	if currentFile.SyncVersion == 0 || currentFile.SyncVersion < ActualSyncFormatVersion {
+	// Clients may tell the format they sync with, which has to be one the
+	// server supports.
+	if format := c.Request().Header.Get("x-actual-format"); format != "" {
+		version, err := strconv.ParseInt(format, 10, 16)
+		if err != nil || !supportedFormat(int16(version)) {
+			return c.String(http.StatusBadRequest, "unsupported-format")
+		}
+	}
+
+	if currentFile.SyncVersion < storage.MinSyncFormatVersion {
 		return c.String(http.StatusBadRequest, "file-old-version")
 	}
+	if currentFile.SyncVersion > storage.MaxSyncFormatVersion {
+		return c.String(http.StatusBadRequest, "file-new-version")
+	}
 
 	// When resetting sync state, something went wrong. There is no
 	// group id and it's awaiting a file to be uploaded.
@@ -106,24 +141,59 @@ func (it *RouteHandler) SyncFile(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "file-has-new-key")
 	}
 
-	// TODO: Implement unencrypted sync option (sync-full)
-	// Currently, End-to-end encrypted sync is active (sync-simple)
+	if errs := validateMessages(pbRequest.GetMessages(), currentFile.EncryptKeyID != ""); len(errs) > 0 {
+		r := &InvalidMessagesResponse{
+			ErrorResponse: ErrorResponse{
+				Status: "error",
+				Reason: "invalid-messages",
+			},
+			Details: errs,
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
 
-	trie, newMessages, err := encryptedSync(
+	// Files without encryption are applied to a server-side replica
+	// (sync-full), end-to-end encrypted ones are only relayed (sync-simple).
+	// Clients store unencrypted files with a "null" encrypt meta, so the key
+	// tells them apart.
+	trie, newMessages, next, err := it.syncMessages(
+		pbRequest.GetFileId(),
+		currentFile.GroupID,
 		pbRequest.GetSince(),
+		int(pbRequest.GetLimit()),
 		pbRequest.GetMessages(),
-		pbRequest.GetFileId(),
-		it.Config.Storage,
-		it.Config.StorageConfig,
+		currentFile.EncryptKeyID == "",
 	)
 	if err != nil {
+		if errors.Is(err, internal_errors.ErrTimestampClockDrift) {
+			return c.String(http.StatusBadRequest, "clock-drift")
+		}
+		// Messages the client is missing were compacted into the latest
+		// snapshot, so it has to download that like after a reset.
+		if errors.Is(err, internal_errors.ErrMessagesCompacted) {
+			return c.String(http.StatusBadRequest, "file-has-reset")
+		}
+		// The file was reset while the sync waited for it.
+		if errors.Is(err, internal_errors.ErrGroupReset) {
+			return c.String(http.StatusBadRequest, "file-has-reset")
+		}
 		c.Echo().Logger.Error(err)
 		return err
 	}
 
+	if messages := pbRequest.GetMessages(); len(messages) > 0 {
+		it.publish(events.Event{
+			Type:      events.TypeSync,
+			FileID:    pbRequest.GetFileId(),
+			GroupID:   pbRequest.GetGroupId(),
+			Timestamp: latestTimestamp(messages),
+		})
+	}
+
 	pbResponse := syncpb.SyncResponse{}
 	pbResponse.Merkle = trie
 	pbResponse.Messages = newMessages
+	pbResponse.Next = next
 
 	out, err := proto.Marshal(&pbResponse)
 	if err != nil {
@@ -148,8 +218,9 @@ func (it *RouteHandler) UserCreateKey(c echo.Context) error {
 		return err
 	}
 
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
+		it.audit(c, &core.AuditEntry{Event: core.AuditCreateKey, Outcome: core.AuditFailure, FileID: req.FileID})
 		r := &ErrorResponse{
 			Status: "error",
 			Reason: "auth-error",
@@ -157,12 +228,23 @@ func (it *RouteHandler) UserCreateKey(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, r)
 	}
 
-	err := it.FileStore.UpdateEncryption(req.FileID, req.KeySalt, req.KeyID, req.TestContent)
+	_, err := it.userFile(req.FileID, userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			it.audit(c, &core.AuditEntry{Event: core.AuditCreateKey, Outcome: core.AuditFailure, FileID: req.FileID})
+			return c.String(http.StatusBadRequest, "file-not-found")
+		}
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	err = it.FileStore.UpdateEncryption(req.FileID, req.KeySalt, req.KeyID, req.TestContent)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
 
+	it.audit(c, &core.AuditEntry{Event: core.AuditCreateKey, Outcome: core.AuditSuccess, FileID: req.FileID})
 	r := &SuccessResponse{Status: "ok"}
 	return c.JSON(http.StatusOK, r)
 }
@@ -190,7 +272,7 @@ func (it *RouteHandler) UserGetKey(c echo.Context) error {
 		return err
 	}
 
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -199,7 +281,7 @@ func (it *RouteHandler) UserGetKey(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, r)
 	}
 
-	file, err := it.FileStore.ForID(req.FileID)
+	file, err := it.userFile(req.FileID, userID)
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
 			return c.String(http.StatusBadRequest, "file-not-found")
@@ -225,8 +307,9 @@ func (it *RouteHandler) ResetUserFile(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
+		it.audit(c, &core.AuditEntry{Event: core.AuditResetFile, Outcome: core.AuditFailure, FileID: req.FileID})
 		r := &ErrorResponse{
 			Status: "error",
 			Reason: "auth-error",
@@ -234,7 +317,23 @@ func (it *RouteHandler) ResetUserFile(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, r)
 	}
 
-	err := it.FileStore.ClearGroup(req.FileID)
+	file, err := it.userFile(req.FileID, userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			it.audit(c, &core.AuditEntry{Event: core.AuditResetFile, Outcome: core.AuditFailure, FileID: req.FileID})
+			return c.String(http.StatusBadRequest, "User or file not found")
+		}
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	// Waits for syncs writing to the old group, so its database is not
+	// removed in the middle of a write.
+	if it.FileLocks != nil {
+		unlock := it.FileLocks.Lock(req.FileID)
+		defer unlock()
+	}
+	err = it.FileStore.ClearGroup(req.FileID)
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageNoRecordUpdated) {
 			return c.String(http.StatusBadRequest, "User or file not found")
@@ -242,7 +341,15 @@ func (it *RouteHandler) ResetUserFile(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
+	if file.GroupID != "" {
+		if err = it.deleteGroupStores(file.GroupID); err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+	}
 
+	it.audit(c, &core.AuditEntry{Event: core.AuditResetFile, Outcome: core.AuditSuccess, FileID: req.FileID})
+	it.publish(events.Event{Type: events.TypeReset, FileID: req.FileID})
 	r := &SuccessResponse{Status: "ok"}
 	return c.JSON(http.StatusOK, r)
 }
@@ -259,7 +366,7 @@ func (it *RouteHandler) UpdateUserFileName(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -268,7 +375,16 @@ func (it *RouteHandler) UpdateUserFileName(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, r)
 	}
 
-	err := it.FileStore.UpdateName(req.FileID, req.Name)
+	_, err := it.userFile(req.FileID, userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			return c.String(http.StatusBadRequest, "User or file not found")
+		}
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	err = it.FileStore.UpdateName(req.FileID, req.Name)
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageNoRecordUpdated) {
 			return c.String(http.StatusBadRequest, "User or file not found")
@@ -292,6 +408,8 @@ type FileInfoWithMetaResponseData struct {
 	GroupID     string          `json:"groupId"`
 	EncryptMeta encryptMetaType `json:"encryptMeta"`
 	Deleted     bool            `json:"deleted"`
+	SHA256      string          `json:"sha256,omitempty"`
+	Size        *int64          `json:"size,omitempty"`
 }
 
 type UserFileInfoResponse struct {
@@ -304,6 +422,17 @@ type FileInfoResponseData struct {
 	FileID  string `json:"fileId"`
 	GroupID string `json:"groupId"`
 	Deleted bool   `json:"deleted"`
+	SHA256  string `json:"sha256,omitempty"`
+	Size    *int64 `json:"size,omitempty"`
+}
+
+// contentSize returns the recorded size of the uploaded file, or nil if its
+// content was never recorded.
+func contentSize(file *core.File) *int64 {
+	if file.ContentHash == "" {
+		return nil
+	}
+	return &file.ContentSize
 }
 
 func (it *RouteHandler) UserFileInfo(c echo.Context) error {
@@ -313,7 +442,7 @@ func (it *RouteHandler) UserFileInfo(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -323,6 +452,9 @@ func (it *RouteHandler) UserFileInfo(c echo.Context) error {
 	}
 
 	file, err := it.FileStore.ForIDAndDelete(req.FileID, false)
+	if err == nil && file.Owner != userID {
+		err = internal_errors.ErrStorageRecordNotFound
+	}
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
 			return c.JSON(http.StatusBadRequest, ErrorResponse{Status: "error", Reason: "User or file not found"})
@@ -345,6 +477,8 @@ func (it *RouteHandler) UserFileInfo(c echo.Context) error {
 				FileID:      file.FileID,
 				GroupID:     file.GroupID,
 				EncryptMeta: meta, Deleted: file.Deleted,
+				SHA256: file.ContentHash,
+				Size:   contentSize(file),
 			},
 		}
 		return c.JSON(http.StatusOK, r)
@@ -357,6 +491,8 @@ func (it *RouteHandler) UserFileInfo(c echo.Context) error {
 			FileID:  file.FileID,
 			GroupID: file.GroupID,
 			Deleted: file.Deleted,
+			SHA256:  file.ContentHash,
+			Size:    contentSize(file),
 		},
 	}
 	return c.JSON(http.StatusOK, r)
@@ -377,6 +513,8 @@ type FileResponseData struct {
 	GroupID      string `json:"groupId"`
 	EncryptKeyID string `json:"encryptKeyIid"`
 	Deleted      bool   `json:"deleted"`
+	SHA256       string `json:"sha256,omitempty"`
+	Size         *int64 `json:"size,omitempty"`
 }
 
 func (it *RouteHandler) ListUserFiles(c echo.Context) error {
@@ -385,7 +523,7 @@ func (it *RouteHandler) ListUserFiles(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -394,7 +532,7 @@ func (it *RouteHandler) ListUserFiles(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, r)
 	}
 
-	files, err := it.FileStore.All()
+	files, err := it.FileStore.ForOwner(userID)
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
@@ -407,6 +545,8 @@ func (it *RouteHandler) ListUserFiles(c echo.Context) error {
 			GroupID:      file.GroupID,
 			EncryptKeyID: file.EncryptKeyID,
 			Deleted:      file.Deleted,
+			SHA256:       file.ContentHash,
+			Size:         contentSize(file),
 		})
 	}
 
@@ -422,132 +562,225 @@ type UploadUserFileResponse struct {
 	GroupID string `json:"groupId"`
 }
 
-func (it *RouteHandler) UploadUserFile(c echo.Context) error {
-	val := it.authenticateUser(c, "")
-	if !val {
-		r := &ErrorResponse{
-			Status: "error",
-			Reason: "auth-error",
-		}
-		return c.JSON(http.StatusUnauthorized, r)
-	}
+// uploadRequest describes the file an upload replaces or adds.
+type uploadRequest struct {
+	userID      string
+	fileID      string
+	groupID     string
+	name        string
+	encryptMeta string
+	syncVersion int16
+	// file is nil for new files.
+	file *core.File
+	// contentHash is the hex encoded SHA-256 of the uploaded file.
+	contentHash string
+	contentSize int64
+}
+
+// blobPath returns the path of the uploaded file of a budget.
+func (it *RouteHandler) blobPath(fileID string) string {
+	return storage.BlobPath(it.Config.UserFiles, fileID)
+}
 
+// parseUpload reads the headers of an upload and checks them against the
+// stored file. A non-empty reason tells why the upload is rejected.
+func (it *RouteHandler) parseUpload(c echo.Context, userID string) (*uploadRequest, string, error) {
 	name, err := url.PathUnescape(c.Request().Header.Get("x-actual-name"))
 	if err != nil {
-		c.Echo().Logger.Error(err)
-		return err
+		return nil, "", err
+	}
+	req := &uploadRequest{
+		userID:      userID,
+		fileID:      c.Request().Header.Get("x-actual-file-id"),
+		groupID:     c.Request().Header.Get("x-actual-group-id"),
+		name:        name,
+		encryptMeta: c.Request().Header.Get("x-actual-encrypt-meta"),
 	}
-	fileID := c.Request().Header.Get("x-actual-file-id")
-	groupID := c.Request().Header.Get("x-actual-group-id")
-	encryptMeta := c.Request().Header.Get("x-actual-encrypt-meta")
 	syncFormatVersion, err := strconv.ParseInt(c.Request().Header.Get("x-actual-format"), 10, 16)
 	if err != nil {
-		c.Echo().Logger.Error(err)
-		return err
+		return nil, "", err
+	}
+	req.syncVersion = int16(syncFormatVersion)
+	// Files are only stored in a format the server can sync.
+	if !supportedFormat(req.syncVersion) {
+		return nil, "unsupported-format", nil
 	}
 	keyID := ""
-	if encryptMeta != "" {
+	if req.encryptMeta != "" {
 		var jsonData encryptMetaType
-		err := json.Unmarshal([]byte(encryptMeta), &jsonData)
+		err := json.Unmarshal([]byte(req.encryptMeta), &jsonData)
 		if err != nil {
-			c.Echo().Logger.Error(err)
-			return err
+			return nil, "", err
 		}
 		keyID = jsonData.KeyID
 	}
 
-	file, err := it.FileStore.ForID(fileID)
-	fileExists := false
-	if err != nil && !errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
-		c.Echo().Logger.Error(err)
-		return err
+	file, err := it.FileStore.ForID(req.fileID)
+	if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		return req, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	req.file = file
+
+	// File ids are global, so an upload must never overwrite a
+	// file that belongs to another user.
+	if file.Owner != userID {
+		return nil, "file-not-found", nil
+	}
+
+	// The uploading file is part of an old group, so reject
+	// it. All of its internal sync state is invalid because its
+	// old. The sync state has been reset, so user needs to
+	// either reset again or download from the current group.
+	if req.groupID != file.GroupID {
+		return nil, "file-has-reset", nil
 	}
-	if !errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
-		fileExists = true
-		// The uploading file is part of an old group, so reject
-		// it. All of its internal sync state is invalid because its
-		// old. The sync state has been reset, so user needs to
-		// either reset again or download from the current group.
-		if groupID != file.GroupID {
-			return c.String(http.StatusBadRequest, "file-has-reset")
-		}
 
-		// The key that the file is encrypted with is different than
-		// the current registered key. All data must always be
-		// encrypted with the registered key for consistency. Key
-		// changes always necessitate a sync reset, which means this
-		// upload is trying to overwrite another reset. That might
-		// be be fine, but since we definitely cannot accept a file
-		// encrypted with the wrong key, we bail and suggest the
-		// user download the latest file.
-		if keyID != file.EncryptKeyID {
-			return c.String(http.StatusBadRequest, "file-has-new-key")
+	// The key that the file is encrypted with is different than
+	// the current registered key. All data must always be
+	// encrypted with the registered key for consistency. Key
+	// changes always necessitate a sync reset, which means this
+	// upload is trying to overwrite another reset. That might
+	// be be fine, but since we definitely cannot accept a file
+	// encrypted with the wrong key, we bail and suggest the
+	// user download the latest file.
+	if keyID != file.EncryptKeyID {
+		return nil, "file-has-new-key", nil
+	}
+	return req, "", nil
+}
+
+func (it *RouteHandler) UploadUserFile(c echo.Context) error {
+	userID, val := it.authenticateUser(c, "")
+	if !val {
+		it.audit(c, &core.AuditEntry{Event: core.AuditUploadFile, Outcome: core.AuditFailure, FileID: c.Request().Header.Get("x-actual-file-id")})
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
 		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	req, reason, err := it.parseUpload(c, userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if reason != "" {
+		it.audit(c, &core.AuditEntry{Event: core.AuditUploadFile, Outcome: core.AuditFailure, FileID: c.Request().Header.Get("x-actual-file-id")})
+		return c.String(http.StatusBadRequest, reason)
 	}
 
-	out, err := it.Config.FileSystem.Create(filepath.Join(it.Config.UserFiles, fmt.Sprintf("%s.blob", fileID)))
+	// The body is written next to the previous file and only replaces it
+	// once complete, so a dropped connection leaves the previous file intact.
+	blob := it.blobPath(req.fileID)
+	temp := fmt.Sprintf("%s.%s.tmp", blob, uuid.NewString())
+	defer func() { _ = it.Config.FileSystem.Remove(temp) }()
+	out, err := it.Config.FileSystem.Create(temp)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), c.Request().Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	defer out.Close()
-	_, err = io.Copy(out, c.Request().Body)
+	req.contentHash = hex.EncodeToString(hash.Sum(nil))
+	req.contentSize = size
+
+	groupID, err := it.commitUpload(req, func() error {
+		return it.Config.FileSystem.Rename(temp, blob)
+	})
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return err
 	}
+	return it.uploadSucceeded(c, req.fileID, groupID)
+}
+
+// commitUpload replaces the uploaded file with replace and records it,
+// returning the group of the file. The recorded content of existing files
+// is updated before they are replaced and restored if that fails, so
+// downloads never check a file against the content of another one for
+// longer than the replacement takes.
+func (it *RouteHandler) commitUpload(req *uploadRequest, replace func() error) (string, error) {
+	fileID, groupID := req.fileID, req.groupID
+	if req.file == nil {
+		// Its new. Files without a row are never downloaded, so the file
+		// can be replaced first.
+		if err := replace(); err != nil {
+			return "", err
+		}
 
-	if !fileExists {
-		// Its new
 		uuid, err := uuid.NewRandom()
 		if err != nil {
-			c.Echo().Logger.Error(err)
-			return err
+			return "", err
 		}
 		groupID = uuid.String()
 
 		err = it.FileStore.Add(&core.NewFile{
 			FileID:      fileID,
 			GroupID:     groupID,
-			SyncVersion: int16(syncFormatVersion),
-			EncryptMeta: encryptMeta,
-			Name:        name,
+			SyncVersion: req.syncVersion,
+			EncryptMeta: req.encryptMeta,
+			Name:        req.name,
+			Owner:       req.userID,
 		})
 		if err != nil {
-			c.Echo().Logger.Error(err)
-			return err
+			return "", err
 		}
+		// Without it, the content is recorded on the first download.
+		return groupID, it.FileStore.UpdateContent(fileID, req.contentHash, req.contentSize)
+	}
 
-		r := UploadUserFileResponse{
-			SuccessResponse: SuccessResponse{Status: "ok"},
-			GroupID:         groupID,
+	err := it.FileStore.UpdateContent(fileID, req.contentHash, req.contentSize)
+	if err != nil {
+		return "", err
+	}
+	if err = replace(); err != nil {
+		// The previous file is still in place.
+		restoreErr := it.FileStore.UpdateContent(fileID, req.file.ContentHash, req.file.ContentSize)
+		if restoreErr != nil {
+			return "", fmt.Errorf("%w (restoring content: %v)", err, restoreErr)
 		}
-		return c.JSON(http.StatusOK, r)
+		return "", err
 	}
 
 	if groupID == "" {
 		// Sync state was reset. Create new group
 		uuid, err := uuid.NewRandom()
 		if err != nil {
-			c.Echo().Logger.Error(err)
-			return err
+			return "", err
 		}
 		groupID = uuid.String()
 
 		err = it.FileStore.UpdateGroup(fileID, groupID)
 		if err != nil {
-			c.Echo().Logger.Error(err)
-			return err
+			return "", err
+		}
+	} else {
+		// The snapshot holds the messages of the group so far, which lets
+		// compaction remove them.
+		err := it.recordSnapshot(groupID)
+		if err != nil {
+			return "", err
 		}
 	}
 
 	// Regardless, update properties
-	err = it.FileStore.Update(fileID, int16(syncFormatVersion), encryptMeta, name)
-	if err != nil {
-		c.Echo().Logger.Error(err)
-		return err
-	}
+	return groupID, it.FileStore.Update(fileID, req.syncVersion, req.encryptMeta, req.name)
+}
 
+func (it *RouteHandler) uploadSucceeded(c echo.Context, fileID, groupID string) error {
+	it.audit(c, &core.AuditEntry{Event: core.AuditUploadFile, Outcome: core.AuditSuccess, FileID: fileID})
+	it.publish(events.Event{Type: events.TypeUpload, FileID: fileID, GroupID: groupID})
 	r := UploadUserFileResponse{
 		SuccessResponse: SuccessResponse{Status: "ok"},
 		GroupID:         groupID,
@@ -556,7 +789,7 @@ func (it *RouteHandler) UploadUserFile(c echo.Context) error {
 }
 
 func (it *RouteHandler) DownloadUserFile(c echo.Context) error {
-	val := it.authenticateUser(c, "")
+	userID, val := it.authenticateUser(c, "")
 	if !val {
 		r := &ErrorResponse{
 			Status: "error",
@@ -567,7 +800,10 @@ func (it *RouteHandler) DownloadUserFile(c echo.Context) error {
 
 	fileID := c.Request().Header.Get("x-actual-file-id")
 
-	_, err := it.FileStore.ForIDAndDelete(fileID, false)
+	file, err := it.FileStore.ForIDAndDelete(fileID, false)
+	if err == nil && file.Owner != userID {
+		err = internal_errors.ErrStorageRecordNotFound
+	}
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
 			return c.String(http.StatusBadRequest, "User or file not found")
@@ -576,27 +812,35 @@ func (it *RouteHandler) DownloadUserFile(c echo.Context) error {
 		return err
 	}
 
-	fs := it.Config.FileSystem
-	file, err := fs.Open(filepath.Join(it.Config.UserFiles, fmt.Sprintf("%s.blob", fileID)))
+	blob, err := it.Config.FileSystem.Open(it.blobPath(fileID))
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return c.String(http.StatusInternalServerError, "Error reading files")
 	}
-	defer file.Close()
-	finfo, err := file.Stat()
+	defer blob.Close()
+	finfo, err := blob.Stat()
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return c.String(http.StatusInternalServerError, "Error reading files")
 	}
-	fileBlob := make([]byte, finfo.Size())
-	_, err = file.Read(fileBlob)
+	contentHash, err := it.checkContent(c.Request(), file, blob, finfo.Size())
+	if errors.Is(err, internal_errors.ErrBlobCorrupted) {
+		c.Echo().Logger.Errorf("file %s: %v", fileID, err)
+		return c.String(http.StatusInternalServerError, "file-corrupted")
+	}
 	if err != nil {
 		c.Echo().Logger.Error(err)
 		return c.String(http.StatusInternalServerError, "Error reading files")
 	}
 
-	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileID))
-	return c.Blob(http.StatusOK, echo.MIMEOctetStream, fileBlob)
+	// ServeContent streams the file and answers conditional and range
+	// requests against the ETag.
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileID))
+	header.Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	header.Set("ETag", fmt.Sprintf("%q", contentHash))
+	http.ServeContent(c.Response(), c.Request(), fileID, finfo.ModTime(), blob)
+	return nil
 }
 
 func (it *RouteHandler) DeleteUserFile(c echo.Context) error {
@@ -605,8 +849,9 @@ func (it *RouteHandler) DeleteUserFile(c echo.Context) error {
 		c.Echo().Logger.Error(err)
 		return err
 	}
-	val := it.authenticateUser(c, req.Token)
+	userID, val := it.authenticateUser(c, req.Token)
 	if !val {
+		it.audit(c, &core.AuditEntry{Event: core.AuditDeleteFile, Outcome: core.AuditFailure, FileID: req.FileID})
 		r := &ErrorResponse{
 			Status: "error",
 			Reason: "auth-error",
@@ -614,7 +859,17 @@ func (it *RouteHandler) DeleteUserFile(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, r)
 	}
 
-	err := it.FileStore.Delete(req.FileID)
+	file, err := it.userFile(req.FileID, userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			it.audit(c, &core.AuditEntry{Event: core.AuditDeleteFile, Outcome: core.AuditFailure, FileID: req.FileID})
+			return c.String(http.StatusBadRequest, "User or file not found")
+		}
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	err = it.FileStore.Delete(req.FileID)
 	if err != nil {
 		if errors.Is(err, internal_errors.ErrStorageNoRecordUpdated) {
 			return c.String(http.StatusBadRequest, "User or file not found")
@@ -623,6 +878,70 @@ func (it *RouteHandler) DeleteUserFile(c echo.Context) error {
 		return err
 	}
 
+	it.audit(c, &core.AuditEntry{Event: core.AuditDeleteFile, Outcome: core.AuditSuccess, FileID: req.FileID})
+	it.publish(events.Event{Type: events.TypeDelete, FileID: req.FileID, GroupID: file.GroupID})
 	r := &SuccessResponse{Status: "ok"}
 	return c.JSON(http.StatusOK, r)
 }
+
+// checkContent checks blob against the recorded content of the uploaded
+// file and returns its hex encoded SHA-256. The whole file is only read for
+// requests answered with all of it, others only get their size checked. The
+// content of files uploaded before it was recorded is computed from blob
+// and stored. Either way, blob is rewound afterwards.
+func (it *RouteHandler) checkContent(req *http.Request, file *core.File, blob io.ReadSeeker, size int64) (string, error) {
+	switch {
+	case file.ContentHash == "":
+		hash, size, err := storage.BlobDigest(blob)
+		if err != nil {
+			return "", err
+		}
+		file.ContentHash, file.ContentSize = hash, size
+		if err = it.FileStore.UpdateContent(file.FileID, hash, size); err != nil {
+			return "", err
+		}
+	case size != file.ContentSize:
+		return "", fmt.Errorf("%w: %d bytes instead of %d", internal_errors.ErrBlobCorrupted, size, file.ContentSize)
+	case sendsWholeFile(req, fmt.Sprintf("%q", file.ContentHash)):
+		if err := storage.CheckBlob(file, blob); err != nil {
+			return "", err
+		}
+	default:
+		return file.ContentHash, nil
+	}
+
+	if _, err := blob.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return file.ContentHash, nil
+}
+
+// sendsWholeFile tells if http.ServeContent answers a request for the file
+// with the given ETag with all of it, rather than a range or not modified.
+func sendsWholeFile(req *http.Request, etag string) bool {
+	for _, match := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+		if match == etag || match == "*" {
+			return false
+		}
+	}
+	if req.Header.Get("Range") == "" {
+		return true
+	}
+	// Ranges of another version of the file are answered with all of it.
+	ifRange := req.Header.Get("If-Range")
+	return ifRange != "" && ifRange != etag
+}
+
+// userFile returns the file with the given id, as long as it is owned by the
+// given user. Files owned by other users are reported as not found.
+func (it *RouteHandler) userFile(fileID core.FileID, userID core.UserID) (*core.File, error) {
+	file, err := it.FileStore.ForID(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.Owner != userID {
+		return nil, internal_errors.ErrStorageRecordNotFound
+	}
+	return file, nil
+}
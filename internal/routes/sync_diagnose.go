@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// fileMerkleID is the row sync keeps the merkle trie of a file in.
+const fileMerkleID = "1"
+
+type DiagnoseSyncRequestBody struct {
+	Token  core.Token  `json:"token"`
+	FileID core.FileID `json:"fileId"`
+	// Merkle is the client's trie, either as an object or as the JSON
+	// string the sync endpoint returns.
+	Merkle json.RawMessage `json:"merkle"`
+}
+
+type DiagnoseSyncData struct {
+	InSync bool `json:"inSync"`
+	// DivergedAt is the start of the earliest minute in which the client
+	// and the server hold different messages.
+	DivergedAt *time.Time `json:"divergedAt"`
+	// Since is the timestamp a client has to sync from to catch up.
+	Since string `json:"since,omitempty"`
+	// ServerMessages is the number of server messages after Since.
+	ServerMessages int `json:"serverMessages"`
+}
+
+type DiagnoseSyncResponse struct {
+	SuccessResponse
+	Data DiagnoseSyncData `json:"data"`
+}
+
+// DiagnoseSync compares a client's merkle trie of a file with the server's
+// and reports where their histories diverge.
+func (it *RouteHandler) DiagnoseSync(c echo.Context) error {
+	req := new(DiagnoseSyncRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	file, err := it.userFile(req.FileID, userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			return c.String(http.StatusBadRequest, "file-not-found")
+		}
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if file.GroupID == "" {
+		return c.String(http.StatusBadRequest, "file-needs-upload")
+	}
+
+	clientTrie, err := parseClientMerkle(req.Merkle)
+	if err != nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-merkle",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	stores, release, err := it.groupStores(file.GroupID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	defer release()
+
+	serverTrie := merkle.NewMerkle(0)
+	msg, err := stores.Merkles.GetForGroup(fileMerkleID)
+	if err == nil {
+		serverTrie, err = merkle.ParseMerkle(msg.Merkle)
+	}
+	if err != nil && !errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	data := DiagnoseSyncData{InSync: true}
+	if millis, diverged := serverTrie.Diff(clientTrie); diverged {
+		divergedAt := time.UnixMilli(millis).UTC()
+		data.InSync = false
+		data.DivergedAt = &divergedAt
+		data.Since = timestamp.NewTimestamp(millis, 0, "0000000000000000").ToString()
+		data.ServerMessages, err = stores.Messages.CountSince(data.Since)
+		if err != nil {
+			c.Echo().Logger.Error(err)
+			return err
+		}
+	}
+
+	r := &DiagnoseSyncResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            data,
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func parseClientMerkle(raw json.RawMessage) (*merkle.Merkle, error) {
+	var jsonString string
+	if err := json.Unmarshal(raw, &jsonString); err == nil {
+		return merkle.ParseMerkle(jsonString)
+	}
+	return merkle.ParseMerkle(string(raw))
+}
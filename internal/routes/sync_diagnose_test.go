@@ -0,0 +1,165 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+var diagnoseTestMillis = []int64{
+	time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC).UnixMilli(),
+	time.Date(2022, 3, 2, 10, 0, 0, 0, time.UTC).UnixMilli(),
+	time.Date(2022, 3, 5, 10, 0, 0, 0, time.UTC).UnixMilli(),
+}
+
+// setupDiagnoseTest syncs the messages at diagnoseTestMillis to file f1 of
+// user u1 and returns a handler for a diagnose request with the given body.
+func setupDiagnoseTest(t *testing.T, body string) (*routes.RouteHandler, echo.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	db, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	tstore := sqlite.NewTokenStore(db)
+	fstore := sqlite.NewFileStore(db)
+	err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+	assert.NoError(t, err)
+	err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u1"})
+	assert.NoError(t, err)
+
+	h, c, rec := setupSyncTestHandler(body, tstore, fstore)
+	h.Config.Storage = core.Sqlite
+	h.Config.StorageConfig = sqlite.StorageConfig{UserData: t.TempDir()}
+
+	messages := make([]*syncpb.MessageEnvelope, 0, len(diagnoseTestMillis))
+	for _, millis := range diagnoseTestMillis {
+		messages = append(messages, &syncpb.MessageEnvelope{
+			Timestamp:   timestamp.NewTimestamp(millis, 0, "ABCDEFGH12345678").ToString(),
+			IsEncrypted: true,
+			Content:     []byte{1, 2, 3},
+		})
+	}
+	conn, _, _, _, _, err := storage.NewGroupStores(h.Config.Storage, h.Config.StorageConfig, "g1")
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = storage.AddNewMessagesTransaction(h.Config.Storage, conn, messages, false, 0)
+	assert.NoError(t, err)
+
+	return h, c, rec
+}
+
+func clientMerkle(t *testing.T, millis ...int64) string {
+	t.Helper()
+
+	trie := merkle.NewMerkle(0)
+	for _, m := range millis {
+		trie.Insert(timestamp.NewTimestamp(m, 0, "ABCDEFGH12345678"))
+	}
+	jsonString, err := trie.Prune().ToJSONString()
+	assert.NoError(t, err)
+	return jsonString
+}
+
+func TestDiagnoseSync(t *testing.T) {
+	t.Run("given matching merkle then returns in sync", func(t *testing.T) {
+		body := `{"token":"token123","fileId":"f1","merkle":` + clientMerkle(t, diagnoseTestMillis...) + `}`
+		h, c, rec := setupDiagnoseTest(t, body)
+
+		err := h.DiagnoseSync(c)
+		assert.NoError(t, err)
+
+		var res routes.DiagnoseSyncResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, true, res.Data.InSync)
+		assert.Nil(t, res.Data.DivergedAt)
+		assert.Equal(t, 0, res.Data.ServerMessages)
+	})
+
+	t.Run("given merkle missing last message then returns divergence", func(t *testing.T) {
+		merkleString, err := json.Marshal(clientMerkle(t, diagnoseTestMillis[:2]...))
+		assert.NoError(t, err)
+		body := `{"token":"token123","fileId":"f1","merkle":` + string(merkleString) + `}`
+		h, c, rec := setupDiagnoseTest(t, body)
+
+		err = h.DiagnoseSync(c)
+		assert.NoError(t, err)
+
+		var res routes.DiagnoseSyncResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, false, res.Data.InSync)
+		assert.NotNil(t, res.Data.DivergedAt)
+		// The divergence point is the start of the range of merkle keys the
+		// tries share, so it may precede messages both sides already have.
+		divergedAt := res.Data.DivergedAt.UnixMilli()
+		assert.True(t, divergedAt <= diagnoseTestMillis[2])
+		expected := 0
+		for _, millis := range diagnoseTestMillis {
+			if millis > divergedAt {
+				expected++
+			}
+		}
+		assert.Equal(t, expected, res.Data.ServerMessages)
+		assert.Equal(t, timestamp.NewTimestamp(divergedAt, 0, "0000000000000000").ToString(), res.Data.Since)
+	})
+
+	t.Run("given empty merkle then counts all server messages", func(t *testing.T) {
+		h, c, rec := setupDiagnoseTest(t, `{"token":"token123","fileId":"f1","merkle":{}}`)
+
+		err := h.DiagnoseSync(c)
+		assert.NoError(t, err)
+
+		var res routes.DiagnoseSyncResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, false, res.Data.InSync)
+		assert.Equal(t, 3, res.Data.ServerMessages)
+	})
+
+	t.Run("given malformed merkle then returns invalid-merkle", func(t *testing.T) {
+		h, c, _ := setupDiagnoseTest(t, `{"token":"token123","fileId":"f1","merkle":{"hash":"x"}}`)
+
+		err := h.DiagnoseSync(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid-merkle", reason)
+	})
+
+	t.Run("given unknown file then returns file-not-found", func(t *testing.T) {
+		h, c, rec := setupDiagnoseTest(t, `{"token":"token123","fileId":"f2","merkle":{}}`)
+
+		err := h.DiagnoseSync(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-not-found", strings.TrimSpace(rec.Body.String()))
+	})
+
+	t.Run("given invalid token then returns auth-error", func(t *testing.T) {
+		h, c, _ := setupDiagnoseTest(t, `{"token":"token456","fileId":"f1","merkle":{}}`)
+
+		err := h.DiagnoseSync(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "auth-error", reason)
+	})
+}
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+)
+
+// maxSyncPageSize caps the page size clients may ask for.
+const maxSyncPageSize = 10000
+
+// syncMessages stores the new messages of a file's sync group and returns the
+// messages the client is missing along with the merkle trie of the group. Encrypted files
+// are only relayed between clients (sync-simple). For unencrypted files,
+// replicate also applies the messages to the server-side replica (sync-full).
+// Messages stamped further ahead of the server time than the configured drift
+// are rejected. Syncs of the same file are serialized, and a file reset while
+// waiting for its lock gives ErrGroupReset. Clients syncing from before the
+// compaction horizon get ErrMessagesCompacted.
+//
+// With a limit, at most that many missing messages are returned and next is
+// the timestamp to continue from if more follow. Without one, all are.
+func (it *RouteHandler) syncMessages(
+	fileID core.FileID,
+	groupID string,
+	since string,
+	limit int,
+	messages []*syncpb.MessageEnvelope,
+	replicate bool,
+) (string, []*syncpb.MessageEnvelope, string, error) {
+	// The stores are opened under the lock, so a concurrent reset cannot
+	// delete the group database while it is in use and have it recreated.
+	if it.FileLocks != nil {
+		unlock := it.FileLocks.Lock(fileID)
+		defer unlock()
+
+		file, err := it.FileStore.ForID(fileID)
+		if err != nil {
+			return "", nil, "", err
+		}
+		if file.GroupID != groupID {
+			return "", nil, "", internal_errors.ErrGroupReset
+		}
+	}
+
+	stores, release, err := it.groupStores(groupID)
+	if err != nil {
+		return "", nil, "", err
+	}
+	defer release()
+
+	// Clients missing compacted messages have to download the file again.
+	horizon, err := stores.Compaction.Horizon()
+	if err != nil {
+		return "", nil, "", err
+	}
+	if since < horizon {
+		return "", nil, "", internal_errors.ErrMessagesCompacted
+	}
+
+	if limit > maxSyncPageSize {
+		limit = maxSyncPageSize
+	}
+	// Fetching one message more than the limit tells if another page follows.
+	fetch := 0
+	if limit > 0 {
+		fetch = limit + 1
+	}
+	newMessages, err := stores.Messages.GetSince(since, fetch)
+	if err != nil {
+		return "", nil, "", err
+	}
+	next := ""
+	if limit > 0 && len(newMessages) > limit {
+		newMessages = newMessages[:limit]
+		next = newMessages[limit-1].Timestamp
+	}
+	pbNewMessages := make([]*syncpb.MessageEnvelope, len(newMessages))
+	for i, msg := range newMessages {
+		pbNewMessages[i] = &syncpb.MessageEnvelope{
+			Timestamp:   msg.Timestamp,
+			IsEncrypted: msg.IsEncrypted,
+			Content:     msg.Content,
+		}
+	}
+
+	trie, err := storage.AddNewMessagesTransaction(
+		it.Config.Storage,
+		stores.Connection,
+		messages,
+		replicate,
+		it.Config.MaxClockDrift,
+	)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	merkleString, err := trie.ToJSONString()
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	return merkleString, pbNewMessages, next, nil
+}
+
+// groupStores returns the message stores of a sync group, from the pool if
+// there is one, along with a function to call once done with them.
+func (it *RouteHandler) groupStores(groupID string) (*storage.GroupStores, func(), error) {
+	if it.Groups != nil {
+		return it.Groups.Acquire(groupID)
+	}
+
+	conn, merkles, messages, replica, compaction, err := storage.NewGroupStores(
+		it.Config.Storage,
+		it.Config.StorageConfig,
+		groupID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	stores := &storage.GroupStores{
+		Connection: conn,
+		Merkles:    merkles,
+		Messages:   messages,
+		Replica:    replica,
+		Compaction: compaction,
+	}
+	return stores, func() { conn.Close() }, nil
+}
+
+// deleteGroupStores removes the message database of a sync group.
+func (it *RouteHandler) deleteGroupStores(groupID string) error {
+	if it.Groups != nil {
+		return it.Groups.Delete(groupID)
+	}
+	return storage.DeleteGroupStores(it.Config.Storage, it.Config.StorageConfig, groupID)
+}
+
+// recordSnapshot records that a snapshot of a sync group was uploaded.
+func (it *RouteHandler) recordSnapshot(groupID string) error {
+	stores, release, err := it.groupStores(groupID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return stores.Compaction.SetSnapshot(time.Now())
+}
+
+// latestTimestamp returns the newest timestamp of the messages.
+func latestTimestamp(messages []*syncpb.MessageEnvelope) string {
+	latest := ""
+	for _, msg := range messages {
+		if msg.GetTimestamp() > latest {
+			latest = msg.GetTimestamp()
+		}
+	}
+	return latest
+}
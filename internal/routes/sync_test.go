@@ -4,21 +4,31 @@ package routes_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	"github.com/nathanjisaac/actual-server-go/internal/core/lock"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
 	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 )
 
 func setupSyncTestHandler(body string, tstore core.TokenStore, fstore core.FileStore) (
@@ -35,36 +45,36 @@ func setupSyncTestHandler(body string, tstore core.TokenStore, fstore core.FileS
 		Mode: core.Development,
 	}
 	h := &routes.RouteHandler{
-		Config:        config,
-		FileStore:     nil,
-		PasswordStore: nil,
-		TokenStore:    nil,
+		Config:     config,
+		FileStore:  nil,
+		UserStore:  nil,
+		TokenStore: nil,
 	}
 	switch fstore {
 	case nil:
 		if tstore != nil {
 			h = &routes.RouteHandler{
-				Config:        config,
-				FileStore:     nil,
-				PasswordStore: nil,
-				TokenStore:    tstore,
+				Config:     config,
+				FileStore:  nil,
+				UserStore:  nil,
+				TokenStore: tstore,
 			}
 		}
 	default:
 		switch tstore {
 		case nil:
 			h = &routes.RouteHandler{
-				Config:        config,
-				FileStore:     fstore,
-				PasswordStore: nil,
-				TokenStore:    nil,
+				Config:     config,
+				FileStore:  fstore,
+				UserStore:  nil,
+				TokenStore: nil,
 			}
 		default:
 			h = &routes.RouteHandler{
-				Config:        config,
-				FileStore:     fstore,
-				PasswordStore: nil,
-				TokenStore:    tstore,
+				Config:     config,
+				FileStore:  fstore,
+				UserStore:  nil,
+				TokenStore: tstore,
 			}
 		}
 	}
@@ -88,36 +98,36 @@ func setupSyncTestFileHandler(body []byte, tstore core.TokenStore, fstore core.F
 		UserFiles:  "",
 	}
 	h := &routes.RouteHandler{
-		Config:        config,
-		FileStore:     nil,
-		PasswordStore: nil,
-		TokenStore:    nil,
+		Config:     config,
+		FileStore:  nil,
+		UserStore:  nil,
+		TokenStore: nil,
 	}
 	switch fstore {
 	case nil:
 		if tstore != nil {
 			h = &routes.RouteHandler{
-				Config:        config,
-				FileStore:     nil,
-				PasswordStore: nil,
-				TokenStore:    tstore,
+				Config:     config,
+				FileStore:  nil,
+				UserStore:  nil,
+				TokenStore: tstore,
 			}
 		}
 	default:
 		switch tstore {
 		case nil:
 			h = &routes.RouteHandler{
-				Config:        config,
-				FileStore:     fstore,
-				PasswordStore: nil,
-				TokenStore:    nil,
+				Config:     config,
+				FileStore:  fstore,
+				UserStore:  nil,
+				TokenStore: nil,
 			}
 		default:
 			h = &routes.RouteHandler{
-				Config:        config,
-				FileStore:     fstore,
-				PasswordStore: nil,
-				TokenStore:    tstore,
+				Config:     config,
+				FileStore:  fstore,
+				UserStore:  nil,
+				TokenStore: tstore,
 			}
 		}
 	}
@@ -133,7 +143,7 @@ func TestUserCreateKey(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1","keyId":"2","keySalt":"3","testContent":"4"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -152,14 +162,38 @@ func TestUserCreateKey(t *testing.T) {
 		defer db.Close()
 		tstore := sqlite.NewTokenStore(db)
 		fstore := sqlite.NewFileStore(db)
-		h, c, _ := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
+		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		err = h.UserCreateKey(c)
-		assert.Error(t, err)
-		assert.Equal(t, "no record updated", err.Error())
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-not-found", rec.Body.String())
+	})
+
+	t.Run("given token and file of another user returns error", func(t *testing.T) {
+		db, err := sqlite.NewAccountConnection(":memory:")
+		assert.NoError(t, err)
+		defer db.Close()
+		tstore := sqlite.NewTokenStore(db)
+		fstore := sqlite.NewFileStore(db)
+		h, c, rec := setupSyncTestHandler(`{"token":"token123","fileId":"f1","keyId":"2"}`, tstore, fstore)
+
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u2"})
+		assert.NoError(t, err)
+
+		err = h.UserCreateKey(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-not-found", rec.Body.String())
+
+		file, err := fstore.ForID("f1")
+		assert.NoError(t, err)
+		assert.Equal(t, "", file.EncryptKeyID)
 	})
 
 	t.Run("given token and valid file returns success", func(t *testing.T) {
@@ -174,9 +208,9 @@ func TestUserCreateKey(t *testing.T) {
 			fstore,
 		)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.SuccessResponse
@@ -204,7 +238,7 @@ func TestUserGetKey(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -225,7 +259,7 @@ func TestUserGetKey(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		err = h.UserGetKey(c)
@@ -246,9 +280,9 @@ func TestUserGetKey(t *testing.T) {
 			fstore,
 		)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -275,7 +309,7 @@ func TestResetUserFile(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -296,7 +330,7 @@ func TestResetUserFile(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		err = h.ResetUserFile(c)
@@ -312,13 +346,20 @@ func TestResetUserFile(t *testing.T) {
 		tstore := sqlite.NewTokenStore(db)
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123","fileId":"f1"}`, tstore, fstore)
+		userData := t.TempDir()
+		h.Config.Storage = core.Sqlite
+		h.Config.StorageConfig = sqlite.StorageConfig{UserData: userData}
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
+		conn, _, _, _, _, err := storage.NewGroupStores(h.Config.Storage, h.Config.StorageConfig, "g1")
+		assert.NoError(t, err)
+		conn.Close()
+		assert.FileExists(t, filepath.Join(userData, "group-g1.sqlite"))
 
 		var res routes.SuccessResponse
 		err = h.ResetUserFile(c)
@@ -331,6 +372,7 @@ func TestResetUserFile(t *testing.T) {
 		file, err := fstore.ForID("f1")
 		assert.NoError(t, err)
 		assert.Equal(t, "", file.GroupID)
+		assert.NoFileExists(t, filepath.Join(userData, "group-g1.sqlite"))
 	})
 }
 
@@ -343,7 +385,7 @@ func TestUpdateUserFileName(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1","name":"budgetnew"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -364,7 +406,7 @@ func TestUpdateUserFileName(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		err = h.UpdateUserFileName(c)
@@ -381,9 +423,9 @@ func TestUpdateUserFileName(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123","fileId":"f1","name":"budgetnew"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -411,7 +453,7 @@ func TestUserFileInfo(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -432,7 +474,7 @@ func TestUserFileInfo(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-file-id", "f1")
 
@@ -454,11 +496,11 @@ func TestUserFileInfo(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-file-id", "f1")
 
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -485,7 +527,7 @@ func TestUserFileInfo(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-file-id", "f1")
 
@@ -495,6 +537,7 @@ func TestUserFileInfo(t *testing.T) {
 			SyncVersion: 2,
 			EncryptMeta: `{"keyId":"keyidMeta"}`,
 			Name:        "budget",
+			Owner:       "u1",
 		})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
@@ -525,7 +568,7 @@ func TestListUserFiles(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -546,7 +589,7 @@ func TestListUserFiles(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ListFilesResponse
@@ -567,15 +610,15 @@ func TestListUserFiles(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123","fileId":"f1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
 
-		err = fstore.Add(&core.NewFile{FileID: "f2", GroupID: "g2", SyncVersion: 2, EncryptMeta: "abc2", Name: "budget2"})
+		err = fstore.Add(&core.NewFile{FileID: "f2", GroupID: "g2", SyncVersion: 2, EncryptMeta: "abc2", Name: "budget2", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f2", "salt2", "keyid2", "test2")
 		assert.NoError(t, err)
@@ -603,6 +646,33 @@ func TestListUserFiles(t *testing.T) {
 	})
 }
 
+func TestListUserFiles_Owner(t *testing.T) {
+	t.Run("given files of two users returns only own files", func(t *testing.T) {
+		db, err := sqlite.NewAccountConnection(":memory:")
+		assert.NoError(t, err)
+		defer db.Close()
+		tstore := sqlite.NewTokenStore(db)
+		fstore := sqlite.NewFileStore(db)
+		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
+
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u1"})
+		assert.NoError(t, err)
+		err = fstore.Add(&core.NewFile{FileID: "f2", GroupID: "g2", SyncVersion: 2, Name: "budget2", Owner: "u2"})
+		assert.NoError(t, err)
+
+		var res routes.ListFilesResponse
+		err = h.ListUserFiles(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 1, len(res.Data))
+		assert.Equal(t, "f1", res.Data[0].FileID)
+	})
+}
+
 func TestUploadUserFIle(t *testing.T) {
 	t.Run("given no token in returns error", func(t *testing.T) {
 		db, err := sqlite.NewAccountConnection(":memory:")
@@ -612,7 +682,7 @@ func TestUploadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte{}, tstore, fstore, "")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -633,7 +703,7 @@ func TestUploadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", "token123")
 		c.Request().Header.Set("x-actual-name", "budget")
@@ -670,7 +740,7 @@ func TestUploadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", "token123")
 		c.Request().Header.Set("x-actual-name", "budget")
@@ -678,7 +748,7 @@ func TestUploadUserFIle(t *testing.T) {
 		c.Request().Header.Set("x-actual-group-id", "g2")
 		c.Request().Header.Set("x-actual-encrypt-meta", `{"keyId": "keyid"}`)
 		c.Request().Header.Set("x-actual-format", "2")
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -698,7 +768,7 @@ func TestUploadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", "token123")
 		c.Request().Header.Set("x-actual-name", "budget")
@@ -706,7 +776,7 @@ func TestUploadUserFIle(t *testing.T) {
 		c.Request().Header.Set("x-actual-group-id", "g1")
 		c.Request().Header.Set("x-actual-encrypt-meta", `{"keyId": "keyid"}`)
 		c.Request().Header.Set("x-actual-format", "2")
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid2", "test")
 		assert.NoError(t, err)
@@ -718,6 +788,32 @@ func TestUploadUserFIle(t *testing.T) {
 		assert.Equal(t, "file-has-new-key", rec.Body.String())
 	})
 
+	t.Run("given logged in and unsupported format then returns error", func(t *testing.T) {
+		db, err := sqlite.NewAccountConnection(":memory:")
+		assert.NoError(t, err)
+		defer db.Close()
+		tstore := sqlite.NewTokenStore(db)
+		fstore := sqlite.NewFileStore(db)
+		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
+
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+		c.Request().Header.Set("x-actual-token", "token123")
+		c.Request().Header.Set("x-actual-name", "budget")
+		c.Request().Header.Set("x-actual-file-id", "f1")
+		c.Request().Header.Set("x-actual-group-id", "g1")
+		c.Request().Header.Set("x-actual-format", fmt.Sprint(storage.MaxSyncFormatVersion+1))
+
+		err = h.UploadUserFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "unsupported-format", rec.Body.String())
+		count, err := fstore.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
 	t.Run("given logged in and file exists then returns success", func(t *testing.T) {
 		db, err := sqlite.NewAccountConnection(":memory:")
 		assert.NoError(t, err)
@@ -726,15 +822,24 @@ func TestUploadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", "token123")
+		h.Config.Storage = core.Sqlite
+		h.Config.StorageConfig = sqlite.StorageConfig{UserData: t.TempDir()}
+		conn, _, _, _, _, err := storage.NewGroupStores(h.Config.Storage, h.Config.StorageConfig, "g1")
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, err = storage.AddNewMessagesTransaction(h.Config.Storage, conn, []*syncpb.MessageEnvelope{
+			testSyncMessage(t, 1000000001000, "S:Checking"),
+		}, false, 0)
+		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-name", "budgetnew")
 		c.Request().Header.Set("x-actual-file-id", "f1")
 		c.Request().Header.Set("x-actual-group-id", "g1")
 		c.Request().Header.Set("x-actual-encrypt-meta", `{"keyId": "keyid"}`)
-		c.Request().Header.Set("x-actual-format", "3")
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		c.Request().Header.Set("x-actual-format", "2")
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 1, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -750,9 +855,16 @@ func TestUploadUserFIle(t *testing.T) {
 
 		file, err := fstore.ForID("f1")
 		assert.NoError(t, err)
-		assert.Equal(t, int16(3), file.SyncVersion)
+		assert.Equal(t, int16(2), file.SyncVersion)
 		assert.Equal(t, "budgetnew", file.Name)
 
+		// The upload is a snapshot the earlier message is compacted into.
+		_, _, err = conn.(*sqlite.Connection).Mutate("UPDATE messages_binary SET received_at = 0")
+		assert.NoError(t, err)
+		compaction, err := storage.CompactMessagesTransaction(h.Config.Storage, conn, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, compaction.Messages)
+
 		fs := h.Config.FileSystem
 		result, err := afero.FileContainsBytes(fs, filepath.Join(h.Config.UserFiles, "f1.blob"), []byte("testing"))
 		assert.NoError(t, err)
@@ -769,7 +881,7 @@ func TestDownloadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte{}, tstore, fstore, "")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -790,7 +902,7 @@ func TestDownloadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", "token123")
 		c.Request().Header.Set("x-actual-file-id", "f1")
@@ -810,11 +922,11 @@ func TestDownloadUserFIle(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestFileHandler([]byte("testing"), tstore, fstore, "f1")
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 		c.Request().Header.Set("x-actual-token", "token123")
 		c.Request().Header.Set("x-actual-file-id", "f1")
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -833,6 +945,30 @@ func TestDownloadUserFIle(t *testing.T) {
 	})
 }
 
+func TestDownloadUserFile_Owner(t *testing.T) {
+	t.Run("given logged in and file of another user then returns error", func(t *testing.T) {
+		db, err := sqlite.NewAccountConnection(":memory:")
+		assert.NoError(t, err)
+		defer db.Close()
+		tstore := sqlite.NewTokenStore(db)
+		fstore := sqlite.NewFileStore(db)
+		h, c, rec := setupSyncTestFileHandler([]byte{}, tstore, fstore, "f1")
+
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+		c.Request().Header.Set("x-actual-token", "token123")
+		c.Request().Header.Set("x-actual-file-id", "f1")
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u2"})
+		assert.NoError(t, err)
+
+		err = h.DownloadUserFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "User or file not found", rec.Body.String())
+	})
+}
+
 func TestDeleteUserFile(t *testing.T) {
 	t.Run("given no token in returns error", func(t *testing.T) {
 		db, err := sqlite.NewAccountConnection(":memory:")
@@ -842,7 +978,7 @@ func TestDeleteUserFile(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"fileId":"1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		var res routes.ErrorResponse
@@ -863,7 +999,7 @@ func TestDeleteUserFile(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
 
 		err = h.DeleteUserFile(c)
@@ -880,9 +1016,9 @@ func TestDeleteUserFile(t *testing.T) {
 		fstore := sqlite.NewFileStore(db)
 		h, c, rec := setupSyncTestHandler(`{"token":"token123","fileId":"f1"}`, tstore, fstore)
 
-		err = tstore.Add("token123")
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
 		assert.NoError(t, err)
-		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget"})
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: "abc", Name: "budget", Owner: "u1"})
 		assert.NoError(t, err)
 		err = fstore.UpdateEncryption("f1", "salt", "keyid", "test")
 		assert.NoError(t, err)
@@ -900,3 +1036,720 @@ func TestDeleteUserFile(t *testing.T) {
 		assert.Equal(t, true, file.Deleted)
 	})
 }
+
+// setupSyncFileTest creates unencrypted file f1 of user u1 with the given
+// encrypt meta, with its message database in a temporary directory.
+func setupSyncFileTest(t *testing.T, encryptMeta string, req *syncpb.SyncRequest) (
+	*routes.RouteHandler,
+	echo.Context,
+	*httptest.ResponseRecorder,
+) {
+	t.Helper()
+
+	db, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	tstore := sqlite.NewTokenStore(db)
+	fstore := sqlite.NewFileStore(db)
+	err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+	assert.NoError(t, err)
+	err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, EncryptMeta: encryptMeta, Name: "budget", Owner: "u1"})
+	assert.NoError(t, err)
+
+	body, err := proto.Marshal(req)
+	assert.NoError(t, err)
+	h, c, rec := setupSyncTestFileHandler(body, tstore, fstore, "f1")
+	h.Config.Storage = core.Sqlite
+	h.Config.StorageConfig = sqlite.StorageConfig{UserData: t.TempDir()}
+	c.Request().Header.Set("x-actual-token", "token123")
+	return h, c, rec
+}
+
+func testSyncMessage(t *testing.T, millis int64, value string) *syncpb.MessageEnvelope {
+	t.Helper()
+
+	content, err := proto.Marshal(&syncpb.Message{Dataset: "accounts", Row: "a1", Column: "name", Value: value})
+	assert.NoError(t, err)
+	return &syncpb.MessageEnvelope{
+		Timestamp: timestamp.NewTimestamp(millis, 0, "ABCDEFGH12345678").ToString(),
+		Content:   content,
+	}
+}
+
+func replicaValue(t *testing.T, h *routes.RouteHandler, groupID string) (*core.ReplicaValue, error) {
+	t.Helper()
+
+	conn, _, _, replica, _, err := storage.NewGroupStores(h.Config.Storage, h.Config.StorageConfig, groupID)
+	assert.NoError(t, err)
+	defer conn.Close()
+	return replica.Get("accounts", "a1", "name")
+}
+
+func TestSyncFile(t *testing.T) {
+	t.Run("given unencrypted file then applies messages to replica", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:  "f1",
+			GroupId: "g1",
+			Since:   timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{
+				testSyncMessage(t, 1000000002000, "S:Checking"),
+				testSyncMessage(t, 1000000001000, "S:Savings"),
+			},
+		})
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		var res syncpb.SyncResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &res))
+		assert.NotEqual(t, "", res.Merkle)
+		assert.Equal(t, 0, len(res.Messages))
+
+		v, err := replicaValue(t, h, "g1")
+		assert.NoError(t, err)
+		assert.Equal(t, "S:Checking", v.Value)
+	})
+
+	t.Run("given file uploaded with null encrypt meta then applies messages to replica", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "null", &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{testSyncMessage(t, 1000000001000, "S:Savings")},
+		})
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		v, err := replicaValue(t, h, "g1")
+		assert.NoError(t, err)
+		assert.Equal(t, "S:Savings", v.Value)
+	})
+
+	t.Run("given message from a clock running ahead then returns clock-drift", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:  "f1",
+			GroupId: "g1",
+			Since:   timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{
+				testSyncMessage(t, time.Now().Add(30*time.Second).UnixMilli(), "S:Checking"),
+				testSyncMessage(t, time.Now().Add(time.Hour).UnixMilli(), "S:Cash"),
+			},
+		})
+		h.Config.MaxClockDrift = time.Minute
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "clock-drift", rec.Body.String())
+		_, err = replicaValue(t, h, "g1")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given messages with the largest counter within drift then accepts them", func(t *testing.T) {
+		millis := time.Now().Add(30 * time.Second).UnixMilli()
+		msg := testSyncMessage(t, millis, "S:Cash")
+		msg.Timestamp = timestamp.NewTimestamp(millis, timestamp.MaxCounter, "ABCDEFGH12345678").ToString()
+		h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{msg},
+		})
+		h.Config.MaxClockDrift = time.Minute
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		v, err := replicaValue(t, h, "g1")
+		assert.NoError(t, err)
+		assert.Equal(t, "S:Cash", v.Value)
+	})
+
+	t.Run("given limit then pages through missing messages", func(t *testing.T) {
+		messages := []*syncpb.MessageEnvelope{
+			testSyncMessage(t, 1000000001000, "S:Checking"),
+			testSyncMessage(t, 1000000002000, "S:Savings"),
+			testSyncMessage(t, 1000000003000, "S:Cash"),
+		}
+		h, c, _ := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: messages,
+		})
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		since := timestamp.NewTimestamp(0, 0, "0000000000000000").ToString()
+		var received []string
+		for page := 0; page < 3; page++ {
+			body, err := proto.Marshal(&syncpb.SyncRequest{FileId: "f1", GroupId: "g1", Since: since, Limit: 2})
+			assert.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("x-actual-token", "token123")
+			rec := httptest.NewRecorder()
+			err = h.SyncFile(echo.New().NewContext(req, rec))
+			assert.NoError(t, err)
+
+			var res syncpb.SyncResponse
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &res))
+			for _, msg := range res.Messages {
+				received = append(received, msg.Timestamp)
+			}
+			if res.Next == "" {
+				break
+			}
+			assert.Equal(t, 2, len(res.Messages))
+			assert.Equal(t, res.Messages[1].Timestamp, res.Next)
+			since = res.Next
+		}
+
+		assert.Equal(t, []string{messages[0].Timestamp, messages[1].Timestamp, messages[2].Timestamp}, received)
+	})
+
+	t.Run("given invalid messages then lists them and stores none", func(t *testing.T) {
+		valid := testSyncMessage(t, 1000000001000, "S:Checking")
+		badTimestamp := testSyncMessage(t, 1000000002000, "S:Savings")
+		badTimestamp.Timestamp = "2001-09-09T01:46:42Z-0000-ABCDEFGH12345678"
+		badNode := testSyncMessage(t, 1000000003000, "S:Savings")
+		badNode.Timestamp = "2001-09-09T01:46:43.000Z-0000-ABCD"
+		encrypted := testSyncMessage(t, 1000000004000, "S:Savings")
+		encrypted.IsEncrypted = true
+		tooLarge := testSyncMessage(t, 1000000005000, "S:Savings")
+		tooLarge.Content = make([]byte, 2<<20)
+		badContent := testSyncMessage(t, 1000000006000, "S:Savings")
+		badContent.Content = []byte{0xff, 0xff}
+		h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{valid, badTimestamp, badNode, encrypted, tooLarge, badContent},
+		})
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		var res routes.InvalidMessagesResponse
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "invalid-messages", res.Reason)
+		assert.Equal(t, []routes.MessageError{
+			{Index: 1, Timestamp: badTimestamp.Timestamp, Reason: "invalid-timestamp"},
+			{Index: 2, Timestamp: badNode.Timestamp, Reason: "invalid-node"},
+			{Index: 3, Timestamp: encrypted.Timestamp, Reason: "expected-unencrypted"},
+			{Index: 4, Timestamp: tooLarge.Timestamp, Reason: "content-too-large"},
+			{Index: 5, Timestamp: badContent.Timestamp, Reason: "invalid-content"},
+		}, res.Details)
+		_, err = replicaValue(t, h, "g1")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given encrypted file then only relays messages", func(t *testing.T) {
+		msg := testSyncMessage(t, 1000000001000, "S:Checking")
+		msg.IsEncrypted = true
+		h, c, rec := setupSyncFileTest(t, `{"keyId":"k1"}`, &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			KeyId:    "k1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{msg},
+		})
+		assert.NoError(t, h.FileStore.UpdateEncryption("f1", "salt", "k1", "test"))
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		_, err = replicaValue(t, h, "g1")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+}
+
+func TestSyncFile_Reset(t *testing.T) {
+	t.Run("given file reset and uploaded again then new group starts from empty history", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{testSyncMessage(t, 1000000001000, "S:Checking")},
+		})
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"token":"token123","fileId":"f1"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+		err = h.ResetUserFile(e.NewContext(req, rec))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		err = h.FileStore.UpdateGroup("f1", "g2")
+		assert.NoError(t, err)
+
+		body, err := proto.Marshal(&syncpb.SyncRequest{
+			FileId:  "f1",
+			GroupId: "g2",
+			Since:   timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+		})
+		assert.NoError(t, err)
+		req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("x-actual-token", "token123")
+		rec = httptest.NewRecorder()
+		err = h.SyncFile(e.NewContext(req, rec))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res syncpb.SyncResponse
+		assert.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 0, len(res.Messages))
+		_, err = replicaValue(t, h, "g2")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given file reset while sync waits for the lock then returns file-has-reset", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+			FileId:   "f1",
+			GroupId:  "g1",
+			Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+			Messages: []*syncpb.MessageEnvelope{testSyncMessage(t, 1000000001000, "S:Checking")},
+		})
+		h.FileLocks = lock.NewKeyedMutex()
+
+		unlock := h.FileLocks.Lock("f1")
+		done := make(chan error)
+		go func() { done <- h.SyncFile(c) }()
+		time.Sleep(50 * time.Millisecond)
+		err := h.FileStore.UpdateGroup("f1", "g2")
+		assert.NoError(t, err)
+		unlock()
+
+		assert.NoError(t, <-done)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-has-reset", rec.Body.String())
+		userData := h.Config.StorageConfig.(sqlite.StorageConfig).UserData
+		assert.NoFileExists(t, filepath.Join(userData, "group-g1.sqlite"))
+	})
+}
+
+func TestSyncFile_Compacted(t *testing.T) {
+	syncSince := func(t *testing.T, h *routes.RouteHandler, since time.Time) *httptest.ResponseRecorder {
+		t.Helper()
+
+		body, err := proto.Marshal(&syncpb.SyncRequest{
+			FileId:  "f1",
+			GroupId: "g1",
+			Since:   timestamp.NewTimestamp(since.UnixMilli(), 0, "0000000000000000").ToString(),
+		})
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("x-actual-token", "token123")
+		rec := httptest.NewRecorder()
+		err = h.SyncFile(echo.New().NewContext(req, rec))
+		assert.NoError(t, err)
+		return rec
+	}
+
+	h, c, rec := setupSyncFileTest(t, "", &syncpb.SyncRequest{
+		FileId:  "f1",
+		GroupId: "g1",
+		Since:   timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+		Messages: []*syncpb.MessageEnvelope{
+			testSyncMessage(t, 1000000001000, "S:Checking"),
+			testSyncMessage(t, 1000000002000, "S:Savings"),
+		},
+	})
+	err := h.SyncFile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	conn, _, _, _, compaction, err := storage.NewGroupStores(h.Config.Storage, h.Config.StorageConfig, "g1")
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, _, err = conn.(*sqlite.Connection).Mutate("UPDATE messages_binary SET received_at = 0")
+	assert.NoError(t, err)
+	assert.NoError(t, compaction.SetSnapshot(time.Now()))
+	result, err := storage.CompactMessagesTransaction(h.Config.Storage, conn, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Messages)
+
+	t.Run("given client synced before the horizon then returns file-has-reset", func(t *testing.T) {
+		rec := syncSince(t, h, time.UnixMilli(1000000000000))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-has-reset", rec.Body.String())
+	})
+
+	t.Run("given client synced after the horizon then syncs", func(t *testing.T) {
+		rec := syncSince(t, h, time.Now().Add(-time.Minute))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var res syncpb.SyncResponse
+		assert.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 0, len(res.Messages))
+	})
+}
+
+func TestSyncFile_Format(t *testing.T) {
+	request := &syncpb.SyncRequest{
+		FileId:  "f1",
+		GroupId: "g1",
+		Since:   timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+	}
+
+	t.Run("given file of an older version then returns file-old-version", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", request)
+		assert.NoError(t, h.FileStore.Update("f1", storage.MinSyncFormatVersion-1, "", "budget"))
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-old-version", rec.Body.String())
+	})
+
+	t.Run("given file of a newer version then returns file-new-version", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", request)
+		assert.NoError(t, h.FileStore.Update("f1", storage.MaxSyncFormatVersion+1, "", "budget"))
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-new-version", rec.Body.String())
+	})
+
+	t.Run("given unsupported client format then returns unsupported-format", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", request)
+		c.Request().Header.Set("x-actual-format", fmt.Sprint(storage.MaxSyncFormatVersion+1))
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "unsupported-format", rec.Body.String())
+	})
+
+	t.Run("given supported client format then syncs", func(t *testing.T) {
+		h, c, rec := setupSyncFileTest(t, "", request)
+		c.Request().Header.Set("x-actual-format", fmt.Sprint(storage.MinSyncFormatVersion))
+
+		err := h.SyncFile(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestSyncFormats(t *testing.T) {
+	t.Run("given request then returns supported versions", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		h := &routes.RouteHandler{}
+
+		err := h.SyncFormats(e.NewContext(req, rec))
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t,
+			fmt.Sprintf(`{"status":"ok","data":{"minVersion":%d,"maxVersion":%d}}`,
+				storage.MinSyncFormatVersion, storage.MaxSyncFormatVersion),
+			rec.Body.String())
+	})
+}
+
+func TestSyncFile_Concurrent(t *testing.T) {
+	t.Run("given many clients syncing one file then merkle matches the stored messages", func(t *testing.T) {
+		// The account database is a file, so all pooled connections share it.
+		db, err := sqlite.NewAccountConnection(filepath.Join(t.TempDir(), "account.sqlite"))
+		assert.NoError(t, err)
+		defer db.Close()
+		tstore := sqlite.NewTokenStore(db)
+		fstore := sqlite.NewFileStore(db)
+		err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+		assert.NoError(t, err)
+		err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u1"})
+		assert.NoError(t, err)
+
+		config := core.Config{Storage: core.Sqlite, StorageConfig: sqlite.StorageConfig{UserData: t.TempDir()}}
+		h := &routes.RouteHandler{Config: config, TokenStore: tstore, FileStore: fstore}
+		h.Groups = storage.NewGroupManager(config.Storage, config.StorageConfig, 0, 0)
+		defer h.Groups.Close()
+		h.FileLocks = lock.NewKeyedMutex()
+
+		const clients, syncs = 8, 5
+		e := echo.New()
+		var wg sync.WaitGroup
+		for client := 0; client < clients; client++ {
+			wg.Add(1)
+			go func(client int) {
+				defer wg.Done()
+				for i := 0; i < syncs; i++ {
+					body, err := proto.Marshal(&syncpb.SyncRequest{
+						FileId:   "f1",
+						GroupId:  "g1",
+						Since:    timestamp.NewTimestamp(0, 0, "0000000000000000").ToString(),
+						Messages: []*syncpb.MessageEnvelope{testSyncMessage(t, 1000000000000+int64(client*syncs+i)*60000, "S:Cash")},
+					})
+					assert.NoError(t, err)
+					req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+					req.Header.Set("x-actual-token", "token123")
+					rec := httptest.NewRecorder()
+
+					err = h.SyncFile(e.NewContext(req, rec))
+					assert.NoError(t, err)
+					assert.Equal(t, http.StatusOK, rec.Code)
+				}
+			}(client)
+		}
+		wg.Wait()
+
+		stores, release, err := h.Groups.Acquire("g1")
+		assert.NoError(t, err)
+		defer release()
+		messages, err := stores.Messages.GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, clients*syncs, len(messages))
+
+		rebuilt := merkle.NewMerkle(0)
+		for _, msg := range messages {
+			ts, err := timestamp.ParseTimestamp(msg.Timestamp)
+			assert.NoError(t, err)
+			rebuilt.Insert(ts)
+		}
+		rebuiltJSON, err := rebuilt.Prune().ToJSONString()
+		assert.NoError(t, err)
+		stored, err := stores.Merkles.GetForGroup("1")
+		assert.NoError(t, err)
+		assert.Equal(t, rebuiltJSON, stored.Merkle)
+	})
+}
+
+func TestDownloadUserFile_Conditional(t *testing.T) {
+	download := func(t *testing.T, h *routes.RouteHandler, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-file-id", "f1")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		assert.NoError(t, h.DownloadUserFile(echo.New().NewContext(req, rec)))
+		return rec
+	}
+	etag := fmt.Sprintf("%q", sha256Hex("old"))
+
+	t.Run("given file without stored hash then returns and stores its etag", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := download(t, h, nil)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "old", rec.Body.String())
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+		file, err := h.FileStore.ForID("f1")
+		assert.NoError(t, err)
+		assert.Equal(t, sha256Hex("old"), file.ContentHash)
+	})
+
+	t.Run("given matching if-none-match then returns not modified", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := download(t, h, map[string]string{"If-None-Match": etag})
+
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, 0, rec.Body.Len())
+	})
+
+	t.Run("given range then returns the part of the file", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := download(t, h, map[string]string{"Range": "bytes=1-"})
+
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+		assert.Equal(t, "ld", rec.Body.String())
+		assert.Equal(t, "bytes 1-2/3", rec.Header().Get("Content-Range"))
+	})
+
+	t.Run("given if-range of an older upload then returns the whole file", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := download(t, h, map[string]string{"Range": "bytes=1-", "If-Range": fmt.Sprintf("%q", sha256Hex("older"))})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "old", rec.Body.String())
+	})
+
+	t.Run("given new upload then changes the etag", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadRequest(t, h.UploadUserFile, "", strings.NewReader("new"), nil)
+
+		rec := download(t, h, map[string]string{"If-None-Match": etag})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "new", rec.Body.String())
+		assert.Equal(t, fmt.Sprintf("%q", sha256Hex("new")), rec.Header().Get("ETag"))
+	})
+}
+
+func TestUploadUserFile_Content(t *testing.T) {
+	t.Run("given upload then lists its checksum and size", func(t *testing.T) {
+		h := setupUploadTest(t)
+		rec := uploadRequest(t, h.UploadUserFile, "", strings.NewReader("new budget"), nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-file-id", "f1")
+		rec = httptest.NewRecorder()
+		assert.NoError(t, h.UserFileInfo(echo.New().NewContext(req, rec)))
+		var info routes.UserFileInfoResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, sha256Hex("new budget"), info.Data.SHA256)
+		assert.Equal(t, int64(10), *info.Data.Size)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-actual-token", "token123")
+		rec = httptest.NewRecorder()
+		assert.NoError(t, h.ListUserFiles(echo.New().NewContext(req, rec)))
+		var list routes.ListFilesResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Equal(t, 1, len(list.Data))
+		assert.Equal(t, sha256Hex("new budget"), list.Data[0].SHA256)
+		assert.Equal(t, int64(10), *list.Data[0].Size)
+	})
+
+	t.Run("given chunked upload then records its checksum and size", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadID := createUpload(t, h)
+		uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new budget"), map[string]string{"x-actual-offset": "0"})
+		uploadRequest(t, h.FinalizeUpload, uploadID, nil, map[string]string{"x-actual-sha256": strings.ToUpper(sha256Hex("new budget"))})
+
+		file, err := h.FileStore.ForID("f1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, sha256Hex("new budget"), file.ContentHash)
+		assert.Equal(t, int64(10), file.ContentSize)
+	})
+
+	t.Run("given file changed on disk then download returns file-corrupted", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadRequest(t, h.UploadUserFile, "", strings.NewReader("new budget"), nil)
+		assert.NoError(t, afero.WriteFile(h.Config.FileSystem, "/files/f1.blob", []byte("new budgeT"), 0o600))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-file-id", "f1")
+		rec := httptest.NewRecorder()
+		assert.NoError(t, h.DownloadUserFile(echo.New().NewContext(req, rec)))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "file-corrupted", rec.Body.String())
+	})
+}
+
+// failingRenameFs is a file system on which files cannot be moved.
+type failingRenameFs struct {
+	afero.Fs
+}
+
+func (failingRenameFs) Rename(string, string) error {
+	return errors.New("rename failed")
+}
+
+func TestUploadUserFile_Commit(t *testing.T) {
+	t.Run("given file not moved into place then keeps the previous content", func(t *testing.T) {
+		h := setupUploadTest(t)
+		rec := uploadRequest(t, h.UploadUserFile, "", strings.NewReader("new budget"), nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		fs := h.Config.FileSystem
+		h.Config.FileSystem = failingRenameFs{fs}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("other"))
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-name", "budget")
+		req.Header.Set("x-actual-file-id", "f1")
+		req.Header.Set("x-actual-group-id", "g1")
+		req.Header.Set("x-actual-format", "2")
+		err := h.UploadUserFile(echo.New().NewContext(req, httptest.NewRecorder()))
+		assert.Error(t, err)
+		h.Config.FileSystem = fs
+
+		file, err := h.FileStore.ForID("f1")
+		assert.NoError(t, err)
+		assert.Equal(t, sha256Hex("new budget"), file.ContentHash)
+		assert.Equal(t, int64(10), file.ContentSize)
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-file-id", "f1")
+		rec = httptest.NewRecorder()
+		assert.NoError(t, h.DownloadUserFile(echo.New().NewContext(req, rec)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "new budget", rec.Body.String())
+		infos, err := afero.ReadDir(fs, "/files")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(infos))
+	})
+}
+
+func TestDownloadUserFile_Verification(t *testing.T) {
+	download := func(t *testing.T, h *routes.RouteHandler, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-file-id", "f1")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		assert.NoError(t, h.DownloadUserFile(echo.New().NewContext(req, rec)))
+		return rec
+	}
+	// The file keeps its size, so only reading it tells it changed.
+	setup := func(t *testing.T) *routes.RouteHandler {
+		h := setupUploadTest(t)
+		uploadRequest(t, h.UploadUserFile, "", strings.NewReader("new budget"), nil)
+		assert.NoError(t, afero.WriteFile(h.Config.FileSystem, "/files/f1.blob", []byte("new budgeT"), 0o600))
+		return h
+	}
+	etag := fmt.Sprintf("%q", sha256Hex("new budget"))
+
+	t.Run("given matching if-none-match then does not read the file", func(t *testing.T) {
+		rec := download(t, setup(t), map[string]string{"If-None-Match": etag})
+
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+	})
+
+	t.Run("given range then does not read the file", func(t *testing.T) {
+		rec := download(t, setup(t), map[string]string{"Range": "bytes=4-"})
+
+		assert.Equal(t, http.StatusPartialContent, rec.Code)
+	})
+
+	t.Run("given if-range of another version then verifies the whole file", func(t *testing.T) {
+		rec := download(t, setup(t), map[string]string{"Range": "bytes=4-", "If-Range": `"other"`})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "file-corrupted", rec.Body.String())
+	})
+
+	t.Run("given file of another size then fails any request", func(t *testing.T) {
+		h := setup(t)
+		assert.NoError(t, afero.WriteFile(h.Config.FileSystem, "/files/f1.blob", []byte("new"), 0o600))
+
+		rec := download(t, h, map[string]string{"Range": "bytes=1-"})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "file-corrupted", rec.Body.String())
+	})
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"regexp"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxMessageContentSize is the largest message content accepted by sync.
+// Messages hold a single cell, so anything near this is a client bug.
+const maxMessageContentSize = 1 << 20
+
+// timestampPattern matches serialized timestamps: millisecond precision UTC
+// time, a four digit hex counter and a node id.
+var timestampPattern = regexp.MustCompile(
+	`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z-[0-9A-F]{4}-([0-9A-Za-z]*)$`,
+)
+
+const nodeLength = 16
+
+type MessageError struct {
+	Index     int    `json:"index"`
+	Timestamp string `json:"timestamp"`
+	Reason    string `json:"reason"`
+}
+
+type InvalidMessagesResponse struct {
+	ErrorResponse
+	Details []MessageError `json:"details"`
+}
+
+// validateMessages checks every message of a sync request before any of them
+// is stored and returns why messages were rejected, if any were. Messages of
+// files with an encryption key must be encrypted, all others must not be.
+func validateMessages(messages []*syncpb.MessageEnvelope, encrypted bool) []MessageError {
+	var errs []MessageError
+	for i, msg := range messages {
+		if reason := validateMessage(msg, encrypted); reason != "" {
+			errs = append(errs, MessageError{Index: i, Timestamp: msg.GetTimestamp(), Reason: reason})
+		}
+	}
+	return errs
+}
+
+func validateMessage(msg *syncpb.MessageEnvelope, encrypted bool) string {
+	match := timestampPattern.FindStringSubmatch(msg.GetTimestamp())
+	if match == nil {
+		return "invalid-timestamp"
+	}
+	if len(match[1]) != nodeLength {
+		return "invalid-node"
+	}
+	ts, err := timestamp.ParseTimestamp(msg.GetTimestamp())
+	if err != nil || ts.ToString() != msg.GetTimestamp() {
+		return "invalid-timestamp"
+	}
+
+	if len(msg.GetContent()) > maxMessageContentSize {
+		return "content-too-large"
+	}
+	if msg.GetIsEncrypted() != encrypted {
+		if encrypted {
+			return "expected-encrypted"
+		}
+		return "expected-unencrypted"
+	}
+	if !encrypted {
+		var content syncpb.Message
+		if proto.Unmarshal(msg.GetContent(), &content) != nil {
+			return "invalid-content"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// attemptContextKey marks a request whose authentication attempt was allowed
+// and is not settled yet.
+const attemptContextKey = "authAttempt"
+
+// allowAttempt reports whether the client may try to authenticate now, and
+// if not, how long it has to wait. Without a limiter every attempt is allowed.
+// An allowed attempt has to be settled with attemptFailed, attemptSucceeded
+// or attemptEnded.
+func (it *RouteHandler) allowAttempt(c echo.Context) (time.Duration, bool) {
+	if it.AuthLimiter == nil {
+		return 0, true
+	}
+	wait, ok := it.AuthLimiter.Allow(c.RealIP())
+	if ok {
+		c.Set(attemptContextKey, true)
+	}
+	return wait, ok
+}
+
+func (it *RouteHandler) attemptFailed(c echo.Context) {
+	if it.settleAttempt(c) {
+		it.AuthLimiter.Failure(c.RealIP())
+	}
+}
+
+func (it *RouteHandler) attemptSucceeded(c echo.Context) {
+	if it.settleAttempt(c) {
+		it.AuthLimiter.Success(c.RealIP())
+	}
+}
+
+// attemptEnded settles an attempt that ended without an outcome, such as a
+// login asking for a second factor or failing on an error. It is meant to be
+// deferred right after allowAttempt.
+func (it *RouteHandler) attemptEnded(c echo.Context) {
+	if it.settleAttempt(c) {
+		it.AuthLimiter.Cancel(c.RealIP())
+	}
+}
+
+func (it *RouteHandler) settleAttempt(c echo.Context) bool {
+	if pending, _ := c.Get(attemptContextKey).(bool); !pending || it.AuthLimiter == nil {
+		return false
+	}
+	c.Set(attemptContextKey, false)
+	return true
+}
+
+func (it *RouteHandler) tooManyAttempts(c echo.Context, wait time.Duration) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	r := &ErrorResponse{
+		Status: "error",
+		Reason: "too-many-attempts",
+	}
+	return c.JSON(http.StatusTooManyRequests, r)
+}
@@ -0,0 +1,327 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/totp"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+// twoFactorIssuer names the server in authenticator apps.
+const twoFactorIssuer = "Actual"
+
+// enabledTwoFactor returns the 2FA enrolment guarding the user's logins, or
+// nil if there is none.
+func (it *RouteHandler) enabledTwoFactor(userID core.UserID) (*core.TwoFactor, error) {
+	if it.TwoFactorStore == nil {
+		return nil, nil
+	}
+	tf, err := it.TwoFactorStore.ForUser(userID)
+	if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !tf.Enabled {
+		return nil, nil
+	}
+	return tf, nil
+}
+
+// verifyTOTP accepts a current code that was not used before and remembers
+// its time step.
+func (it *RouteHandler) verifyTOTP(tf *core.TwoFactor, code string) (bool, error) {
+	counter, ok := totp.Validate(tf.Secret, code, time.Now())
+	if !ok || counter <= tf.LastCounter {
+		return false, nil
+	}
+	tf.LastCounter = counter
+	return true, it.TwoFactorStore.Save(tf)
+}
+
+// verifyTwoFactorCode accepts a current TOTP code or an unused recovery code,
+// which is used up.
+func (it *RouteHandler) verifyTwoFactorCode(tf *core.TwoFactor, code string) (bool, error) {
+	ok, err := it.verifyTOTP(tf, code)
+	if ok || err != nil {
+		return ok, err
+	}
+
+	hash := core.HashRecoveryCode(code)
+	for i, recoveryCode := range tf.RecoveryCodes {
+		if recoveryCode != hash {
+			continue
+		}
+		tf.RecoveryCodes = append(tf.RecoveryCodes[:i:i], tf.RecoveryCodes[i+1:]...)
+		return true, it.TwoFactorStore.Save(tf)
+	}
+	return false, nil
+}
+
+// checkTwoFactor verifies the code of a user with 2FA who passed the first
+// factor. Unless it returns true, it has written the response.
+func (it *RouteHandler) checkTwoFactor(c echo.Context, tf *core.TwoFactor, code string) (bool, error) {
+	// A missing code is not a failed attempt, clients send the first factor
+	// alone to learn that a code is needed.
+	if code == "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "2fa-required",
+		}
+		return false, c.JSON(http.StatusUnauthorized, r)
+	}
+	valid, err := it.verifyTwoFactorCode(tf, code)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return false, err
+	}
+	if !valid {
+		it.attemptFailed(c)
+		it.audit(c, &core.AuditEntry{Event: core.AuditLogin, Outcome: core.AuditFailure, UserID: tf.UserID})
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-2fa-code",
+		}
+		return false, c.JSON(http.StatusUnauthorized, r)
+	}
+	return true, nil
+}
+
+type EnrollTwoFactorData struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type EnrollTwoFactorResponse struct {
+	SuccessResponse
+	Data EnrollTwoFactorData `json:"data"`
+}
+
+// EnrollTwoFactor creates a new TOTP secret for the user. It only guards
+// logins once a code for it was verified with VerifyTwoFactor.
+func (it *RouteHandler) EnrollTwoFactor(c echo.Context) error {
+	req := new(TokenRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	tf, err := it.enabledTwoFactor(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if tf != nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "2fa-already-enabled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	user, err := it.UserStore.ForID(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	err = it.TwoFactorStore.Save(&core.TwoFactor{UserID: userID, Secret: secret})
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	r := &EnrollTwoFactorResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data: EnrollTwoFactorData{
+			Secret: secret,
+			URI:    totp.URI(twoFactorIssuer, user.UserName, secret),
+		},
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type VerifyTwoFactorRequestBody struct {
+	Token core.Token `json:"token"`
+	Code  string     `json:"code"`
+}
+
+type VerifyTwoFactorData struct {
+	// RecoveryCodes are only ever returned here, the server keeps just
+	// their hashes.
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type VerifyTwoFactorResponse struct {
+	SuccessResponse
+	Data VerifyTwoFactorData `json:"data"`
+}
+
+// VerifyTwoFactor enables 2FA once the user proved their authenticator app
+// has the secret, and hands out the recovery codes.
+func (it *RouteHandler) VerifyTwoFactor(c echo.Context) error {
+	req := new(VerifyTwoFactorRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	tf, err := it.TwoFactorStore.ForUser(userID)
+	if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "2fa-not-enrolled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	} else if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if tf.Enabled {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "2fa-already-enabled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	counter, ok := totp.Validate(tf.Secret, req.Code, time.Now())
+	if !ok {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-code",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	codes, err := core.GenerateRecoveryCodes()
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	tf.Enabled = true
+	tf.LastCounter = counter
+	tf.RecoveryCodes = make([]string, 0, len(codes))
+	for _, code := range codes {
+		tf.RecoveryCodes = append(tf.RecoveryCodes, core.HashRecoveryCode(code))
+	}
+	err = it.TwoFactorStore.Save(tf)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	r := &VerifyTwoFactorResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            VerifyTwoFactorData{RecoveryCodes: codes},
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type DisableTwoFactorRequestBody struct {
+	Token    core.Token    `json:"token"`
+	Password core.Password `json:"password"`
+	Code     string        `json:"code"`
+}
+
+// DisableTwoFactor removes 2FA from the user. A stolen session alone is not
+// enough, it takes the password and a current code.
+func (it *RouteHandler) DisableTwoFactor(c echo.Context) error {
+	req := new(DisableTwoFactorRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if wait, ok := it.allowAttempt(c); !ok {
+		return it.tooManyAttempts(c, wait)
+	}
+	defer it.attemptEnded(c)
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		it.attemptFailed(c)
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	tf, err := it.enabledTwoFactor(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if tf == nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "2fa-not-enabled",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	user, err := it.UserStore.ForID(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	ok, _, err := it.passwords().Verify(user.Password, req.Password)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+	}
+	if !ok {
+		it.attemptFailed(c)
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-password",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	ok, err = it.verifyTOTP(tf, req.Code)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if !ok {
+		it.attemptFailed(c)
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-code",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	it.attemptSucceeded(c)
+	err = it.TwoFactorStore.Delete(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	r := &SuccessResponse{Status: "ok"}
+	return c.JSON(http.StatusOK, r)
+}
@@ -0,0 +1,335 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/totp"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testTwoFactorSecret = "JBSWY3DPEHPK3PXP"
+
+type twoFactorTestStores struct {
+	users      *memory.UserStore
+	tokens     *memory.TokenStore
+	twoFactors *memory.TwoFactorStore
+}
+
+// newTwoFactorTestStores creates the user "admin" with password "password123"
+// and a session with token "t1".
+func newTwoFactorTestStores(t *testing.T) *twoFactorTestStores {
+	t.Helper()
+
+	stores := &twoFactorTestStores{
+		users:      memory.NewUserStore(),
+		tokens:     memory.NewTokenStore(),
+		twoFactors: memory.NewTwoFactorStore(),
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	err = stores.users.Add(&core.User{UserID: "u1", UserName: "admin", Password: string(hash), IsAdmin: true})
+	assert.NoError(t, err)
+	err = stores.tokens.Add(&core.Session{SessionID: "s1", Token: "t1", UserID: "u1"})
+	assert.NoError(t, err)
+	return stores
+}
+
+func (it *twoFactorTestStores) enable(t *testing.T, recoveryCodes ...string) {
+	t.Helper()
+
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hashes = append(hashes, core.HashRecoveryCode(code))
+	}
+	err := it.twoFactors.Save(&core.TwoFactor{
+		UserID:        "u1",
+		Secret:        testTwoFactorSecret,
+		Enabled:       true,
+		RecoveryCodes: hashes,
+	})
+	assert.NoError(t, err)
+}
+
+// responseError returns the status and reason of an ErrorResponse.
+func responseError(t *testing.T, c echo.Context) (int, string) {
+	t.Helper()
+
+	rec := c.Response().Writer.(*httptest.ResponseRecorder)
+	var res routes.ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec.Code, res.Reason
+}
+
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Counter(time.Now()))
+	assert.NoError(t, err)
+	return code
+}
+
+func TestLogin_TwoFactor(t *testing.T) {
+	t.Run("given 2fa not enabled then logs in with password only", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		h, c, rec := setupAccountTestHandler(`{"password":"password123"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.NotEqual(t, "", res.Data.Token)
+	})
+
+	t.Run("given 2fa enabled and no code then returns 2fa-required", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		h, c, _ := setupAccountTestHandler(`{"password":"password123"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "2fa-required", reason)
+		assert.Equal(t, 1, len(stores.tokens.Sessions))
+	})
+
+	t.Run("given 2fa enabled and wrong password then does not reveal 2fa", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		h, c, rec := setupAccountTestHandler(`{"password":"wrong"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginFailResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, nil, res.Data.Token)
+	})
+
+	t.Run("given 2fa enabled and wrong code then returns invalid-2fa-code", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		h, c, _ := setupAccountTestHandler(`{"password":"password123","code":"000000x"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "invalid-2fa-code", reason)
+	})
+
+	t.Run("given 2fa enabled and current code then logs in once", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		body := fmt.Sprintf(`{"password":"password123","code":"%s"}`, currentCode(t, testTwoFactorSecret))
+
+		h, c, rec := setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		var res routes.LoginSuccessResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.NotEqual(t, "", res.Data.Token)
+
+		h, c, _ = setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "invalid-2fa-code", reason)
+	})
+
+	t.Run("given 2fa enabled and recovery code then logs in once", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t, "abcde-12345", "fghij-67890")
+		body := `{"password":"password123","code":"ABCDE-12345"}`
+
+		h, c, rec := setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+		err := h.Login(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		tf, err := stores.twoFactors.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{core.HashRecoveryCode("fghij-67890")}, tf.RecoveryCodes)
+
+		h, c, _ = setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+		err = h.Login(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "invalid-2fa-code", reason)
+	})
+}
+
+func TestEnrollTwoFactor(t *testing.T) {
+	t.Run("given no token then returns auth-error", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		h, c, _ := setupAccountTestHandler(`{}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.EnrollTwoFactor(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "auth-error", reason)
+	})
+
+	t.Run("given 2fa enabled then returns 2fa-already-enabled", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		h, c, _ := setupAccountTestHandler(`{"token":"t1"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.EnrollTwoFactor(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "2fa-already-enabled", reason)
+	})
+
+	t.Run("given enrolment then verifies code and enables 2fa", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		h, c, rec := setupAccountTestHandler(`{"token":"t1"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.EnrollTwoFactor(c)
+		assert.NoError(t, err)
+
+		var enrolled routes.EnrollTwoFactorResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &enrolled))
+		assert.NotEqual(t, "", enrolled.Data.Secret)
+		assert.True(t, strings.HasPrefix(enrolled.Data.URI, "otpauth://totp/Actual:admin?"))
+		tf, err := stores.twoFactors.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, false, tf.Enabled)
+
+		body := fmt.Sprintf(`{"token":"t1","code":"%s"}`, currentCode(t, enrolled.Data.Secret))
+		h, c, rec = setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err = h.VerifyTwoFactor(c)
+		assert.NoError(t, err)
+
+		var verified routes.VerifyTwoFactorResponse
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &verified))
+		assert.Equal(t, core.RecoveryCodeCount, len(verified.Data.RecoveryCodes))
+		tf, err = stores.twoFactors.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, true, tf.Enabled)
+		assert.Equal(t, core.HashRecoveryCode(verified.Data.RecoveryCodes[0]), tf.RecoveryCodes[0])
+	})
+}
+
+func TestVerifyTwoFactor(t *testing.T) {
+	t.Run("given no enrolment then returns 2fa-not-enrolled", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		h, c, _ := setupAccountTestHandler(`{"token":"t1","code":"123456"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.VerifyTwoFactor(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "2fa-not-enrolled", reason)
+	})
+
+	t.Run("given wrong code then returns invalid-code", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		err := stores.twoFactors.Save(&core.TwoFactor{UserID: "u1", Secret: testTwoFactorSecret})
+		assert.NoError(t, err)
+		h, c, _ := setupAccountTestHandler(`{"token":"t1","code":"abcdef"}`, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err = h.VerifyTwoFactor(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid-code", reason)
+		tf, err := stores.twoFactors.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, false, tf.Enabled)
+	})
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	t.Run("given wrong password then returns invalid-password", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		body := fmt.Sprintf(`{"token":"t1","password":"wrong","code":"%s"}`, currentCode(t, testTwoFactorSecret))
+		h, c, _ := setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.DisableTwoFactor(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid-password", reason)
+		assert.Equal(t, 1, len(stores.twoFactors.TwoFactors))
+	})
+
+	t.Run("given recovery code instead of current code then returns invalid-code", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t, "abcde-12345")
+		body := `{"token":"t1","password":"password123","code":"abcde-12345"}`
+		h, c, _ := setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.DisableTwoFactor(c)
+		assert.NoError(t, err)
+
+		code, reason := responseError(t, c)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid-code", reason)
+		assert.Equal(t, 1, len(stores.twoFactors.TwoFactors))
+	})
+
+	t.Run("given password and current code then disables 2fa", func(t *testing.T) {
+		stores := newTwoFactorTestStores(t)
+		stores.enable(t)
+		body := fmt.Sprintf(`{"token":"t1","password":"password123","code":"%s"}`, currentCode(t, testTwoFactorSecret))
+		h, c, rec := setupAccountTestHandler(body, stores.users, stores.tokens)
+		h.TwoFactorStore = stores.twoFactors
+
+		err := h.DisableTwoFactor(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 0, len(stores.twoFactors.TwoFactors))
+	})
+}
@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/upload"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type UploadSessionResponse struct {
+	SuccessResponse
+	UploadID string `json:"uploadId"`
+	Offset   int64  `json:"offset"`
+}
+
+type UploadOffsetResponse struct {
+	ErrorResponse
+	Offset int64 `json:"offset"`
+}
+
+func uploadSessionResponse(session upload.Session) *UploadSessionResponse {
+	return &UploadSessionResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		UploadID:        session.ID,
+		Offset:          session.Size,
+	}
+}
+
+// CreateUpload starts a chunked upload of a file. It takes the headers of
+// UploadUserFile, so uploads the file would reject fail before any chunk is
+// sent, and the size of the file in the `x-actual-size` header.
+func (it *RouteHandler) CreateUpload(c echo.Context) error {
+	userID, val := it.authenticateUser(c, "")
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+	if it.Uploads == nil {
+		return echo.ErrNotFound
+	}
+
+	req, reason, err := it.parseUpload(c, userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if reason != "" {
+		return c.String(http.StatusBadRequest, reason)
+	}
+	size, err := strconv.ParseInt(c.Request().Header.Get("x-actual-size"), 10, 64)
+	if err != nil || size < 0 {
+		return c.String(http.StatusBadRequest, "invalid-size")
+	}
+
+	session, err := it.Uploads.Create(userID, req.fileID, size)
+	switch {
+	case errors.Is(err, internal_errors.ErrUploadTooLarge):
+		return c.String(http.StatusRequestEntityTooLarge, "file-too-large")
+	case errors.Is(err, internal_errors.ErrUploadTooMany):
+		return c.String(http.StatusTooManyRequests, "too-many-uploads")
+	case err != nil:
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, uploadSessionResponse(session))
+}
+
+// UploadStatus returns how much of a chunked upload was received, which is
+// where a client resumes it.
+func (it *RouteHandler) UploadStatus(c echo.Context) error {
+	userID, val := it.authenticateUser(c, "")
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+	if it.Uploads == nil {
+		return echo.ErrNotFound
+	}
+
+	session, err := it.Uploads.Get(c.Param("uploadId"), userID)
+	if errors.Is(err, internal_errors.ErrUploadNotFound) {
+		return c.String(http.StatusNotFound, "upload-not-found")
+	}
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, uploadSessionResponse(session))
+}
+
+// UploadChunk appends the body to a chunked upload. The `x-actual-offset`
+// header has to match the size received so far, otherwise the chunk is
+// rejected along with the offset to resume from.
+func (it *RouteHandler) UploadChunk(c echo.Context) error {
+	userID, val := it.authenticateUser(c, "")
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+	if it.Uploads == nil {
+		return echo.ErrNotFound
+	}
+
+	offset, err := strconv.ParseInt(c.Request().Header.Get("x-actual-offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.String(http.StatusBadRequest, "invalid-offset")
+	}
+
+	session, err := it.Uploads.Write(c.Param("uploadId"), userID, offset, c.Request().Body)
+	switch {
+	case errors.Is(err, internal_errors.ErrUploadNotFound):
+		return c.String(http.StatusNotFound, "upload-not-found")
+	case errors.Is(err, internal_errors.ErrUploadOffsetMismatch):
+		r := &UploadOffsetResponse{
+			ErrorResponse: ErrorResponse{Status: "error", Reason: "offset-mismatch"},
+			Offset:        session.Size,
+		}
+		return c.JSON(http.StatusConflict, r)
+	case errors.Is(err, internal_errors.ErrUploadTooLarge):
+		r := &UploadOffsetResponse{
+			ErrorResponse: ErrorResponse{Status: "error", Reason: "file-too-large"},
+			Offset:        session.Size,
+		}
+		return c.JSON(http.StatusRequestEntityTooLarge, r)
+	case err != nil:
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	return c.JSON(http.StatusOK, uploadSessionResponse(session))
+}
+
+// FinalizeUpload replaces the file with a chunked upload, if it matches the
+// hex encoded SHA-256 checksum in the `x-actual-sha256` header. It takes the
+// headers of UploadUserFile and responds like it. Uploads that do not match
+// are discarded and have to start over.
+func (it *RouteHandler) FinalizeUpload(c echo.Context) error {
+	userID, val := it.authenticateUser(c, "")
+	if !val {
+		it.audit(c, &core.AuditEntry{Event: core.AuditUploadFile, Outcome: core.AuditFailure, FileID: c.Request().Header.Get("x-actual-file-id")})
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+	if it.Uploads == nil {
+		return echo.ErrNotFound
+	}
+
+	uploadID := c.Param("uploadId")
+	session, err := it.Uploads.Get(uploadID, userID)
+	if errors.Is(err, internal_errors.ErrUploadNotFound) {
+		return c.String(http.StatusNotFound, "upload-not-found")
+	}
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if session.FileID != c.Request().Header.Get("x-actual-file-id") {
+		return c.String(http.StatusNotFound, "upload-not-found")
+	}
+
+	// The file may have been reset or rekeyed since the upload started.
+	req, reason, err := it.parseUpload(c, userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if reason != "" {
+		it.audit(c, &core.AuditEntry{Event: core.AuditUploadFile, Outcome: core.AuditFailure, FileID: session.FileID})
+		return c.String(http.StatusBadRequest, reason)
+	}
+
+	checksum := strings.ToLower(c.Request().Header.Get("x-actual-sha256"))
+	var groupID string
+	_, err = it.Uploads.Finalize(uploadID, userID, checksum, func(session upload.Session, path string) error {
+		req.contentHash = checksum
+		req.contentSize = session.Size
+		var err error
+		// Renaming replaces the previous file at once, so downloads never
+		// see a partial one.
+		groupID, err = it.commitUpload(req, func() error {
+			return it.Config.FileSystem.Rename(path, it.blobPath(session.FileID))
+		})
+		return err
+	})
+	switch {
+	case errors.Is(err, internal_errors.ErrUploadNotFound):
+		return c.String(http.StatusNotFound, "upload-not-found")
+	case errors.Is(err, internal_errors.ErrUploadChecksumMismatch):
+		it.audit(c, &core.AuditEntry{Event: core.AuditUploadFile, Outcome: core.AuditFailure, FileID: session.FileID})
+		return c.String(http.StatusBadRequest, "checksum-mismatch")
+	case err != nil:
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	return it.uploadSucceeded(c, session.FileID, groupID)
+}
@@ -0,0 +1,280 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/upload"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupUploadTest creates file f1 of user u1 in group g1, with its uploaded
+// file holding "old".
+func setupUploadTest(t *testing.T) *routes.RouteHandler {
+	t.Helper()
+
+	db, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	tstore := sqlite.NewTokenStore(db)
+	fstore := sqlite.NewFileStore(db)
+	err = tstore.Add(&core.Session{SessionID: "s-u1", Token: "token123", UserID: "u1"})
+	assert.NoError(t, err)
+	err = tstore.Add(&core.Session{SessionID: "s-u2", Token: "token456", UserID: "u2"})
+	assert.NoError(t, err)
+	err = fstore.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "budget", Owner: "u1"})
+	assert.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/files/f1.blob", []byte("old"), 0o600))
+	config := core.Config{
+		Mode:          core.Development,
+		FileSystem:    fs,
+		UserFiles:     "/files",
+		Storage:       core.Sqlite,
+		StorageConfig: sqlite.StorageConfig{UserData: t.TempDir()},
+	}
+	return &routes.RouteHandler{
+		Config:     config,
+		TokenStore: tstore,
+		FileStore:  fstore,
+		Uploads:    upload.NewSessions(fs, config.UserFiles, time.Hour, 1024, 2),
+	}
+}
+
+// uploadRequest calls a chunked upload handler with the headers of an
+// upload of f1.
+func uploadRequest(
+	t *testing.T,
+	handler echo.HandlerFunc,
+	uploadID string,
+	body io.Reader,
+	headers map[string]string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("x-actual-token", "token123")
+	req.Header.Set("x-actual-name", "budget")
+	req.Header.Set("x-actual-file-id", "f1")
+	req.Header.Set("x-actual-group-id", "g1")
+	req.Header.Set("x-actual-format", "2")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("uploadId")
+	c.SetParamValues(uploadID)
+	assert.NoError(t, handler(c))
+	return rec
+}
+
+func createUpload(t *testing.T, h *routes.RouteHandler) string {
+	t.Helper()
+
+	rec := uploadRequest(t, h.CreateUpload, "", nil, map[string]string{"x-actual-size": "1024"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var res routes.UploadSessionResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, int64(0), res.Offset)
+	return res.UploadID
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func readBlob(t *testing.T, h *routes.RouteHandler) string {
+	t.Helper()
+
+	data, err := afero.ReadFile(h.Config.FileSystem, "/files/f1.blob")
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestChunkedUpload(t *testing.T) {
+	t.Run("given chunks and matching checksum then replaces the file", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadID := createUpload(t, h)
+
+		rec := uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new "), map[string]string{"x-actual-offset": "0"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "old", readBlob(t, h))
+		rec = uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("budget"), map[string]string{"x-actual-offset": "4"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = uploadRequest(t, h.FinalizeUpload, uploadID, nil, map[string]string{"x-actual-sha256": sha256Hex("new budget")})
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var res routes.UploadUserFileResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "ok", res.Status)
+		assert.Equal(t, "g1", res.GroupID)
+		assert.Equal(t, "new budget", readBlob(t, h))
+	})
+
+	t.Run("given resumed upload then continues from the received offset", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadID := createUpload(t, h)
+		uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new "), map[string]string{"x-actual-offset": "0"})
+
+		rec := uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new budget"), map[string]string{"x-actual-offset": "0"})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var conflict routes.UploadOffsetResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conflict))
+		assert.Equal(t, "offset-mismatch", conflict.Reason)
+		assert.Equal(t, int64(4), conflict.Offset)
+
+		rec = uploadRequest(t, h.UploadStatus, uploadID, nil, nil)
+		var status routes.UploadSessionResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, int64(4), status.Offset)
+
+		uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("budget"), map[string]string{"x-actual-offset": "4"})
+		rec = uploadRequest(t, h.FinalizeUpload, uploadID, nil, map[string]string{"x-actual-sha256": sha256Hex("new budget")})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "new budget", readBlob(t, h))
+	})
+
+	t.Run("given wrong checksum then keeps the file", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadID := createUpload(t, h)
+		uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new"), map[string]string{"x-actual-offset": "0"})
+
+		rec := uploadRequest(t, h.FinalizeUpload, uploadID, nil, map[string]string{"x-actual-sha256": sha256Hex("other")})
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "checksum-mismatch", rec.Body.String())
+		assert.Equal(t, "old", readBlob(t, h))
+		rec = uploadRequest(t, h.UploadStatus, uploadID, nil, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("given file reset during the upload then returns file-has-reset", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadID := createUpload(t, h)
+		uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new"), map[string]string{"x-actual-offset": "0"})
+		assert.NoError(t, h.FileStore.UpdateGroup("f1", "g2"))
+
+		rec := uploadRequest(t, h.FinalizeUpload, uploadID, nil, map[string]string{"x-actual-sha256": sha256Hex("new")})
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-has-reset", rec.Body.String())
+		assert.Equal(t, "old", readBlob(t, h))
+	})
+
+	t.Run("given upload of another user then returns upload-not-found", func(t *testing.T) {
+		h := setupUploadTest(t)
+		uploadID := createUpload(t, h)
+
+		rec := uploadRequest(t, h.UploadChunk, uploadID, strings.NewReader("new"), map[string]string{
+			"x-actual-token":  "token456",
+			"x-actual-offset": "0",
+		})
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "upload-not-found", rec.Body.String())
+	})
+
+	t.Run("given file of another user then rejects the session", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := uploadRequest(t, h.CreateUpload, "", nil, map[string]string{"x-actual-token": "token456"})
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "file-not-found", rec.Body.String())
+		assert.Equal(t, 0, h.Uploads.Len())
+	})
+
+	t.Run("given no size then rejects the session", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := uploadRequest(t, h.CreateUpload, "", nil, nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalid-size", rec.Body.String())
+		assert.Equal(t, 0, h.Uploads.Len())
+	})
+
+	t.Run("given size above the maximum then rejects the session", func(t *testing.T) {
+		h := setupUploadTest(t)
+
+		rec := uploadRequest(t, h.CreateUpload, "", nil, map[string]string{"x-actual-size": "1025"})
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, "file-too-large", rec.Body.String())
+		assert.Equal(t, 0, h.Uploads.Len())
+	})
+
+	t.Run("given too many sessions then rejects another one", func(t *testing.T) {
+		h := setupUploadTest(t)
+		createUpload(t, h)
+		createUpload(t, h)
+
+		rec := uploadRequest(t, h.CreateUpload, "", nil, map[string]string{"x-actual-size": "1024"})
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "too-many-uploads", rec.Body.String())
+		assert.Equal(t, 2, h.Uploads.Len())
+	})
+
+	t.Run("given chunk beyond the declared size then returns the received offset", func(t *testing.T) {
+		h := setupUploadTest(t)
+		rec := uploadRequest(t, h.CreateUpload, "", nil, map[string]string{"x-actual-size": "3"})
+		var session routes.UploadSessionResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+
+		rec = uploadRequest(t, h.UploadChunk, session.UploadID, strings.NewReader("new budget"), map[string]string{"x-actual-offset": "0"})
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		var res routes.UploadOffsetResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "file-too-large", res.Reason)
+		assert.Equal(t, int64(3), res.Offset)
+	})
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestUploadUserFile_Interrupted(t *testing.T) {
+	t.Run("given body cut off then keeps the previous file", func(t *testing.T) {
+		h := setupUploadTest(t)
+		h.Uploads = nil
+
+		req := httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("new"), failingReader{}))
+		req.Header.Set("x-actual-token", "token123")
+		req.Header.Set("x-actual-name", "budget")
+		req.Header.Set("x-actual-file-id", "f1")
+		req.Header.Set("x-actual-group-id", "g1")
+		req.Header.Set("x-actual-format", "2")
+		rec := httptest.NewRecorder()
+
+		err := h.UploadUserFile(echo.New().NewContext(req, rec))
+
+		assert.Error(t, err)
+		assert.Equal(t, "old", readBlob(t, h))
+		infos, err := afero.ReadDir(h.Config.FileSystem, "/files")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(infos))
+	})
+}
@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type CreateUserRequestBody struct {
+	Token    core.Token    `json:"token"`
+	UserName string        `json:"userName"`
+	Password core.Password `json:"password"`
+	IsAdmin  bool          `json:"isAdmin"`
+}
+
+type UserResponseData struct {
+	UserID   core.UserID `json:"userId"`
+	UserName string      `json:"userName"`
+	IsAdmin  bool        `json:"isAdmin"`
+}
+
+type CreateUserResponse struct {
+	SuccessResponse
+	Data UserResponseData `json:"data"`
+}
+
+func (it *RouteHandler) CreateUser(c echo.Context) error {
+	req := new(CreateUserRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	admin, err := it.isAdmin(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if !admin {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "permission-denied",
+		}
+		return c.JSON(http.StatusForbidden, r)
+	}
+
+	if req.UserName == "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-username",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+	if req.Password == "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "invalid-password",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	if reason := it.passwordPolicyReason(req.Password); reason != "" {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: reason,
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+
+	_, err = it.UserStore.ForUserName(req.UserName)
+	if err == nil {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "user-exists",
+		}
+		return c.JSON(http.StatusBadRequest, r)
+	}
+	if !errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	hash, err := it.passwords().Hash(req.Password)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	user := &core.User{
+		UserID:   uuid.NewString(),
+		UserName: req.UserName,
+		Password: hash,
+		IsAdmin:  req.IsAdmin,
+	}
+	err = it.UserStore.Add(user)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+
+	r := &CreateUserResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data: UserResponseData{
+			UserID:   user.UserID,
+			UserName: user.UserName,
+			IsAdmin:  user.IsAdmin,
+		},
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+type ListUsersResponse struct {
+	SuccessResponse
+	Data []UserResponseData `json:"data"`
+}
+
+func (it *RouteHandler) ListUsers(c echo.Context) error {
+	req := new(TokenRequestBody)
+	if err := c.Bind(req); err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	userID, val := it.authenticateUser(c, req.Token)
+	if !val {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "auth-error",
+		}
+		return c.JSON(http.StatusUnauthorized, r)
+	}
+
+	admin, err := it.isAdmin(userID)
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	if !admin {
+		r := &ErrorResponse{
+			Status: "error",
+			Reason: "permission-denied",
+		}
+		return c.JSON(http.StatusForbidden, r)
+	}
+
+	users, err := it.UserStore.All()
+	if err != nil {
+		c.Echo().Logger.Error(err)
+		return err
+	}
+	usersRes := make([]UserResponseData, 0, len(users))
+	for _, user := range users {
+		usersRes = append(usersRes, UserResponseData{
+			UserID:   user.UserID,
+			UserName: user.UserName,
+			IsAdmin:  user.IsAdmin,
+		})
+	}
+
+	r := &ListUsersResponse{
+		SuccessResponse: SuccessResponse{Status: "ok"},
+		Data:            usersRes,
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (it *RouteHandler) isAdmin(userID core.UserID) (bool, error) {
+	user, err := it.UserStore.ForID(userID)
+	if err != nil {
+		if errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return user.IsAdmin, nil
+}
+
+// linkedUser returns the user linked to an external identity, creating it on
+// the first login of the identity. Names already held by another user are
+// never taken over, so an identity cannot sign in to a password account.
+// Linked users start without admin rights.
+func (it *RouteHandler) linkedUser(externalID, userName string) (*core.User, error) {
+	user, err := it.UserStore.ForExternalID(externalID)
+	if err == nil {
+		return user, nil
+	} else if !errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		return nil, err
+	}
+
+	_, err = it.UserStore.ForUserName(userName)
+	if err == nil {
+		return nil, fmt.Errorf("%w: %s", internal_errors.ErrUserNameTaken, userName)
+	} else if !errors.Is(err, internal_errors.ErrStorageRecordNotFound) {
+		return nil, err
+	}
+
+	// An empty password never matches, so the user can only sign in through
+	// the external identity.
+	user = &core.User{
+		UserID:     uuid.NewString(),
+		UserName:   userName,
+		Password:   "",
+		ExternalID: externalID,
+	}
+	if err = it.UserStore.Add(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
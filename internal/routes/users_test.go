@@ -0,0 +1,134 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/routes"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUser(t *testing.T) {
+	t.Run("given no token then returns error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"userName":"bob","password":"pass"}`, uStore, tStore)
+
+		var res routes.ErrorResponse
+		err := h.CreateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "auth-error", res.Reason)
+	})
+
+	t.Run("given token of non-admin then returns error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t2","userName":"carol","password":"pass"}`, uStore, tStore)
+
+		err := uStore.Add(&core.User{UserID: "u2", UserName: "bob", Password: "hash"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s-u2", Token: "t2", UserID: "u2"})
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		err = h.CreateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "permission-denied", res.Reason)
+	})
+
+	t.Run("given existing user name then returns error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","userName":"admin","password":"pass"}`, uStore, tStore)
+
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.ErrorResponse
+		err = h.CreateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "user-exists", res.Reason)
+	})
+
+	t.Run("given admin token then creates user", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1","userName":"bob","password":"pass"}`, uStore, tStore)
+
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.CreateUserResponse
+		err = h.CreateUser(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "ok", res.Status)
+		assert.Equal(t, "bob", res.Data.UserName)
+		assert.Equal(t, false, res.Data.IsAdmin)
+
+		user, err := uStore.ForUserName("bob")
+		assert.NoError(t, err)
+		assert.Equal(t, res.Data.UserID, user.UserID)
+		assert.NotEqual(t, "pass", user.Password)
+	})
+}
+
+func TestListUsers(t *testing.T) {
+	t.Run("given token of non-admin then returns error", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t2"}`, uStore, tStore)
+
+		err := uStore.Add(&core.User{UserID: "u2", UserName: "bob", Password: "hash"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s-u2", Token: "t2", UserID: "u2"})
+		assert.NoError(t, err)
+
+		err = h.ListUsers(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("given admin token then returns users", func(t *testing.T) {
+		uStore := memory.NewUserStore()
+		tStore := memory.NewTokenStore()
+		h, c, rec := setupAccountTestHandler(`{"token":"t1"}`, uStore, tStore)
+
+		err := uStore.Add(&core.User{UserID: "u1", UserName: "admin", Password: "hash", IsAdmin: true})
+		assert.NoError(t, err)
+		err = uStore.Add(&core.User{UserID: "u2", UserName: "bob", Password: "hash"})
+		assert.NoError(t, err)
+		err = tStore.Add(&core.Session{SessionID: "s-u1", Token: "t1", UserID: "u1"})
+		assert.NoError(t, err)
+
+		var res routes.ListUsersResponse
+		err = h.ListUsers(c)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, 2, len(res.Data))
+		assert.Equal(t, routes.UserResponseData{UserID: "u1", UserName: "admin", IsAdmin: true}, res.Data[0])
+		assert.Equal(t, routes.UserResponseData{UserID: "u2", UserName: "bob", IsAdmin: false}, res.Data[1])
+	})
+}
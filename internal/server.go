@@ -1,13 +1,26 @@
 package internal
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/events"
+	"github.com/nathanjisaac/actual-server-go/internal/core/lock"
+	"github.com/nathanjisaac/actual-server-go/internal/core/openid"
+	"github.com/nathanjisaac/actual-server-go/internal/core/password"
+	"github.com/nathanjisaac/actual-server-go/internal/core/throttle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/upload"
 	"github.com/nathanjisaac/actual-server-go/internal/routes"
 	"github.com/nathanjisaac/actual-server-go/internal/storage"
 )
@@ -21,16 +34,108 @@ func setHeaders(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// ipExtractor resolves the client IP from X-Forwarded-For only for requests
+// coming through one of the trusted proxies.
+func ipExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipNet := range trustedProxies {
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// auditPruneInterval is how often audit log entries past their retention
+// are removed.
+const auditPruneInterval = time.Hour
+
+// pruneAuditLog removes audit log entries older than the retention period,
+// once at startup and then periodically.
+func pruneAuditLog(store core.AuditStore, retention time.Duration, logger echo.Logger) {
+	ticker := time.NewTicker(auditPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := store.DeleteBefore(time.Now().Add(-retention)); err != nil {
+			logger.Error(err)
+		}
+		<-ticker.C
+	}
+}
+
+// compactGroups compacts the sync groups of all files, once at startup and
+// then periodically. Files are locked while compacted, as for syncs.
+func compactGroups(
+	groups *storage.GroupManager,
+	locks *lock.KeyedMutex,
+	files core.FileStore,
+	retention, interval time.Duration,
+	logger echo.Logger,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		all, err := files.All()
+		if err == nil {
+			var compactions []*storage.GroupCompaction
+			compactions, err = storage.CompactGroups(groups, locks, all, retention)
+			for _, compaction := range compactions {
+				if compaction.Messages > 0 {
+					logger.Infof("compacted %d messages (%d bytes) of file %s",
+						compaction.Messages, compaction.Bytes, compaction.FileID)
+				}
+			}
+		}
+		if err != nil {
+			logger.Error(err)
+		}
+		<-ticker.C
+	}
+}
+
+// uploadSweepInterval is how often abandoned chunked uploads are removed.
+const uploadSweepInterval = 10 * time.Minute
+
+// sweepUploads removes abandoned chunked uploads, once at startup and then
+// periodically.
+func sweepUploads(uploads *upload.Sessions, logger echo.Logger) {
+	ticker := time.NewTicker(uploadSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := uploads.Sweep(); err != nil {
+			logger.Error(err)
+		}
+		<-ticker.C
+	}
+}
+
+// shutdownTimeout is how long running requests may take to finish once the
+// server is stopped.
+const shutdownTimeout = 10 * time.Second
+
 func StartServer(config core.Config, buildDirectory embed.FS, headless bool, logs bool) {
 	e := echo.New()
 	e.HideBanner = true
 
+	e.IPExtractor = ipExtractor(config.TrustedProxies)
+
 	e.Use(middleware.CORS())
 	e.Use(setHeaders)
 
 	if logs {
+		// Only the path is logged, as the query of /sync/events may carry
+		// a session token.
 		e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-			Format: "method=${method}, uri=${uri}, status=${status}\n",
+			Format: "method=${method}, path=${path}, status=${status}\n",
 		}))
 	}
 
@@ -42,17 +147,53 @@ func StartServer(config core.Config, buildDirectory embed.FS, headless bool, log
 		}))
 	}
 
-	conn, pStore, tStore, fStore, err := storage.NewAccountStores(config.Storage, config.StorageConfig)
+	conn, uStore, tStore, fStore, kStore, tfStore, aStore, err := storage.NewAccountStores(config.Storage, config.StorageConfig)
 	if err != nil {
 		e.Logger.Fatal(err)
 	}
 	defer conn.Close()
 
+	err = storage.MigrateGroupStores(config.Storage, config.StorageConfig, fStore)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+
 	handler := routes.RouteHandler{
-		Config:        config,
-		FileStore:     fStore,
-		TokenStore:    tStore,
-		PasswordStore: pStore,
+		Config:         config,
+		FileStore:      fStore,
+		TokenStore:     tStore,
+		UserStore:      uStore,
+		APIKeyStore:    kStore,
+		TwoFactorStore: tfStore,
+		AuditStore:     aStore,
+	}
+	handler.AuthLimiter = throttle.NewLimiter(config.LoginThrottle)
+	handler.Events = events.NewHub()
+	handler.Groups = storage.NewGroupManager(
+		config.Storage,
+		config.StorageConfig,
+		config.GroupPoolSize,
+		config.GroupIdleTimeout,
+	)
+	defer handler.Groups.Close()
+	handler.FileLocks = lock.NewKeyedMutex()
+	handler.Uploads = upload.NewSessions(
+		config.FileSystem,
+		config.UserFiles,
+		config.UploadTimeout,
+		config.UploadMaxSize,
+		config.UploadMaxSessions,
+	)
+	// No upload survives a restart, so the files of earlier ones can go.
+	if err = handler.Uploads.RemoveOrphans(); err != nil {
+		e.Logger.Error(err)
+	}
+	handler.Passwords, err = password.NewHasher(config.PasswordHash)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	if config.OpenID.Enabled() {
+		handler.OpenID = openid.NewClient(config.OpenID, &http.Client{Timeout: openid.RequestTimeout})
 	}
 	e.GET("/mode", handler.GetMode)
 
@@ -62,18 +203,73 @@ func StartServer(config core.Config, buildDirectory embed.FS, headless bool, log
 	account.POST("/login", handler.Login)
 	account.POST("/change-password", handler.ChangePassword)
 	account.GET("/validate", handler.ValidateUser)
+	account.POST("/logout", handler.Logout)
+	account.GET("/sessions", handler.ListSessions)
+	account.POST("/sessions/revoke", handler.RevokeSession)
+	account.POST("/create-user", handler.CreateUser, handler.RequireScope(core.ScopeAdmin))
+	account.GET("/list-users", handler.ListUsers, handler.RequireScope(core.ScopeAdmin))
+	account.GET("/api-keys", handler.ListAPIKeys)
+	account.POST("/api-keys/create", handler.CreateAPIKey)
+	account.POST("/api-keys/revoke", handler.RevokeAPIKey)
+	account.POST("/2fa/enroll", handler.EnrollTwoFactor)
+	account.POST("/2fa/verify", handler.VerifyTwoFactor)
+	account.POST("/2fa/disable", handler.DisableTwoFactor)
+
+	oid := e.Group("/openid")
+	oid.GET("/login", handler.OpenIDLogin)
+	oid.GET("/callback", handler.OpenIDCallback)
+	oid.POST("/2fa", handler.OpenIDTwoFactor)
+
+	admin := e.Group("/admin")
+	admin.GET("/audit", handler.ListAuditLog, handler.RequireScope(core.ScopeAdmin))
 
 	sync := e.Group("/sync")
-	sync.POST("/sync", handler.SyncFile)
-	sync.POST("/user-create-key", handler.UserCreateKey)
-	sync.POST("/user-get-key", handler.UserGetKey)
-	sync.POST("/reset-user-file", handler.ResetUserFile)
-	sync.POST("/update-user-filename", handler.UpdateUserFileName)
-	sync.GET("/get-user-file-info", handler.UserFileInfo)
-	sync.GET("/list-user-files", handler.ListUserFiles)
-	sync.POST("/upload-user-file", handler.UploadUserFile)
-	sync.GET("/download-user-file", handler.DownloadUserFile)
-	sync.POST("/delete-user-file", handler.DeleteUserFile)
-
-	e.Logger.Fatal(e.Start(fmt.Sprintf("%v:%v", config.Hostname, config.Port)))
+	filesRead := handler.RequireScope(core.ScopeFilesRead)
+	filesWrite := handler.RequireScope(core.ScopeFilesWrite)
+	sync.POST("/sync", handler.SyncFile, handler.RequireScope(core.ScopeSync))
+	sync.POST("/diagnose", handler.DiagnoseSync, handler.RequireScope(core.ScopeSync))
+	sync.GET("/events", handler.FileEvents, handler.RequireScope(core.ScopeSync))
+	sync.GET("/formats", handler.SyncFormats)
+	sync.POST("/user-create-key", handler.UserCreateKey, filesWrite)
+	sync.POST("/user-get-key", handler.UserGetKey, filesRead)
+	sync.POST("/reset-user-file", handler.ResetUserFile, filesWrite)
+	sync.POST("/update-user-filename", handler.UpdateUserFileName, filesWrite)
+	sync.GET("/get-user-file-info", handler.UserFileInfo, filesRead)
+	sync.GET("/list-user-files", handler.ListUserFiles, filesRead)
+	sync.POST("/upload-user-file", handler.UploadUserFile, filesWrite)
+	sync.POST("/uploads", handler.CreateUpload, filesWrite)
+	sync.GET("/uploads/:uploadId", handler.UploadStatus, filesWrite)
+	sync.PUT("/uploads/:uploadId", handler.UploadChunk, filesWrite)
+	sync.POST("/uploads/:uploadId/finalize", handler.FinalizeUpload, filesWrite)
+	sync.GET("/download-user-file", handler.DownloadUserFile, filesRead)
+	sync.POST("/delete-user-file", handler.DeleteUserFile, filesWrite)
+
+	if config.AuditRetention > 0 {
+		go pruneAuditLog(aStore, config.AuditRetention, e.Logger)
+	}
+	go sweepUploads(handler.Uploads, e.Logger)
+	if config.CompactionInterval > 0 {
+		go compactGroups(handler.Groups, handler.FileLocks, fStore, config.CompactionRetention, config.CompactionInterval, e.Logger)
+	}
+
+	// Event streams only end with their clients, so they are closed to let
+	// the server shut down.
+	e.Server.RegisterOnShutdown(handler.Events.Close)
+
+	go func() {
+		err := e.Start(fmt.Sprintf("%v:%v", config.Hostname, config.Port))
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		e.Logger.Error(err)
+	}
 }
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/spf13/afero"
+)
+
+// The outcomes of verifying an uploaded file.
+const (
+	BlobOK       = "ok"
+	BlobMissing  = "missing"
+	BlobSize     = "size-mismatch"
+	BlobChecksum = "checksum-mismatch"
+	// BlobUnknown is the outcome for files uploaded before their content
+	// was recorded.
+	BlobUnknown = "unknown"
+)
+
+// BlobPath returns the path of the uploaded file of a budget.
+func BlobPath(dir string, fileID core.FileID) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.blob", fileID))
+}
+
+// BlobDigest returns the hex encoded SHA-256 and the size of the content.
+func BlobDigest(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// CheckBlob compares content to what was recorded for the file. It returns
+// ErrBlobCorrupted if they differ.
+func CheckBlob(file *core.File, r io.Reader) error {
+	_, err := checkBlob(file, r)
+	return err
+}
+
+func checkBlob(file *core.File, r io.Reader) (string, error) {
+	if file.ContentHash == "" {
+		return BlobUnknown, nil
+	}
+	hash, size, err := BlobDigest(r)
+	if err != nil {
+		return "", err
+	}
+	if size != file.ContentSize {
+		return BlobSize, fmt.Errorf("%w: %d bytes instead of %d", internal_errors.ErrBlobCorrupted, size, file.ContentSize)
+	}
+	if hash != file.ContentHash {
+		return BlobChecksum, fmt.Errorf("%w: checksum %s instead of %s", internal_errors.ErrBlobCorrupted, hash, file.ContentHash)
+	}
+	return BlobOK, nil
+}
+
+// BlobVerification is the outcome of verifying the uploaded file of a budget.
+type BlobVerification struct {
+	FileID core.FileID
+	Status string
+	// Err describes why the file does not match.
+	Err error
+}
+
+// VerifyBlobs reads the uploaded file of each budget and compares it to its
+// recorded content. Files that do not match are reported, not returned as
+// errors, so one corrupted file does not stop the others from being checked.
+func VerifyBlobs(fs afero.Fs, dir string, files []*core.File) ([]*BlobVerification, error) {
+	verifications := make([]*BlobVerification, 0, len(files))
+	for _, file := range files {
+		verification := &BlobVerification{FileID: file.FileID}
+		blob, err := fs.Open(BlobPath(dir, file.FileID))
+		if errors.Is(err, os.ErrNotExist) {
+			verification.Status, verification.Err = BlobMissing, err
+			verifications = append(verifications, verification)
+			continue
+		}
+		if err != nil {
+			return verifications, fmt.Errorf("file %s: %w", file.FileID, err)
+		}
+
+		verification.Status, verification.Err = checkBlob(file, blob)
+		blob.Close()
+		if verification.Status == "" {
+			return verifications, fmt.Errorf("file %s: %w", file.FileID, verification.Err)
+		}
+		verifications = append(verifications, verification)
+	}
+	return verifications, nil
+}
@@ -0,0 +1,52 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package storage_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyBlobs(t *testing.T) {
+	t.Run("given blobs then reports those not matching their content", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		hash, size, err := storage.BlobDigest(bytes.NewReader([]byte("budget")))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6), size)
+		for _, name := range []string{"ok", "truncated", "flipped", "legacy"} {
+			assert.NoError(t, afero.WriteFile(fs, storage.BlobPath("/files", name), []byte("budget"), 0o600))
+		}
+		assert.NoError(t, afero.WriteFile(fs, storage.BlobPath("/files", "truncated"), []byte("bud"), 0o600))
+		assert.NoError(t, afero.WriteFile(fs, storage.BlobPath("/files", "flipped"), []byte("budgeT"), 0o600))
+		files := []*core.File{
+			{FileID: "ok", ContentHash: hash, ContentSize: size},
+			{FileID: "truncated", ContentHash: hash, ContentSize: size},
+			{FileID: "flipped", ContentHash: hash, ContentSize: size},
+			{FileID: "legacy"},
+			{FileID: "missing", ContentHash: hash, ContentSize: size},
+		}
+
+		verifications, err := storage.VerifyBlobs(fs, "/files", files)
+
+		assert.NoError(t, err)
+		statuses := map[core.FileID]string{}
+		for _, v := range verifications {
+			statuses[v.FileID] = v.Status
+		}
+		assert.Equal(t, map[core.FileID]string{
+			"ok":        storage.BlobOK,
+			"truncated": storage.BlobSize,
+			"flipped":   storage.BlobChecksum,
+			"legacy":    storage.BlobUnknown,
+			"missing":   storage.BlobMissing,
+		}, statuses)
+		assert.ErrorIs(t, verifications[1].Err, internal_errors.ErrBlobCorrupted)
+		assert.ErrorIs(t, verifications[2].Err, internal_errors.ErrBlobCorrupted)
+		assert.NoError(t, verifications[0].Err)
+	})
+}
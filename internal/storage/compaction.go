@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/lock"
+)
+
+const (
+	DefaultCompactionRetention = 30 * 24 * time.Hour
+	DefaultCompactionInterval  = 24 * time.Hour
+)
+
+// GroupCompaction reports the compaction of the sync group of a file.
+type GroupCompaction struct {
+	FileID  core.FileID
+	GroupID string
+	core.CompactionResult
+}
+
+// CompactGroups compacts the sync groups of the files. Files awaiting an
+// upload after a reset and groups without messages are skipped. With locks,
+// each file is locked while its group is compacted, so a sync or reset of
+// the file waits for it.
+func CompactGroups(
+	groups *GroupManager,
+	locks *lock.KeyedMutex,
+	files []*core.File,
+	retention time.Duration,
+) ([]*GroupCompaction, error) {
+	compactions := make([]*GroupCompaction, 0, len(files))
+	for _, file := range files {
+		if file.GroupID == "" {
+			continue
+		}
+		compaction, err := compactGroup(groups, locks, file, retention)
+		if err != nil {
+			return compactions, fmt.Errorf("file %s: %w", file.FileID, err)
+		}
+		if compaction != nil {
+			compactions = append(compactions, compaction)
+		}
+	}
+	return compactions, nil
+}
+
+func compactGroup(
+	groups *GroupManager,
+	locks *lock.KeyedMutex,
+	file *core.File,
+	retention time.Duration,
+) (*GroupCompaction, error) {
+	if locks != nil {
+		unlock := locks.Lock(file.FileID)
+		defer unlock()
+	}
+	// A reset while waiting for the lock removed the database, which must
+	// not be created again.
+	_, err := os.Stat(groupDataSource(groups.config, file.GroupID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	stores, release, err := groups.Acquire(file.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := CompactMessagesTransaction(groups.storageType, stores.Connection, retention)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupCompaction{
+		FileID:           file.FileID,
+		GroupID:          file.GroupID,
+		CompactionResult: *result,
+	}, nil
+}
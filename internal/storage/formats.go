@@ -0,0 +1,8 @@
+package storage
+
+// The sync format versions files may sync with. Files of older versions
+// have to be reset first.
+const (
+	MinSyncFormatVersion int16 = 2
+	MaxSyncFormatVersion int16 = 2
+)
@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+const (
+	DefaultGroupPoolSize    = 64
+	DefaultGroupIdleTimeout = 5 * time.Minute
+)
+
+var ErrGroupManagerClosed = errors.New("group manager is closed")
+
+// GroupStores are the stores of the message database of one sync group.
+type GroupStores struct {
+	Connection core.Connection
+	Merkles    core.MerkleStore
+	Messages   core.MessageStore
+	Replica    core.ReplicaStore
+	Compaction core.CompactionStore
+}
+
+// openGroupStores opens the message database of a group, migrating it unless
+// migrated is set.
+func openGroupStores(
+	storageType core.StorageType,
+	config core.StorageConfig,
+	groupID string,
+	migrated bool,
+) (*GroupStores, error) {
+	open := NewGroupStores
+	if migrated {
+		open = OpenGroupStores
+	}
+	conn, merkles, messages, replica, compaction, err := open(storageType, config, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupStores{
+		Connection: conn,
+		Merkles:    merkles,
+		Messages:   messages,
+		Replica:    replica,
+		Compaction: compaction,
+	}, nil
+}
+
+type groupEntry struct {
+	groupID string
+	elem    *list.Element
+	// ready is closed once stores or err is set.
+	ready    chan struct{}
+	stores   *GroupStores
+	err      error
+	refs     int
+	lastUsed time.Time
+	evicted  bool
+}
+
+// GroupManager keeps the message databases of recently synced files open, so
+// requests do not open and migrate them every time. Each database is migrated
+// once per process. Databases are closed when they are the least recently used
+// one and the pool is full, or once idle for longer than the idle timeout.
+// Databases still in use are only closed when released.
+type GroupManager struct {
+	storageType core.StorageType
+	config      core.StorageConfig
+	size        int
+	idleTimeout time.Duration
+	// open opens the stores of a group, migrating them unless migrated.
+	open func(storageType core.StorageType, config core.StorageConfig, groupID string, migrated bool) (*GroupStores, error)
+
+	mu       sync.Mutex
+	entries  map[string]*groupEntry
+	lru      *list.List
+	migrated map[string]bool
+	closed   bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewGroupManager returns a manager that keeps up to size databases open.
+// Non-positive values select the defaults.
+func NewGroupManager(
+	storageType core.StorageType,
+	config core.StorageConfig,
+	size int,
+	idleTimeout time.Duration,
+) *GroupManager {
+	if size <= 0 {
+		size = DefaultGroupPoolSize
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultGroupIdleTimeout
+	}
+	it := &GroupManager{
+		storageType: storageType,
+		config:      config,
+		size:        size,
+		idleTimeout: idleTimeout,
+		open:        openGroupStores,
+		entries:     map[string]*groupEntry{},
+		lru:         list.New(),
+		migrated:    map[string]bool{},
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go it.sweep()
+	return it
+}
+
+// Acquire returns the stores of a group along with a function that has to be
+// called once the caller is done with them.
+func (it *GroupManager) Acquire(groupID string) (*GroupStores, func(), error) {
+	it.mu.Lock()
+	if it.closed {
+		it.mu.Unlock()
+		return nil, nil, ErrGroupManagerClosed
+	}
+
+	entry, ok := it.entries[groupID]
+	if ok {
+		entry.refs++
+		it.lru.MoveToFront(entry.elem)
+		it.mu.Unlock()
+		<-entry.ready
+	} else {
+		entry = &groupEntry{groupID: groupID, ready: make(chan struct{}), refs: 1}
+		entry.elem = it.lru.PushFront(entry)
+		it.entries[groupID] = entry
+		migrated := it.migrated[groupID]
+		it.mu.Unlock()
+
+		// Other files stay available while this one is opened.
+		stores, err := it.open(it.storageType, it.config, groupID, migrated)
+
+		it.mu.Lock()
+		entry.stores, entry.err = stores, err
+		if err != nil {
+			it.remove(entry)
+		} else if !entry.evicted {
+			// A database deleted while it was opened is created anew on
+			// the next use and has to be migrated again.
+			it.migrated[groupID] = true
+		}
+		close(entry.ready)
+		it.evictOverflow()
+		it.mu.Unlock()
+	}
+
+	if entry.err != nil {
+		it.release(entry)
+		return nil, nil, entry.err
+	}
+	var once sync.Once
+	return entry.stores, func() { once.Do(func() { it.release(entry) }) }, nil
+}
+
+// Evict closes the database of a group, once no longer in use.
+func (it *GroupManager) Evict(groupID string) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if entry, ok := it.entries[groupID]; ok {
+		it.evict(entry)
+	}
+}
+
+// Delete closes and removes the database of a group. Databases still in use
+// are removed right away and closed once released.
+func (it *GroupManager) Delete(groupID string) error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if entry, ok := it.entries[groupID]; ok {
+		if err := it.evict(entry); err != nil {
+			return err
+		}
+	}
+	delete(it.migrated, groupID)
+	return DeleteGroupStores(it.storageType, it.config, groupID)
+}
+
+// Len returns the number of databases in the pool.
+func (it *GroupManager) Len() int {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return it.lru.Len()
+}
+
+// Close closes all databases. Databases still in use are closed as soon as
+// they are released.
+func (it *GroupManager) Close() error {
+	it.mu.Lock()
+	if it.closed {
+		it.mu.Unlock()
+		return nil
+	}
+	it.closed = true
+	close(it.stop)
+
+	var err error
+	for elem := it.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if closeErr := it.evict(elem.Value.(*groupEntry)); err == nil {
+			err = closeErr
+		}
+		elem = next
+	}
+	it.mu.Unlock()
+
+	<-it.done
+	return err
+}
+
+func (it *GroupManager) release(entry *groupEntry) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	entry.refs--
+	entry.lastUsed = time.Now()
+	if entry.evicted && entry.refs == 0 && entry.stores != nil {
+		_ = entry.stores.Connection.Close()
+	}
+}
+
+// evict removes an entry from the pool and closes its database unless it is
+// in use. Callers must hold the lock.
+func (it *GroupManager) evict(entry *groupEntry) error {
+	it.remove(entry)
+	entry.evicted = true
+	if entry.refs == 0 && entry.stores != nil {
+		return entry.stores.Connection.Close()
+	}
+	return nil
+}
+
+func (it *GroupManager) remove(entry *groupEntry) {
+	if it.entries[entry.groupID] == entry {
+		delete(it.entries, entry.groupID)
+		it.lru.Remove(entry.elem)
+	}
+}
+
+// evictOverflow closes least recently used databases that are not in use
+// until the pool fits its size. Callers must hold the lock.
+func (it *GroupManager) evictOverflow() {
+	for elem := it.lru.Back(); elem != nil && it.lru.Len() > it.size; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*groupEntry); entry.refs == 0 {
+			_ = it.evict(entry)
+		}
+		elem = prev
+	}
+}
+
+// sweep closes databases that were idle for longer than the idle timeout.
+// They are closed between one and two idle timeouts after their last use.
+func (it *GroupManager) sweep() {
+	defer close(it.done)
+
+	ticker := time.NewTicker(it.idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-it.stop:
+			return
+		case now := <-ticker.C:
+			it.mu.Lock()
+			for elem := it.lru.Back(); elem != nil; {
+				prev := elem.Prev()
+				entry := elem.Value.(*groupEntry)
+				if entry.refs == 0 && now.Sub(entry.lastUsed) >= it.idleTimeout {
+					_ = it.evict(entry)
+				}
+				elem = prev
+			}
+			it.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupManager_DeleteWhileOpening(t *testing.T) {
+	t.Run("given group deleted during its first open then migrates it again", func(t *testing.T) {
+		config := sqlite.StorageConfig{UserData: t.TempDir()}
+		manager := NewGroupManager(core.Sqlite, config, 2, time.Hour)
+		t.Cleanup(func() { _ = manager.Close() })
+
+		opening := make(chan struct{})
+		deleted := make(chan struct{})
+		manager.open = func(
+			storageType core.StorageType,
+			config core.StorageConfig,
+			groupID string,
+			migrated bool,
+		) (*GroupStores, error) {
+			stores, err := openGroupStores(storageType, config, groupID, migrated)
+			close(opening)
+			<-deleted
+			return stores, err
+		}
+
+		acquired := make(chan struct{})
+		go func() {
+			defer close(acquired)
+			_, release, err := manager.Acquire("g1")
+			assert.NoError(t, err)
+			release()
+		}()
+
+		<-opening
+		assert.NoError(t, manager.Delete("g1"))
+		close(deleted)
+		<-acquired
+
+		manager.open = openGroupStores
+		g1, release, err := manager.Acquire("g1")
+		assert.NoError(t, err)
+		defer release()
+		_, err = g1.Messages.GetSince("", 0)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,236 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package storage_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGroupManager(t *testing.T, size int, idleTimeout time.Duration) (*storage.GroupManager, sqlite.StorageConfig) {
+	config := sqlite.StorageConfig{UserData: t.TempDir()}
+	manager := storage.NewGroupManager(core.Sqlite, config, size, idleTimeout)
+	t.Cleanup(func() { _ = manager.Close() })
+	return manager, config
+}
+
+// usable tells if the message database of the stores is still open.
+func usable(stores *storage.GroupStores) bool {
+	_, err := stores.Messages.GetSince("", 0)
+	return err == nil
+}
+
+func TestGroupManager_Acquire(t *testing.T) {
+	t.Run("given same file twice then reuses the connection", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 2, time.Hour)
+
+		first, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		release()
+
+		second, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		defer release()
+
+		assert.Same(t, first, second)
+		assert.Equal(t, 1, manager.Len())
+		assert.True(t, usable(second))
+	})
+
+	t.Run("given full pool then closes the least recently used file", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 2, time.Hour)
+
+		f1, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		release()
+		_, release, err = manager.Acquire("f2")
+		assert.NoError(t, err)
+		release()
+		_, release, err = manager.Acquire("f1")
+		assert.NoError(t, err)
+		release()
+		f3, release, err := manager.Acquire("f3")
+		assert.NoError(t, err)
+		release()
+
+		assert.Equal(t, 2, manager.Len())
+		assert.True(t, usable(f1))
+		assert.True(t, usable(f3))
+
+		f2, release, err := manager.Acquire("f2")
+		assert.NoError(t, err)
+		defer release()
+		assert.True(t, usable(f2))
+	})
+
+	t.Run("given full pool of files in use then keeps them open", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 1, time.Hour)
+
+		f1, release1, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		f2, release2, err := manager.Acquire("f2")
+		assert.NoError(t, err)
+
+		assert.True(t, usable(f1))
+		assert.True(t, usable(f2))
+
+		release1()
+		release2()
+	})
+
+	t.Run("given release called twice then releases once", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 1, time.Hour)
+
+		f1, release1, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		_, release2, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+
+		release1()
+		release1()
+		manager.Evict("f1")
+
+		assert.True(t, usable(f1))
+		release2()
+		assert.False(t, usable(f1))
+	})
+
+	t.Run("given reopened file then does not migrate it again", func(t *testing.T) {
+		manager, config := newTestGroupManager(t, 1, time.Hour)
+
+		_, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		release()
+		manager.Evict("f1")
+
+		// Another migration run would recreate the dropped table.
+		db, err := sql.Open("sqlite", filepath.Join(config.UserData, "group-f1.sqlite"))
+		assert.NoError(t, err)
+		_, err = db.Exec("DROP TABLE messages_binary; DROP TABLE schema_migrations")
+		assert.NoError(t, err)
+		assert.NoError(t, db.Close())
+
+		f1, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		defer release()
+		assert.False(t, usable(f1))
+	})
+
+	t.Run("given closed manager then returns error", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 1, time.Hour)
+		assert.NoError(t, manager.Close())
+
+		_, _, err := manager.Acquire("f1")
+
+		assert.ErrorIs(t, err, storage.ErrGroupManagerClosed)
+	})
+}
+
+func TestGroupManager_Evict(t *testing.T) {
+	t.Run("given file in use then closes it once released", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 2, time.Hour)
+
+		f1, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+
+		manager.Evict("f1")
+		assert.Equal(t, 0, manager.Len())
+		assert.True(t, usable(f1))
+
+		release()
+		assert.False(t, usable(f1))
+	})
+
+	t.Run("given idle file then closes it after the idle timeout", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 2, 20*time.Millisecond)
+
+		f1, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		release()
+
+		assert.Eventually(t, func() bool { return manager.Len() == 0 }, time.Second, 10*time.Millisecond)
+		assert.False(t, usable(f1))
+	})
+}
+
+func TestGroupManager_Close(t *testing.T) {
+	t.Run("given open files then closes them", func(t *testing.T) {
+		manager, _ := newTestGroupManager(t, 2, time.Hour)
+
+		f1, release, err := manager.Acquire("f1")
+		assert.NoError(t, err)
+		release()
+		f2, release2, err := manager.Acquire("f2")
+		assert.NoError(t, err)
+
+		assert.NoError(t, manager.Close())
+		assert.NoError(t, manager.Close())
+
+		assert.False(t, usable(f1))
+		assert.True(t, usable(f2))
+		release2()
+		assert.False(t, usable(f2))
+	})
+}
+
+func BenchmarkGroupStores(b *testing.B) {
+	config := sqlite.StorageConfig{UserData: b.TempDir()}
+
+	b.Run("open per request", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			conn, _, messages, _, _, err := storage.NewGroupStores(core.Sqlite, config, "f1")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := messages.GetSince("", 0); err != nil {
+				b.Fatal(err)
+			}
+			conn.Close()
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		manager := storage.NewGroupManager(core.Sqlite, config, 0, 0)
+		defer manager.Close()
+
+		for i := 0; i < b.N; i++ {
+			stores, release, err := manager.Acquire("f1")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := stores.Messages.GetSince("", 0); err != nil {
+				b.Fatal(err)
+			}
+			release()
+		}
+	})
+}
+
+func TestGroupManager_Delete(t *testing.T) {
+	t.Run("given pooled group then closes and removes its database", func(t *testing.T) {
+		manager, config := newTestGroupManager(t, 2, time.Hour)
+
+		g1, release, err := manager.Acquire("g1")
+		assert.NoError(t, err)
+		release()
+
+		err = manager.Delete("g1")
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, manager.Len())
+		assert.False(t, usable(g1))
+		assert.NoFileExists(t, filepath.Join(config.UserData, "group-g1.sqlite"))
+
+		// The recreated database is migrated again.
+		g1, release, err = manager.Acquire("g1")
+		assert.NoError(t, err)
+		defer release()
+		assert.True(t, usable(g1))
+	})
+}
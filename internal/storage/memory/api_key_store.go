@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type APIKeyStore struct {
+	Keys []*core.APIKey
+}
+
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{
+		Keys: []*core.APIKey{},
+	}
+}
+
+func (a *APIKeyStore) ForHash(hash string) (*core.APIKey, error) {
+	for _, v := range a.Keys {
+		if v.KeyHash == hash {
+			return v, nil
+		}
+	}
+	return nil, internal_errors.ErrStorageRecordNotFound
+}
+
+func (a *APIKeyStore) ForUser(userID core.UserID) ([]*core.APIKey, error) {
+	keys := make([]*core.APIKey, 0)
+	for _, v := range a.Keys {
+		if v.UserID == userID {
+			keys = append(keys, v)
+		}
+	}
+	return keys, nil
+}
+
+func (a *APIKeyStore) All() ([]*core.APIKey, error) {
+	return a.Keys, nil
+}
+
+func (a *APIKeyStore) Add(key *core.APIKey) error {
+	a.Keys = append(a.Keys, key)
+	return nil
+}
+
+func (a *APIKeyStore) Touch(id core.APIKeyID, lastUsedAt time.Time) error {
+	for _, v := range a.Keys {
+		if v.APIKeyID == id {
+			v.LastUsedAt = lastUsedAt
+			return nil
+		}
+	}
+	return internal_errors.ErrStorageNoRecordUpdated
+}
+
+func (a *APIKeyStore) Delete(id core.APIKeyID) error {
+	for i, v := range a.Keys {
+		if v.APIKeyID == id {
+			a.Keys = append(a.Keys[:i], a.Keys[i+1:]...)
+			return nil
+		}
+	}
+	return internal_errors.ErrStorageNoRecordUpdated
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+type AuditStore struct {
+	Entries []*core.AuditEntry
+}
+
+func NewAuditStore() *AuditStore {
+	return &AuditStore{
+		Entries: make([]*core.AuditEntry, 0),
+	}
+}
+
+func (a *AuditStore) Add(entry *core.AuditEntry) error {
+	entry.AuditEntryID = 1
+	if n := len(a.Entries); n > 0 {
+		entry.AuditEntryID = a.Entries[n-1].AuditEntryID + 1
+	}
+	a.Entries = append(a.Entries, entry)
+	return nil
+}
+
+func (a *AuditStore) Page(before core.AuditEntryID, limit int) ([]*core.AuditEntry, error) {
+	entries := make([]*core.AuditEntry, 0)
+	for i := len(a.Entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if before > 0 && a.Entries[i].AuditEntryID >= before {
+			continue
+		}
+		entries = append(entries, a.Entries[i])
+	}
+	return entries, nil
+}
+
+func (a *AuditStore) DeleteBefore(t time.Time) (int64, error) {
+	kept := make([]*core.AuditEntry, 0, len(a.Entries))
+	for _, e := range a.Entries {
+		if !e.Timestamp.Before(t) {
+			kept = append(kept, e)
+		}
+	}
+	deleted := int64(len(a.Entries) - len(kept))
+	a.Entries = kept
+	return deleted, nil
+}
@@ -1,33 +1,72 @@
 package memory
 
 import (
+	"time"
+
 	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 )
 
 type TokenStore struct {
-	Tokens []core.Token
+	Sessions []*core.Session
 }
 
 func NewTokenStore() *TokenStore {
 	return &TokenStore{
-		Tokens: []core.Token{},
+		Sessions: []*core.Session{},
+	}
+}
+
+func (a *TokenStore) ForToken(token core.Token) (*core.Session, error) {
+	for _, v := range a.Sessions {
+		if v.Token == token {
+			return v, nil
+		}
+	}
+	return nil, internal_errors.ErrStorageRecordNotFound
+}
+
+func (a *TokenStore) ForUser(userID core.UserID) ([]*core.Session, error) {
+	sessions := make([]*core.Session, 0)
+	for _, v := range a.Sessions {
+		if v.UserID == userID {
+			sessions = append(sessions, v)
+		}
 	}
+	return sessions, nil
 }
 
-func (a *TokenStore) First() (core.Token, error) {
-	return a.Tokens[0], nil
+func (a *TokenStore) Add(session *core.Session) error {
+	a.Sessions = append(a.Sessions, session)
+	return nil
 }
 
-func (a *TokenStore) Add(token core.Token) error {
-	a.Tokens = append(a.Tokens, token)
+func (a *TokenStore) Touch(token core.Token, lastUsedAt time.Time) error {
+	s, err := a.ForToken(token)
+	if err != nil {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+	s.LastUsedAt = lastUsedAt
 	return nil
 }
 
-func (a *TokenStore) Has(token core.Token) (bool, error) {
-	for _, v := range a.Tokens {
-		if v == token {
-			return true, nil
+func (a *TokenStore) Delete(id core.SessionID) error {
+	for i, v := range a.Sessions {
+		if v.SessionID == id {
+			a.Sessions = append(a.Sessions[:i], a.Sessions[i+1:]...)
+			return nil
+		}
+	}
+	return internal_errors.ErrStorageNoRecordUpdated
+}
+
+func (a *TokenStore) DeleteExpired(now time.Time) error {
+	sessions := make([]*core.Session, 0, len(a.Sessions))
+	for _, v := range a.Sessions {
+		if !v.Expired(now) {
+			sessions = append(sessions, v)
 		}
 	}
-	return false, nil
+	a.Sessions = sessions
+	return nil
 }
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type TwoFactorStore struct {
+	TwoFactors map[core.UserID]*core.TwoFactor
+}
+
+func NewTwoFactorStore() *TwoFactorStore {
+	return &TwoFactorStore{
+		TwoFactors: map[core.UserID]*core.TwoFactor{},
+	}
+}
+
+func (a *TwoFactorStore) ForUser(userID core.UserID) (*core.TwoFactor, error) {
+	tf, ok := a.TwoFactors[userID]
+	if !ok {
+		return nil, internal_errors.ErrStorageRecordNotFound
+	}
+	// Return a copy, like a database read would.
+	c := *tf
+	c.RecoveryCodes = append([]string{}, tf.RecoveryCodes...)
+	return &c, nil
+}
+
+func (a *TwoFactorStore) Save(twoFactor *core.TwoFactor) error {
+	c := *twoFactor
+	a.TwoFactors[twoFactor.UserID] = &c
+	return nil
+}
+
+func (a *TwoFactorStore) Delete(userID core.UserID) error {
+	if _, ok := a.TwoFactors[userID]; !ok {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+	delete(a.TwoFactors, userID)
+	return nil
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type UserStore struct {
+	Users []*core.User
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		Users: []*core.User{},
+	}
+}
+
+func (it *UserStore) Count() (int, error) {
+	return len(it.Users), nil
+}
+
+func (it *UserStore) ForID(id core.UserID) (*core.User, error) {
+	for _, u := range it.Users {
+		if u.UserID == id {
+			return u, nil
+		}
+	}
+	return nil, internal_errors.ErrStorageRecordNotFound
+}
+
+func (it *UserStore) ForUserName(name string) (*core.User, error) {
+	for _, u := range it.Users {
+		if u.UserName == name {
+			return u, nil
+		}
+	}
+	return nil, internal_errors.ErrStorageRecordNotFound
+}
+
+func (it *UserStore) ForExternalID(externalID string) (*core.User, error) {
+	for _, u := range it.Users {
+		if externalID != "" && u.ExternalID == externalID {
+			return u, nil
+		}
+	}
+	return nil, internal_errors.ErrStorageRecordNotFound
+}
+
+func (it *UserStore) All() ([]*core.User, error) {
+	return it.Users, nil
+}
+
+func (it *UserStore) Add(user *core.User) error {
+	it.Users = append(it.Users, user)
+	return nil
+}
+
+func (it *UserStore) SetPassword(id core.UserID, password core.Password) error {
+	u, err := it.ForID(id)
+	if err != nil {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+	u.Password = password
+	return nil
+}
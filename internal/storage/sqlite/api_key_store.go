@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type APIKeyStore struct {
+	connection *Connection
+}
+
+func NewAPIKeyStore(connection *Connection) *APIKeyStore {
+	return &APIKeyStore{
+		connection: connection,
+	}
+}
+
+// apiKeyColumns lists the columns read by scanAPIKey, in scan order.
+const apiKeyColumns = "id, user_id, name, key_hash, scopes, created_at, last_used_at, expires_at"
+
+func scanAPIKey(row rowScanner) (*core.APIKey, error) {
+	var k core.APIKey
+	var scopes string
+	var createdAt int64
+	var lastUsedAt sql.NullInt64
+	var expiresAt sql.NullInt64
+
+	if err := row.Scan(
+		&k.APIKeyID,
+		&k.UserID,
+		&k.Name,
+		&k.KeyHash,
+		&scopes,
+		&createdAt,
+		&lastUsedAt,
+		&expiresAt,
+	); err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	k.CreatedAt = time.UnixMilli(createdAt)
+	if lastUsedAt.Valid {
+		k.LastUsedAt = time.UnixMilli(lastUsedAt.Int64)
+	}
+	if expiresAt.Valid {
+		k.ExpiresAt = time.UnixMilli(expiresAt.Int64)
+	}
+
+	return &k, nil
+}
+
+func (ks *APIKeyStore) ForHash(hash string) (*core.APIKey, error) {
+	row, err := ks.connection.First("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", hash)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (ks *APIKeyStore) ForUser(userID core.UserID) ([]*core.APIKey, error) {
+	return ks.all("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at", userID)
+}
+
+func (ks *APIKeyStore) All() ([]*core.APIKey, error) {
+	return ks.all("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY created_at")
+}
+
+func (ks *APIKeyStore) all(query string, args ...any) ([]*core.APIKey, error) {
+	rows, err := ks.connection.All(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*core.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (ks *APIKeyStore) Add(key *core.APIKey) error {
+	var lastUsedAt sql.NullInt64
+	if !key.LastUsedAt.IsZero() {
+		lastUsedAt = sql.NullInt64{Int64: key.LastUsedAt.UnixMilli(), Valid: true}
+	}
+	var expiresAt sql.NullInt64
+	if !key.ExpiresAt.IsZero() {
+		expiresAt = sql.NullInt64{Int64: key.ExpiresAt.UnixMilli(), Valid: true}
+	}
+	_, _, err := ks.connection.Mutate(
+		`INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.APIKeyID,
+		key.UserID,
+		key.Name,
+		key.KeyHash,
+		strings.Join(key.Scopes, " "),
+		key.CreatedAt.UnixMilli(),
+		lastUsedAt,
+		expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ks *APIKeyStore) Touch(id core.APIKeyID, lastUsedAt time.Time) error {
+	rows, _, err := ks.connection.Mutate(
+		"UPDATE api_keys SET last_used_at = ? WHERE id = ?",
+		lastUsedAt.UnixMilli(),
+		id,
+	)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+
+	return nil
+}
+
+func (ks *APIKeyStore) Delete(id core.APIKeyID) error {
+	rows, _, err := ks.connection.Mutate("DELETE FROM api_keys WHERE id = ?", id)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+
+	return nil
+}
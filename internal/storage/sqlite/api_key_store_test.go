@@ -0,0 +1,157 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package sqlite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAPIKeyStore(t *testing.T) (*sqlite.APIKeyStore, *sqlite.Connection) {
+	conn, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+
+	return sqlite.NewAPIKeyStore(conn), conn
+}
+
+func TestAPIKeyStore_ForHash(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		_, err := store.ForHash("hash")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given row then returns all details", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		key := &core.APIKey{
+			APIKeyID:   "k1",
+			UserID:     "u1",
+			Name:       "backup",
+			KeyHash:    "hash",
+			Scopes:     []core.Scope{core.ScopeFilesRead, core.ScopeSync},
+			CreatedAt:  time.UnixMilli(1000),
+			LastUsedAt: time.UnixMilli(2000),
+			ExpiresAt:  time.UnixMilli(3000),
+		}
+		err := store.Add(key)
+		assert.NoError(t, err)
+
+		k, err := store.ForHash("hash")
+
+		assert.NoError(t, err)
+		assert.Equal(t, key, k)
+	})
+
+	t.Run("given row without usage or expiry then times are zero", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", Name: "backup", KeyHash: "hash"})
+		assert.NoError(t, err)
+
+		k, err := store.ForHash("hash")
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, k.LastUsedAt.IsZero())
+		assert.Equal(t, true, k.ExpiresAt.IsZero())
+	})
+}
+
+func TestAPIKeyStore_ForUser(t *testing.T) {
+	t.Run("given rows for two users", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", KeyHash: "a", CreatedAt: time.UnixMilli(2)})
+		assert.NoError(t, err)
+		err = store.Add(&core.APIKey{APIKeyID: "k2", UserID: "u2", KeyHash: "b", CreatedAt: time.UnixMilli(1)})
+		assert.NoError(t, err)
+		err = store.Add(&core.APIKey{APIKeyID: "k3", UserID: "u1", KeyHash: "c", CreatedAt: time.UnixMilli(1)})
+		assert.NoError(t, err)
+
+		keys, err := store.ForUser("u1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(keys))
+		assert.Equal(t, "k3", keys[0].APIKeyID)
+		assert.Equal(t, "k1", keys[1].APIKeyID)
+
+		all, err := store.All()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(all))
+	})
+}
+
+func TestAPIKeyStore_Add(t *testing.T) {
+	t.Run("given duplicate hash then returns error", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", KeyHash: "hash"})
+		assert.NoError(t, err)
+		err = store.Add(&core.APIKey{APIKeyID: "k2", UserID: "u1", KeyHash: "hash"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestAPIKeyStore_Touch(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Touch("k1", time.UnixMilli(1000))
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given row then updates last used", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", KeyHash: "hash"})
+		assert.NoError(t, err)
+
+		err = store.Touch("k1", time.UnixMilli(5000))
+		assert.NoError(t, err)
+
+		k, err := store.ForHash("hash")
+		assert.NoError(t, err)
+		assert.Equal(t, time.UnixMilli(5000), k.LastUsedAt)
+	})
+}
+
+func TestAPIKeyStore_Delete(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Delete("k1")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given row then deletes it", func(t *testing.T) {
+		store, conn := newTestAPIKeyStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.APIKey{APIKeyID: "k1", UserID: "u1", KeyHash: "hash"})
+		assert.NoError(t, err)
+
+		err = store.Delete("k1")
+		assert.NoError(t, err)
+
+		_, err = store.ForHash("hash")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"math"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+)
+
+type AuditStore struct {
+	connection *Connection
+}
+
+func NewAuditStore(connection *Connection) *AuditStore {
+	return &AuditStore{
+		connection: connection,
+	}
+}
+
+func (as *AuditStore) Add(entry *core.AuditEntry) error {
+	_, id, err := as.connection.Mutate(
+		`INSERT INTO audit_log (timestamp, event, outcome, user_id, client_ip, session_id, file_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp.UnixMilli(),
+		entry.Event,
+		entry.Outcome,
+		entry.UserID,
+		entry.ClientIP,
+		entry.SessionID,
+		entry.FileID,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.AuditEntryID = id
+	return nil
+}
+
+func (as *AuditStore) Page(before core.AuditEntryID, limit int) ([]*core.AuditEntry, error) {
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+	rows, err := as.connection.All(
+		`SELECT id, timestamp, event, outcome, user_id, client_ip, session_id, file_id
+		FROM audit_log WHERE id < ? ORDER BY id DESC LIMIT ?`,
+		before,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*core.AuditEntry, 0)
+	for rows.Next() {
+		var e core.AuditEntry
+		var timestamp int64
+		err = rows.Scan(&e.AuditEntryID, &timestamp, &e.Event, &e.Outcome, &e.UserID, &e.ClientIP, &e.SessionID, &e.FileID)
+		if err != nil {
+			return nil, err
+		}
+		e.Timestamp = time.UnixMilli(timestamp)
+
+		entries = append(entries, &e)
+	}
+
+	return entries, nil
+}
+
+func (as *AuditStore) DeleteBefore(t time.Time) (int64, error) {
+	rows, _, err := as.connection.Mutate("DELETE FROM audit_log WHERE timestamp < ?", t.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
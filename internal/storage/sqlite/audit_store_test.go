@@ -0,0 +1,113 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package sqlite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuditStore(t *testing.T) (*sqlite.AuditStore, *sqlite.Connection) {
+	conn, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+
+	return sqlite.NewAuditStore(conn), conn
+}
+
+func addTestAuditEntries(t *testing.T, store *sqlite.AuditStore, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		err := store.Add(&core.AuditEntry{
+			Timestamp: time.UnixMilli(int64(1000 * (i + 1))),
+			Event:     core.AuditLogin,
+			Outcome:   core.AuditSuccess,
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func TestAuditStore_Add(t *testing.T) {
+	t.Run("given entry then stores all details and sets id", func(t *testing.T) {
+		store, conn := newTestAuditStore(t)
+		defer conn.Close()
+
+		entry := &core.AuditEntry{
+			Timestamp: time.UnixMilli(1000),
+			Event:     core.AuditDeleteFile,
+			Outcome:   core.AuditFailure,
+			UserID:    "u1",
+			ClientIP:  "10.0.0.1",
+			SessionID: "s1",
+			FileID:    "f1",
+		}
+		err := store.Add(entry)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), entry.AuditEntryID)
+
+		entries, err := store.Page(0, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*core.AuditEntry{entry}, entries)
+	})
+
+	t.Run("given stored entry then cannot be changed", func(t *testing.T) {
+		store, conn := newTestAuditStore(t)
+		defer conn.Close()
+		addTestAuditEntries(t, store, 1)
+
+		_, _, err := conn.Mutate("UPDATE audit_log SET outcome = ?", core.AuditFailure)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestAuditStore_Page(t *testing.T) {
+	t.Run("given no rows then returns empty page", func(t *testing.T) {
+		store, conn := newTestAuditStore(t)
+		defer conn.Close()
+
+		entries, err := store.Page(0, 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("given rows then returns pages newest first", func(t *testing.T) {
+		store, conn := newTestAuditStore(t)
+		defer conn.Close()
+		addTestAuditEntries(t, store, 5)
+
+		first, err := store.Page(0, 2)
+		assert.NoError(t, err)
+		second, err := store.Page(first[1].AuditEntryID, 2)
+		assert.NoError(t, err)
+		last, err := store.Page(second[1].AuditEntryID, 2)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []int64{5, 4}, []int64{first[0].AuditEntryID, first[1].AuditEntryID})
+		assert.Equal(t, []int64{3, 2}, []int64{second[0].AuditEntryID, second[1].AuditEntryID})
+		assert.Equal(t, 1, len(last))
+		assert.Equal(t, int64(1), last[0].AuditEntryID)
+	})
+}
+
+func TestAuditStore_DeleteBefore(t *testing.T) {
+	t.Run("given old and new rows then deletes only old ones", func(t *testing.T) {
+		store, conn := newTestAuditStore(t)
+		defer conn.Close()
+		addTestAuditEntries(t, store, 3)
+
+		deleted, err := store.DeleteBefore(time.UnixMilli(2500))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		entries, err := store.Page(0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, int64(3), entries[0].AuditEntryID)
+	})
+}
@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+)
+
+// compactedCondition selects the messages that were received and stamped
+// before the horizon. Messages stored before their arrival was recorded
+// only go by their timestamp.
+const compactedCondition = "(received_at IS NULL OR received_at < ?) AND timestamp < ?"
+
+type compactionState struct {
+	snapshotAt int64
+	horizon    string
+	// merkle is the trie of the compacted messages.
+	merkle string
+}
+
+func getCompaction(tx *sql.Tx) (*compactionState, error) {
+	stmt, err := tx.Prepare("SELECT snapshot_at, horizon, merkle FROM messages_compaction WHERE id = 1")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var state compactionState
+	err = stmt.QueryRow().Scan(&state.snapshotAt, &state.horizon, &state.merkle)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// CompactMessagesTransaction removes the messages that are part of the
+// latest snapshot and older than retention at the time it was uploaded.
+// Messages the snapshot uploader could still have missed are kept.
+//
+// The merkle trie keeps covering the removed messages, as the tries of the
+// clients that synced them do. It is rebuilt from the kept messages and the
+// trie of all messages compacted so far.
+func CompactMessagesTransaction(db *Connection, retention time.Duration) (*core.CompactionResult, error) {
+	result := &core.CompactionResult{}
+	err := db.Transaction(func(tx *sql.Tx) error {
+		state, err := getCompaction(tx)
+		if err != nil {
+			return err
+		}
+		result.Horizon = state.horizon
+		if state.snapshotAt == 0 {
+			return nil
+		}
+
+		horizonMillis := state.snapshotAt - retention.Milliseconds()
+		horizon := timestamp.NewTimestamp(horizonMillis, 0, "0000000000000000").ToString()
+		if horizon <= state.horizon {
+			return nil
+		}
+
+		compacted := merkle.NewMerkle(0)
+		if state.merkle != "" {
+			compacted, err = merkle.ParseMerkle(state.merkle)
+			if err != nil {
+				return err
+			}
+		}
+		result.Messages, result.Bytes, err = insertTimestamps(
+			tx,
+			compacted,
+			"SELECT timestamp, LENGTH(timestamp) + IFNULL(LENGTH(content), 0) FROM messages_binary WHERE "+
+				compactedCondition,
+			horizonMillis,
+			horizon,
+		)
+		if err != nil || result.Messages == 0 {
+			return err
+		}
+		compacted = compacted.Prune().(*merkle.Merkle)
+		compactedString, err := compacted.ToJSONString()
+		if err != nil {
+			return err
+		}
+
+		// Inserting changes the trie, so the kept messages go into a copy.
+		trie, err := merkle.ParseMerkle(compactedString)
+		if err != nil {
+			return err
+		}
+		_, _, err = insertTimestamps(
+			tx,
+			trie,
+			"SELECT timestamp, 0 FROM messages_binary WHERE NOT ("+compactedCondition+")",
+			horizonMillis,
+			horizon,
+		)
+		if err != nil {
+			return err
+		}
+		err = updateMessagesStore(tx, trie.Prune().(*merkle.Merkle))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM messages_binary WHERE "+compactedCondition, horizonMillis, horizon)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"UPDATE messages_compaction SET horizon = ?, merkle = ? WHERE id = 1",
+			horizon,
+			compactedString,
+		)
+		if err != nil {
+			return err
+		}
+		result.Horizon = horizon
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Messages > 0 {
+		// Hands the freed pages back to the file system.
+		if _, err = db.Exec("VACUUM"); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// insertTimestamps inserts the timestamps a query selects into the trie and
+// returns their number and the sum of the sizes selected along with them.
+func insertTimestamps(tx *sql.Tx, trie *merkle.Merkle, query string, params ...any) (int, int64, error) {
+	rows, err := tx.Query(query, params...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	count, bytes := 0, int64(0)
+	for rows.Next() {
+		var ts string
+		var size int64
+		if err = rows.Scan(&ts, &size); err != nil {
+			return 0, 0, err
+		}
+		parsed, err := timestamp.ParseTimestamp(ts)
+		if err != nil {
+			return 0, 0, err
+		}
+		trie.Insert(parsed)
+		count++
+		bytes += size
+	}
+	return count, bytes, rows.Err()
+}
@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type CompactionStore struct {
+	connection *Connection
+}
+
+func NewCompactionStore(connection *Connection) *CompactionStore {
+	return &CompactionStore{
+		connection: connection,
+	}
+}
+
+func (cs *CompactionStore) SetSnapshot(at time.Time) error {
+	_, _, err := cs.connection.Mutate(
+		"INSERT INTO messages_compaction (id, snapshot_at) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET snapshot_at = ?",
+		at.UnixMilli(),
+		at.UnixMilli(),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cs *CompactionStore) Horizon() (string, error) {
+	row, err := cs.connection.First("SELECT horizon FROM messages_compaction WHERE id = 1")
+	if err != nil {
+		return "", err
+	}
+
+	var horizon string
+	if err = row.Scan(&horizon); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return horizon, nil
+}
@@ -0,0 +1,170 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package sqlite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+const compactionTestMillis = 1000000000000
+
+// newTestCompaction stores messages stamped and received one minute apart,
+// starting at compactionTestMillis, and a snapshot uploaded a minute after
+// the last of them.
+func newTestCompaction(t *testing.T, count int) *sqlite.Connection {
+	t.Helper()
+
+	conn, err := sqlite.NewMessageConnection(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make([]*syncpb.MessageEnvelope, 0, count)
+	for i := 0; i < count; i++ {
+		messages = append(messages, testEnvelope(t, compactionTestMillis+int64(i)*60000, "S:Cash"))
+	}
+	_, err = sqlite.AddNewMessagesTransaction(conn, messages, false, 0)
+	assert.NoError(t, err)
+	for i, msg := range messages {
+		_, _, err = conn.Mutate(
+			"UPDATE messages_binary SET received_at = ? WHERE timestamp = ?",
+			compactionTestMillis+int64(i)*60000,
+			msg.Timestamp,
+		)
+		assert.NoError(t, err)
+	}
+
+	err = sqlite.NewCompactionStore(conn).SetSnapshot(time.UnixMilli(compactionTestMillis + int64(count)*60000))
+	assert.NoError(t, err)
+	return conn
+}
+
+func storedMerkle(t *testing.T, conn *sqlite.Connection) *merkle.Merkle {
+	t.Helper()
+
+	msg, err := sqlite.NewMerkleStore(conn).GetForGroup("1")
+	assert.NoError(t, err)
+	trie, err := merkle.ParseMerkle(msg.Merkle)
+	assert.NoError(t, err)
+	return trie
+}
+
+func TestCompactMessagesTransaction(t *testing.T) {
+	t.Run("given no snapshot then removes nothing", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{
+			testEnvelope(t, compactionTestMillis, "S:Cash"),
+		}, false, 0)
+		assert.NoError(t, err)
+
+		result, err := sqlite.CompactMessagesTransaction(conn, 0)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, result.Messages)
+		assert.Equal(t, "", result.Horizon)
+	})
+
+	t.Run("given snapshot then removes messages older than retention before it", func(t *testing.T) {
+		conn := newTestCompaction(t, 4)
+		before := storedMerkle(t, conn)
+
+		// The snapshot is at minute 4, so minutes 0 and 1 are older than
+		// two and a half minutes before it.
+		result, err := sqlite.CompactMessagesTransaction(conn, 150*time.Second)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, result.Messages)
+		assert.Less(t, int64(0), result.Bytes)
+		assert.Equal(t, "2001-09-09T01:48:10.000Z-0000-0000000000000000", result.Horizon)
+
+		messages, err := sqlite.NewMessageStore(conn).GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(messages))
+		assert.Equal(t, testEnvelope(t, compactionTestMillis+120000, "").Timestamp, messages[0].Timestamp)
+
+		horizon, err := sqlite.NewCompactionStore(conn).Horizon()
+		assert.NoError(t, err)
+		assert.Equal(t, result.Horizon, horizon)
+		assert.Equal(t, before.Hash, storedMerkle(t, conn).Hash)
+		_, diverged := before.Diff(storedMerkle(t, conn))
+		assert.False(t, diverged)
+	})
+
+	t.Run("given compacted group then compacting again removes nothing", func(t *testing.T) {
+		conn := newTestCompaction(t, 4)
+		first, err := sqlite.CompactMessagesTransaction(conn, 150*time.Second)
+		assert.NoError(t, err)
+
+		result, err := sqlite.CompactMessagesTransaction(conn, 150*time.Second)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, result.Messages)
+		assert.Equal(t, first.Horizon, result.Horizon)
+	})
+
+	t.Run("given old message received after the horizon then keeps it", func(t *testing.T) {
+		conn := newTestCompaction(t, 4)
+		_, _, err := conn.Mutate("UPDATE messages_binary SET received_at = ?", compactionTestMillis+240000)
+		assert.NoError(t, err)
+
+		result, err := sqlite.CompactMessagesTransaction(conn, 150*time.Second)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, result.Messages)
+		assert.Equal(t, "", result.Horizon)
+	})
+
+	t.Run("given later compaction then merkle keeps covering all messages", func(t *testing.T) {
+		conn := newTestCompaction(t, 6)
+		before := storedMerkle(t, conn)
+		_, err := sqlite.CompactMessagesTransaction(conn, 270*time.Second)
+		assert.NoError(t, err)
+
+		err = sqlite.NewCompactionStore(conn).SetSnapshot(time.UnixMilli(compactionTestMillis + 6*60000))
+		assert.NoError(t, err)
+		result, err := sqlite.CompactMessagesTransaction(conn, 90*time.Second)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 3, result.Messages)
+		assert.Equal(t, before.Hash, storedMerkle(t, conn).Hash)
+		_, diverged := before.Diff(storedMerkle(t, conn))
+		assert.False(t, diverged)
+	})
+}
+
+func TestAddNewMessagesTransaction_Compacted(t *testing.T) {
+	t.Run("given message before the horizon then rejects the batch", func(t *testing.T) {
+		conn := newTestCompaction(t, 4)
+		_, err := sqlite.CompactMessagesTransaction(conn, 150*time.Second)
+		assert.NoError(t, err)
+
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{
+			testEnvelope(t, compactionTestMillis, "S:Cash"),
+		}, false, 0)
+
+		assert.ErrorIs(t, err, internal_errors.ErrMessagesCompacted)
+	})
+
+	t.Run("given message after the horizon then stores it", func(t *testing.T) {
+		conn := newTestCompaction(t, 4)
+		_, err := sqlite.CompactMessagesTransaction(conn, 150*time.Second)
+		assert.NoError(t, err)
+		before := storedMerkle(t, conn)
+
+		msg := testEnvelope(t, compactionTestMillis+600000, "S:Cash")
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{msg}, false, 0)
+		assert.NoError(t, err)
+
+		ts, err := timestamp.ParseTimestamp(msg.Timestamp)
+		assert.NoError(t, err)
+		assert.Equal(t, before.Hash^ts.Hash(), storedMerkle(t, conn).Hash)
+	})
+}
@@ -5,13 +5,21 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	migrateSqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
-	_ "modernc.org/sqlite" // Using blank-import due to requirement by package
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
+// messageParams make writers of a message database queue up instead of
+// failing right away. Transactions take the write lock when they begin, so
+// a transaction never has to upgrade its read lock, which SQLite can only
+// resolve by failing one of the writers.
+const messageParams = "_pragma=busy_timeout(5000)&_txlock=immediate"
+
 type Connection struct {
 	db *sql.DB
 }
@@ -57,7 +65,7 @@ func NewAccountConnection(dataSource string) (*Connection, error) {
 }
 
 func NewMessageConnection(dataSource string) (*Connection, error) {
-	db, err := sql.Open("sqlite", dataSource)
+	db, err := sql.Open("sqlite", messageDataSource(dataSource))
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +98,33 @@ func NewMessageConnection(dataSource string) (*Connection, error) {
 	return conn, nil
 }
 
+// OpenMessageConnection opens a message database without migrating it. It is
+// meant for databases this process already migrated.
+func OpenMessageConnection(dataSource string) (*Connection, error) {
+	db, err := sql.Open("sqlite", messageDataSource(dataSource))
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Connection{db: db}
+
+	return conn, nil
+}
+
+func messageDataSource(dataSource string) string {
+	if strings.Contains(dataSource, "?") {
+		return dataSource + "&" + messageParams
+	}
+	return dataSource + "?" + messageParams
+}
+
+// IsBusy tells if an error is caused by another connection holding the
+// database lock for longer than the busy timeout.
+func IsBusy(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+}
+
 func (it *Connection) All(sqlString string, params ...any) (*sql.Rows, error) {
 	stmt, err := it.db.Prepare(sqlString)
 	if err != nil {
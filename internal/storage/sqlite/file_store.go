@@ -34,19 +34,24 @@ func (fs *FileStore) Count() (int, error) {
 	return count, nil
 }
 
-func (fs *FileStore) ForID(id core.FileID) (*core.File, error) {
-	row, err := fs.connection.First("SELECT * FROM files WHERE id = ?", id)
-	if err != nil {
-		return nil, err
-	}
+// fileColumns lists the columns read by scanFile, in scan order.
+const fileColumns = "id, group_id, sync_version, encrypt_meta, encrypt_keyid, encrypt_salt, encrypt_test, deleted, name, owner, content_hash, content_size"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanFile(row rowScanner) (*core.File, error) {
 	var f core.File
 	var gid sql.NullString
 	var encryptKey sql.NullString
 	var encryptSalt sql.NullString
 	var encryptTest sql.NullString
+	var owner sql.NullString
+	var contentHash sql.NullString
+	var contentSize sql.NullInt64
 
-	if err = row.Scan(
+	if err := row.Scan(
 		&f.FileID,
 		&gid,
 		&f.SyncVersion,
@@ -56,10 +61,10 @@ func (fs *FileStore) ForID(id core.FileID) (*core.File, error) {
 		&encryptTest,
 		&f.Deleted,
 		&f.Name,
+		&owner,
+		&contentHash,
+		&contentSize,
 	); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, internal_errors.ErrStorageRecordNotFound
-		}
 		return nil, err
 	}
 	if gid.Valid {
@@ -74,56 +79,55 @@ func (fs *FileStore) ForID(id core.FileID) (*core.File, error) {
 	if encryptTest.Valid {
 		f.EncryptTest = encryptTest.String
 	}
+	if owner.Valid {
+		f.Owner = owner.String
+	}
+	if contentHash.Valid {
+		f.ContentHash = contentHash.String
+	}
+	if contentSize.Valid {
+		f.ContentSize = contentSize.Int64
+	}
 
 	return &f, nil
 }
 
-func (fs *FileStore) ForIDAndDelete(id core.FileID, deleted bool) (*core.File, error) {
-	row, err := fs.connection.First("SELECT * FROM files WHERE id = ? AND deleted = ?", id, deleted)
+func (fs *FileStore) ForID(id core.FileID) (*core.File, error) {
+	row, err := fs.connection.First("SELECT "+fileColumns+" FROM files WHERE id = ?", id)
 	if err != nil {
 		return nil, err
 	}
 
-	var f core.File
-	var gid sql.NullString
-	var encryptKey sql.NullString
-	var encryptSalt sql.NullString
-	var encryptTest sql.NullString
-
-	if err = row.Scan(
-		&f.FileID,
-		&gid,
-		&f.SyncVersion,
-		&f.EncryptMeta,
-		&encryptKey,
-		&encryptSalt,
-		&encryptTest,
-		&f.Deleted,
-		&f.Name,
-	); err != nil {
+	f, err := scanFile(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, internal_errors.ErrStorageRecordNotFound
 		}
 		return nil, err
 	}
-	if gid.Valid {
-		f.GroupID = gid.String
-	}
-	if encryptKey.Valid {
-		f.EncryptKeyID = encryptKey.String
-	}
-	if encryptSalt.Valid {
-		f.EncryptSalt = encryptSalt.String
+
+	return f, nil
+}
+
+func (fs *FileStore) ForIDAndDelete(id core.FileID, deleted bool) (*core.File, error) {
+	row, err := fs.connection.First("SELECT "+fileColumns+" FROM files WHERE id = ? AND deleted = ?", id, deleted)
+	if err != nil {
+		return nil, err
 	}
-	if encryptTest.Valid {
-		f.EncryptTest = encryptTest.String
+
+	f, err := scanFile(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
 	}
 
-	return &f, nil
+	return f, nil
 }
 
 func (fs *FileStore) All() ([]*core.File, error) {
-	rows, err := fs.connection.All("SELECT * FROM files")
+	rows, err := fs.connection.All("SELECT " + fileColumns + " FROM files")
 	if err != nil {
 		return nil, err
 	}
@@ -131,39 +135,32 @@ func (fs *FileStore) All() ([]*core.File, error) {
 
 	files := make([]*core.File, 0)
 	for rows.Next() {
-		var f core.File
-		var gid sql.NullString
-		var encryptKey sql.NullString
-		var encryptSalt sql.NullString
-		var encryptTest sql.NullString
-
-		if err := rows.Scan(
-			&f.FileID,
-			&gid,
-			&f.SyncVersion,
-			&f.EncryptMeta,
-			&encryptKey,
-			&encryptSalt,
-			&encryptTest,
-			&f.Deleted,
-			&f.Name,
-		); err != nil {
+		f, err := scanFile(rows)
+		if err != nil {
 			return nil, err
 		}
-		if gid.Valid {
-			f.GroupID = gid.String
-		}
-		if encryptKey.Valid {
-			f.EncryptKeyID = encryptKey.String
-		}
-		if encryptSalt.Valid {
-			f.EncryptSalt = encryptSalt.String
-		}
-		if encryptTest.Valid {
-			f.EncryptTest = encryptTest.String
+
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+func (fs *FileStore) ForOwner(owner core.UserID) ([]*core.File, error) {
+	rows, err := fs.connection.All("SELECT "+fileColumns+" FROM files WHERE owner = ?", owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]*core.File, 0)
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
 		}
 
-		files = append(files, &f)
+		files = append(files, f)
 	}
 
 	return files, nil
@@ -188,12 +185,13 @@ func (fs *FileStore) Update(fileID string, syncVersion int16, encryptMeta string
 
 func (fs *FileStore) Add(file *core.NewFile) error {
 	_, _, err := fs.connection.Mutate(
-		"INSERT INTO files (id, group_id, sync_version, name, encrypt_meta) VALUES (?, ?, ?, ?, ?)",
+		"INSERT INTO files (id, group_id, sync_version, name, encrypt_meta, owner) VALUES (?, ?, ?, ?, ?, ?)",
 		file.FileID,
 		file.GroupID,
 		file.SyncVersion,
 		file.Name,
 		file.EncryptMeta,
+		file.Owner,
 	)
 	if err != nil {
 		return err
@@ -224,6 +222,17 @@ func (fs *FileStore) Delete(id core.FileID) error {
 	return nil
 }
 
+func (fs *FileStore) Undelete(id core.FileID) error {
+	rows, _, err := fs.connection.Mutate("UPDATE files SET deleted = FALSE WHERE id = ?", id)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+
+	return nil
+}
+
 func (fs *FileStore) UpdateName(id core.FileID, name string) error {
 	rows, _, err := fs.connection.Mutate("UPDATE files SET name = ? WHERE id = ?", name, id)
 	if err != nil {
@@ -262,3 +271,19 @@ func (fs *FileStore) UpdateEncryption(id core.FileID, salt, keyID, test string)
 
 	return nil
 }
+
+func (fs *FileStore) UpdateContent(id core.FileID, contentHash string, contentSize int64) error {
+	rows, _, err := fs.connection.Mutate(
+		"UPDATE files SET content_hash = ?, content_size = ? WHERE id = ?",
+		contentHash,
+		contentSize,
+		id,
+	)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+
+	return nil
+}
@@ -350,6 +350,35 @@ func TestFileStore_Delete(t *testing.T) {
 	})
 }
 
+func TestFileStore_Undelete(t *testing.T) {
+	t.Run("given no row with matching id", func(t *testing.T) {
+		store, conn := newTestFileStore(t)
+		defer conn.Close()
+
+		err := store.Undelete("1")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given deleted row with matching id", func(t *testing.T) {
+		store, conn := newTestFileStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.NewFile{FileID: "1", GroupID: "g1", SyncVersion: 1, EncryptMeta: "A1B2C3", Name: "Budget1"})
+		assert.NoError(t, err)
+		err = store.Delete("1")
+		assert.NoError(t, err)
+
+		err = store.Undelete("1")
+		assert.NoError(t, err)
+
+		f, err := store.ForID("1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, false, f.Deleted)
+	})
+}
+
 func TestFileStore_UpdateName(t *testing.T) {
 	t.Run("given no row with matching id", func(t *testing.T) {
 		store, conn := newTestFileStore(t)
@@ -466,3 +495,64 @@ func TestFileStore_UpdateEncryption(t *testing.T) {
 		}, f)
 	})
 }
+
+func TestFileStore_ForOwner(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestFileStore(t)
+		defer conn.Close()
+
+		files, err := store.ForOwner("u1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(files))
+	})
+
+	t.Run("given files of two owners returns only matching", func(t *testing.T) {
+		store, conn := newTestFileStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.NewFile{FileID: "1", GroupID: "g1", SyncVersion: 2, Name: "Budget1", Owner: "u1"})
+		assert.NoError(t, err)
+		err = store.Add(&core.NewFile{FileID: "2", GroupID: "g2", SyncVersion: 2, Name: "Budget2", Owner: "u2"})
+		assert.NoError(t, err)
+		err = store.Add(&core.NewFile{FileID: "3", GroupID: "g3", SyncVersion: 2, Name: "Budget3", Owner: "u1"})
+		assert.NoError(t, err)
+
+		files, err := store.ForOwner("u1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(files))
+		assert.Equal(t, "1", files[0].FileID)
+		assert.Equal(t, "u1", files[0].Owner)
+		assert.Equal(t, "3", files[1].FileID)
+		assert.Equal(t, "u1", files[1].Owner)
+	})
+}
+
+func TestFileStore_UpdateContent(t *testing.T) {
+	t.Run("given no row with matching id", func(t *testing.T) {
+		store, conn := newTestFileStore(t)
+		defer conn.Close()
+
+		err := store.UpdateContent("1", "abc", 3)
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given row with matching id", func(t *testing.T) {
+		store, conn := newTestFileStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.NewFile{FileID: "1", GroupID: "g1", SyncVersion: 1, EncryptMeta: "A1B2C3", Name: "Budget1"})
+		assert.NoError(t, err)
+
+		err = store.UpdateContent("1", "abc", 3)
+		assert.NoError(t, err)
+
+		f, err := store.ForID("1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", f.ContentHash)
+		assert.Equal(t, int64(3), f.ContentSize)
+	})
+}
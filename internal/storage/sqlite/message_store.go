@@ -30,8 +30,14 @@ func (ms *MessageStore) Add(message core.BinaryMessage) (bool, error) {
 	return false, nil
 }
 
-func (ms *MessageStore) GetSince(timestamp string) ([]*core.BinaryMessage, error) {
-	rows, err := ms.connection.All("SELECT * FROM messages_binary WHERE timestamp > ? ORDER BY timestamp", timestamp)
+func (ms *MessageStore) GetSince(timestamp string, limit int) ([]*core.BinaryMessage, error) {
+	query := "SELECT timestamp, is_encrypted, content FROM messages_binary WHERE timestamp > ? ORDER BY timestamp"
+	args := []any{timestamp}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := ms.connection.All(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -50,3 +56,17 @@ func (ms *MessageStore) GetSince(timestamp string) ([]*core.BinaryMessage, error
 
 	return messages, nil
 }
+
+func (ms *MessageStore) CountSince(timestamp string) (int, error) {
+	row, err := ms.connection.First("SELECT COUNT(*) FROM messages_binary WHERE timestamp > ?", timestamp)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err = row.Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -69,7 +69,7 @@ func TestMessageStore_GetSince(t *testing.T) {
 		assert.Equal(t, true, flag)
 
 		since := timestamp.NewTimestamp(0, 5678, "ABCDEFGH12345678")
-		messages, err := store.GetSince(since.ToString())
+		messages, err := store.GetSince(since.ToString(), 0)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 2, len(messages))
@@ -106,7 +106,7 @@ func TestMessageStore_GetSince(t *testing.T) {
 		assert.Equal(t, true, flag)
 
 		since := timestamp.NewTimestamp(100000000000, 5678, "ABCDEFGH12345678")
-		messages, err := store.GetSince(since.ToString())
+		messages, err := store.GetSince(since.ToString(), 0)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 1, len(messages))
@@ -137,9 +137,67 @@ func TestMessageStore_GetSince(t *testing.T) {
 		assert.Equal(t, true, flag)
 
 		since := timestamp.NewTimestamp(10000000000000, 5678, "ABCDEFGH12345678")
-		messages, err := store.GetSince(since.ToString())
+		messages, err := store.GetSince(since.ToString(), 0)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 0, len(messages))
 	})
 }
+
+func TestMessageStore_GetSinceLimit(t *testing.T) {
+	t.Run("given 3 rows and limit of 2 then returns the 2 oldest", func(t *testing.T) {
+		store, conn := newTestMessageStore(t)
+		defer conn.Close()
+
+		stamps := make([]string, 0, 3)
+		for _, millis := range []int64{3000, 1000, 2000} {
+			ts := timestamp.NewTimestamp(millis, 0, "ABCDEFGH12345678")
+			stamps = append(stamps, ts.ToString())
+			_, err := store.Add(core.BinaryMessage{Timestamp: ts.ToString(), IsEncrypted: true, Content: []byte{1}})
+			assert.NoError(t, err)
+		}
+
+		messages, err := store.GetSince("", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(messages))
+		assert.Equal(t, stamps[1], messages[0].Timestamp)
+		assert.Equal(t, stamps[2], messages[1].Timestamp)
+
+		messages, err = store.GetSince(messages[1].Timestamp, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, stamps[0], messages[0].Timestamp)
+	})
+}
+
+func TestMessageStore_CountSince(t *testing.T) {
+	t.Run("given 2 rows and count rows since inbetween", func(t *testing.T) {
+		store, conn := newTestMessageStore(t)
+		defer conn.Close()
+
+		ts1 := timestamp.NewTimestamp(1000000000000, 5678, "ABCDEFGH12345678")
+		_, err := store.Add(core.BinaryMessage{Timestamp: ts1.ToString(), IsEncrypted: true, Content: []byte{11, 12, 13}})
+		assert.NoError(t, err)
+
+		ts2 := timestamp.NewTimestamp(0, 1234, "12345678ABCDEFGH")
+		_, err = store.Add(core.BinaryMessage{Timestamp: ts2.ToString(), IsEncrypted: true, Content: []byte{1, 25, 17}})
+		assert.NoError(t, err)
+
+		count, err := store.CountSince(timestamp.NewTimestamp(100000000000, 0, "0000000000000000").ToString())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		count, err = store.CountSince(timestamp.NewTimestamp(0, 0, "0000000000000000").ToString())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("given no rows then returns zero", func(t *testing.T) {
+		store, conn := newTestMessageStore(t)
+		defer conn.Close()
+
+		count, err := store.CountSince(timestamp.NewTimestamp(0, 0, "0000000000000000").ToString())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id TEXT PRIMARY KEY,
+    user_name TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    is_admin BOOLEAN DEFAULT FALSE
+);
+
+-- The single password of an already bootstrapped server becomes the
+-- bootstrap (admin) user, which also takes over every existing session
+-- and file.
+INSERT INTO users (id, user_name, password, is_admin)
+SELECT lower(hex(randomblob(16))), 'admin', password, TRUE FROM auth LIMIT 1;
+
+DROP TABLE auth;
+
+ALTER TABLE sessions ADD COLUMN user_id TEXT;
+UPDATE sessions SET user_id = (SELECT id FROM users LIMIT 1);
+
+ALTER TABLE files ADD COLUMN owner TEXT;
+UPDATE files SET owner = (SELECT id FROM users LIMIT 1);
@@ -0,0 +1,16 @@
+ALTER TABLE sessions ADD COLUMN id TEXT;
+ALTER TABLE sessions ADD COLUMN device TEXT;
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN created_at INTEGER;
+ALTER TABLE sessions ADD COLUMN last_used_at INTEGER;
+ALTER TABLE sessions ADD COLUMN expires_at INTEGER;
+
+-- Timestamps are stored as unix milliseconds. Tokens issued before sessions
+-- were tracked never expire.
+UPDATE sessions SET
+    id = lower(hex(randomblob(16))),
+    created_at = CAST(strftime('%s', 'now') AS INTEGER) * 1000,
+    last_used_at = CAST(strftime('%s', 'now') AS INTEGER) * 1000;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_id ON sessions (id);
+CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id);
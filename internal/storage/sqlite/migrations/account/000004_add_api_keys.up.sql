@@ -0,0 +1,12 @@
+-- Scopes are stored space separated, timestamps as unix milliseconds.
+CREATE TABLE IF NOT EXISTS api_keys
+  (id TEXT PRIMARY KEY,
+   user_id TEXT NOT NULL,
+   name TEXT NOT NULL,
+   key_hash TEXT NOT NULL UNIQUE,
+   scopes TEXT NOT NULL,
+   created_at INTEGER NOT NULL,
+   last_used_at INTEGER,
+   expires_at INTEGER);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,7 @@
+-- Recovery codes are stored as space separated SHA-256 hashes.
+CREATE TABLE IF NOT EXISTS two_factor
+  (user_id TEXT PRIMARY KEY,
+   secret TEXT NOT NULL,
+   enabled BOOLEAN NOT NULL DEFAULT FALSE,
+   last_counter INTEGER NOT NULL DEFAULT 0,
+   recovery_codes TEXT NOT NULL DEFAULT '');
@@ -0,0 +1,19 @@
+-- Timestamps are stored as unix milliseconds. Entries may only be deleted by
+-- retention, never changed.
+CREATE TABLE IF NOT EXISTS audit_log
+  (id INTEGER PRIMARY KEY AUTOINCREMENT,
+   timestamp INTEGER NOT NULL,
+   event TEXT NOT NULL,
+   outcome TEXT NOT NULL,
+   user_id TEXT NOT NULL DEFAULT '',
+   client_ip TEXT NOT NULL DEFAULT '',
+   session_id TEXT NOT NULL DEFAULT '',
+   file_id TEXT NOT NULL DEFAULT '');
+
+CREATE INDEX IF NOT EXISTS audit_log_timestamp ON audit_log (timestamp);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_append_only
+  BEFORE UPDATE ON audit_log
+BEGIN
+  SELECT RAISE(ABORT, 'audit log is append-only');
+END;
@@ -0,0 +1,3 @@
+-- Hex encoded SHA-256 of the uploaded file. Files uploaded before have none
+-- until it is computed on their next download.
+ALTER TABLE files ADD COLUMN content_hash TEXT;
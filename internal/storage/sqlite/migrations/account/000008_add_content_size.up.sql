@@ -0,0 +1,2 @@
+-- Size in bytes of the uploaded file, recorded along with content_hash.
+ALTER TABLE files ADD COLUMN content_size INTEGER;
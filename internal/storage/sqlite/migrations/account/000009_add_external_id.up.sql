@@ -0,0 +1,4 @@
+-- Users signing in through an external identity (an OpenID issuer and
+-- subject, or a trusted proxy) are linked to it rather than matched by name.
+ALTER TABLE users ADD COLUMN external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS users_external_id ON users (external_id);
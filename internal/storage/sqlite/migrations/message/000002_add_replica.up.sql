@@ -0,0 +1,12 @@
+-- Latest value of every cell of an unencrypted file, as written by the
+-- message with the newest timestamp. Values keep the client's serialization,
+-- e.g. "S:text", "N:42" or "0:" for null.
+CREATE TABLE IF NOT EXISTS replica
+(
+    dataset TEXT NOT NULL,
+    row TEXT NOT NULL,
+    "column" TEXT NOT NULL,
+    value TEXT NOT NULL,
+    timestamp TEXT NOT NULL,
+    PRIMARY KEY (dataset, row, "column")
+);
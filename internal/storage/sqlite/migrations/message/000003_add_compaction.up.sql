@@ -0,0 +1,14 @@
+-- When the server received a message, in unix milliseconds. Messages stored
+-- before this column existed have none.
+ALTER TABLE messages_binary ADD COLUMN received_at INTEGER;
+
+-- Compaction state of the group. snapshot_at is when the latest snapshot of
+-- the file was uploaded, horizon the timestamp before which messages were
+-- removed and merkle the trie of the removed messages.
+CREATE TABLE IF NOT EXISTS messages_compaction
+(
+    id INTEGER PRIMARY KEY,
+    snapshot_at INTEGER NOT NULL DEFAULT 0,
+    horizon TEXT NOT NULL DEFAULT '',
+    merkle TEXT NOT NULL DEFAULT ''
+);
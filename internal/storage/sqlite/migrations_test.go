@@ -0,0 +1,51 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountMigrations_Users(t *testing.T) {
+	t.Run("given single password database then moves it to bootstrap user", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "account.sqlite")
+
+		db, err := sql.Open("sqlite", path)
+		assert.NoError(t, err)
+		_, err = db.Exec(`
+			CREATE TABLE schema_migrations (version uint64, dirty bool);
+			INSERT INTO schema_migrations (version, dirty) VALUES (1, false);
+			CREATE TABLE auth (password TEXT PRIMARY KEY);
+			CREATE TABLE sessions (token TEXT PRIMARY KEY);
+			CREATE TABLE files (id TEXT PRIMARY KEY, group_id TEXT, sync_version SMALLINT, encrypt_meta TEXT,
+				encrypt_keyid TEXT, encrypt_salt TEXT, encrypt_test TEXT, deleted BOOLEAN DEFAULT FALSE, name TEXT);
+			INSERT INTO auth (password) VALUES ('hash');
+			INSERT INTO sessions (token) VALUES ('token');
+			INSERT INTO files (id, group_id, sync_version, encrypt_meta, name) VALUES ('f1', 'g1', 2, '', 'budget');
+		`)
+		assert.NoError(t, err)
+		assert.NoError(t, db.Close())
+
+		conn, err := sqlite.NewAccountConnection(path)
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		user, err := sqlite.NewUserStore(conn).ForUserName("admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "hash", user.Password)
+		assert.Equal(t, true, user.IsAdmin)
+
+		session, err := sqlite.NewTokenStore(conn).ForToken("token")
+		assert.NoError(t, err)
+		assert.Equal(t, user.UserID, session.UserID)
+		assert.NotEqual(t, "", session.SessionID)
+		assert.Equal(t, true, session.ExpiresAt.IsZero())
+
+		file, err := sqlite.NewFileStore(conn).ForID("f1")
+		assert.NoError(t, err)
+		assert.Equal(t, user.UserID, file.Owner)
+	})
+}
@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type ReplicaStore struct {
+	connection *Connection
+}
+
+func NewReplicaStore(connection *Connection) *ReplicaStore {
+	return &ReplicaStore{
+		connection: connection,
+	}
+}
+
+func (rs *ReplicaStore) Get(dataset, row, column string) (*core.ReplicaValue, error) {
+	r, err := rs.connection.First(
+		`SELECT dataset, row, "column", value, timestamp FROM replica WHERE dataset = ? AND row = ? AND "column" = ?`,
+		dataset,
+		row,
+		column,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var v core.ReplicaValue
+	if err = r.Scan(&v.Dataset, &v.Row, &v.Column, &v.Value, &v.Timestamp); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+func (rs *ReplicaStore) ForDataset(dataset string) ([]*core.ReplicaValue, error) {
+	rows, err := rs.connection.All(
+		`SELECT dataset, row, "column", value, timestamp FROM replica WHERE dataset = ? ORDER BY row, "column"`,
+		dataset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]*core.ReplicaValue, 0)
+	for rows.Next() {
+		var v core.ReplicaValue
+		if err = rows.Scan(&v.Dataset, &v.Row, &v.Column, &v.Value, &v.Timestamp); err != nil {
+			return nil, err
+		}
+
+		values = append(values, &v)
+	}
+
+	return values, nil
+}
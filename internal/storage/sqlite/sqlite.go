@@ -7,25 +7,67 @@ type StorageConfig struct {
 	UserData   string
 }
 
-func NewAccountStores(dataSource string) (core.Connection, core.PasswordStore, core.TokenStore, core.FileStore, error) {
+func NewAccountStores(dataSource string) (
+	core.Connection,
+	core.UserStore,
+	core.TokenStore,
+	core.FileStore,
+	core.APIKeyStore,
+	core.TwoFactorStore,
+	core.AuditStore,
+	error,
+) {
 	db, err := NewAccountConnection(dataSource)
 	if err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, nil, err
 	}
 
-	pwdStore := NewPasswordStore(db)
+	userStore := NewUserStore(db)
 	tokenStore := NewTokenStore(db)
 	fileStore := NewFileStore(db)
-	return db, pwdStore, tokenStore, fileStore, nil
+	apiKeyStore := NewAPIKeyStore(db)
+	twoFactorStore := NewTwoFactorStore(db)
+	auditStore := NewAuditStore(db)
+	return db, userStore, tokenStore, fileStore, apiKeyStore, twoFactorStore, auditStore, nil
 }
 
-func NewGroupStores(dataSource string) (core.Connection, core.MerkleStore, core.MessageStore, error) {
+func NewGroupStores(dataSource string) (
+	core.Connection,
+	core.MerkleStore,
+	core.MessageStore,
+	core.ReplicaStore,
+	core.CompactionStore,
+	error,
+) {
 	db, err := NewMessageConnection(dataSource)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, nil, err
 	}
 
 	merkleDb := NewMerkleStore(db)
 	messageDb := NewMessageStore(db)
-	return db, merkleDb, messageDb, nil
+	replicaDb := NewReplicaStore(db)
+	compactionDb := NewCompactionStore(db)
+	return db, merkleDb, messageDb, replicaDb, compactionDb, nil
+}
+
+// OpenGroupStores is like NewGroupStores, but skips the migrations.
+func OpenGroupStores(dataSource string) (
+	core.Connection,
+	core.MerkleStore,
+	core.MessageStore,
+	core.ReplicaStore,
+	core.CompactionStore,
+	error,
+) {
+	db, err := OpenMessageConnection(dataSource)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	merkleDb := NewMerkleStore(db)
+	messageDb := NewMessageStore(db)
+	replicaDb := NewReplicaStore(db)
+	compactionDb := NewCompactionStore(db)
+	return db, merkleDb, messageDb, replicaDb, compactionDb, nil
 }
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/nathanjisaac/actual-server-go/internal/core"
 	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
@@ -18,27 +19,109 @@ func NewTokenStore(connection *Connection) *TokenStore {
 	}
 }
 
-func (a *TokenStore) First() (core.Token, error) {
-	var token core.Token
+// sessionColumns lists the columns read by scanSession, in scan order.
+const sessionColumns = "id, token, user_id, device, user_agent, created_at, last_used_at, expires_at"
+
+func scanSession(row rowScanner) (*core.Session, error) {
+	var s core.Session
+	var userID sql.NullString
+	var device sql.NullString
+	var userAgent sql.NullString
+	var createdAt sql.NullInt64
+	var lastUsedAt sql.NullInt64
+	var expiresAt sql.NullInt64
+
+	if err := row.Scan(
+		&s.SessionID,
+		&s.Token,
+		&userID,
+		&device,
+		&userAgent,
+		&createdAt,
+		&lastUsedAt,
+		&expiresAt,
+	); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		s.UserID = userID.String
+	}
+	if device.Valid {
+		s.Device = device.String
+	}
+	if userAgent.Valid {
+		s.UserAgent = userAgent.String
+	}
+	if createdAt.Valid {
+		s.CreatedAt = time.UnixMilli(createdAt.Int64)
+	}
+	if lastUsedAt.Valid {
+		s.LastUsedAt = time.UnixMilli(lastUsedAt.Int64)
+	}
+	if expiresAt.Valid {
+		s.ExpiresAt = time.UnixMilli(expiresAt.Int64)
+	}
 
-	row, err := a.connection.First("SELECT * FROM sessions")
+	return &s, nil
+}
 
+func (a *TokenStore) ForToken(token core.Token) (*core.Session, error) {
+	row, err := a.connection.First("SELECT "+sessionColumns+" FROM sessions WHERE token = ?", token)
 	if err != nil {
-		return token, err
+		return nil, err
 	}
 
-	if err = row.Scan(&token); err != nil {
+	s, err := scanSession(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return token, internal_errors.ErrStorageRecordNotFound
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (a *TokenStore) ForUser(userID core.UserID) ([]*core.Session, error) {
+	rows, err := a.connection.All(
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*core.Session, 0)
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
 		}
-		return token, err
+
+		sessions = append(sessions, s)
 	}
 
-	return token, nil
+	return sessions, nil
 }
 
-func (a *TokenStore) Add(token core.Token) error {
-	_, _, err := a.connection.Mutate("INSERT INTO sessions (token) VALUES (?)", token)
+func (a *TokenStore) Add(session *core.Session) error {
+	var expiresAt sql.NullInt64
+	if !session.ExpiresAt.IsZero() {
+		expiresAt = sql.NullInt64{Int64: session.ExpiresAt.UnixMilli(), Valid: true}
+	}
+	_, _, err := a.connection.Mutate(
+		`INSERT INTO sessions (id, token, user_id, device, user_agent, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.SessionID,
+		session.Token,
+		session.UserID,
+		session.Device,
+		session.UserAgent,
+		session.CreatedAt.UnixMilli(),
+		session.LastUsedAt.UnixMilli(),
+		expiresAt,
+	)
 	if err != nil {
 		return err
 	}
@@ -46,21 +129,40 @@ func (a *TokenStore) Add(token core.Token) error {
 	return nil
 }
 
-func (a *TokenStore) Has(token core.Token) (bool, error) {
-	var count int
-
-	row, err := a.connection.First("SELECT count(*) FROM sessions WHERE token = ?", token)
+func (a *TokenStore) Touch(token core.Token, lastUsedAt time.Time) error {
+	rows, _, err := a.connection.Mutate(
+		"UPDATE sessions SET last_used_at = ? WHERE token = ?",
+		lastUsedAt.UnixMilli(),
+		token,
+	)
 	if err != nil {
-		return false, err
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
 	}
 
-	if err = row.Scan(&count); err != nil {
-		return false, err
+	return nil
+}
+
+func (a *TokenStore) Delete(id core.SessionID) error {
+	rows, _, err := a.connection.Mutate("DELETE FROM sessions WHERE id = ?", id)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
 	}
 
-	if count > 0 {
-		return true, nil
+	return nil
+}
+
+func (a *TokenStore) DeleteExpired(now time.Time) error {
+	_, _, err := a.connection.Mutate(
+		"DELETE FROM sessions WHERE expires_at IS NOT NULL AND expires_at <= ?",
+		now.UnixMilli(),
+	)
+	if err != nil {
+		return err
 	}
 
-	return false, nil
+	return nil
 }
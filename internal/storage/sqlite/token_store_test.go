@@ -3,7 +3,9 @@ package sqlite_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/nathanjisaac/actual-server-go/internal/core"
 	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
 	"github.com/stretchr/testify/assert"
@@ -16,79 +18,156 @@ func newTestTokenStore(t *testing.T) (*sqlite.TokenStore, *sqlite.Connection) {
 	return sqlite.NewTokenStore(conn), conn
 }
 
-func TestTokenStore_First(t *testing.T) {
+func TestTokenStore_ForToken(t *testing.T) {
 	t.Run("given no rows", func(t *testing.T) {
 		store, conn := newTestTokenStore(t)
 		defer conn.Close()
 
-		_, err := store.First()
+		_, err := store.ForToken("token")
 
 		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
 	})
 
-	t.Run("given one row", func(t *testing.T) {
+	t.Run("given row then returns all details", func(t *testing.T) {
 		store, conn := newTestTokenStore(t)
 		defer conn.Close()
 
-		err := store.Add("token")
+		session := &core.Session{
+			SessionID:  "s1",
+			Token:      "token",
+			UserID:     "user1",
+			Device:     "laptop",
+			UserAgent:  "agent",
+			CreatedAt:  time.UnixMilli(1000),
+			LastUsedAt: time.UnixMilli(2000),
+			ExpiresAt:  time.UnixMilli(3000),
+		}
+		err := store.Add(session)
 		assert.NoError(t, err)
 
-		token, err := store.First()
+		s, err := store.ForToken("token")
 
 		assert.NoError(t, err)
-		assert.Equal(t, "token", token)
+		assert.Equal(t, session, s)
 	})
 
-	t.Run("given two rows then return first", func(t *testing.T) {
+	t.Run("given row without expiry then expiry is zero", func(t *testing.T) {
 		store, conn := newTestTokenStore(t)
 		defer conn.Close()
 
-		err := store.Add("a")
+		err := store.Add(&core.Session{SessionID: "s1", Token: "token", UserID: "user1"})
 		assert.NoError(t, err)
-		err = store.Add("b")
+
+		s, err := store.ForToken("token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, s.ExpiresAt.IsZero())
+	})
+}
+
+func TestTokenStore_ForUser(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestTokenStore(t)
+		defer conn.Close()
+
+		sessions, err := store.ForUser("user1")
+
 		assert.NoError(t, err)
+		assert.Equal(t, 0, len(sessions))
+	})
 
-		token, err := store.First()
+	t.Run("given rows for two users", func(t *testing.T) {
+		store, conn := newTestTokenStore(t)
+		defer conn.Close()
 
+		err := store.Add(&core.Session{SessionID: "s1", Token: "a", UserID: "user1", CreatedAt: time.UnixMilli(2)})
 		assert.NoError(t, err)
-		assert.Equal(t, "a", token)
+		err = store.Add(&core.Session{SessionID: "s2", Token: "b", UserID: "user2", CreatedAt: time.UnixMilli(1)})
+		assert.NoError(t, err)
+		err = store.Add(&core.Session{SessionID: "s3", Token: "c", UserID: "user1", CreatedAt: time.UnixMilli(1)})
+		assert.NoError(t, err)
+
+		sessions, err := store.ForUser("user1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(sessions))
+		assert.Equal(t, "s3", sessions[0].SessionID)
+		assert.Equal(t, "s1", sessions[1].SessionID)
 	})
 }
 
-func TestTokenStore_Has(t *testing.T) {
+func TestTokenStore_Touch(t *testing.T) {
 	t.Run("given no rows", func(t *testing.T) {
 		store, conn := newTestTokenStore(t)
 		defer conn.Close()
 
-		hasToken, err := store.Has("token")
+		err := store.Touch("token", time.UnixMilli(1000))
 
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given row then updates last used", func(t *testing.T) {
+		store, conn := newTestTokenStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.Session{SessionID: "s1", Token: "token", UserID: "user1"})
+		assert.NoError(t, err)
+
+		err = store.Touch("token", time.UnixMilli(5000))
+		assert.NoError(t, err)
+
+		s, err := store.ForToken("token")
 		assert.NoError(t, err)
-		assert.Equal(t, false, hasToken)
+		assert.Equal(t, time.UnixMilli(5000), s.LastUsedAt)
 	})
+}
 
-	t.Run("given one row", func(t *testing.T) {
+func TestTokenStore_Delete(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
 		store, conn := newTestTokenStore(t)
 		defer conn.Close()
 
-		err := store.Add("token")
+		err := store.Delete("s1")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given two rows then deletes matching", func(t *testing.T) {
+		store, conn := newTestTokenStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.Session{SessionID: "s1", Token: "a", UserID: "user1"})
+		assert.NoError(t, err)
+		err = store.Add(&core.Session{SessionID: "s2", Token: "b", UserID: "user1"})
 		assert.NoError(t, err)
 
-		hasToken, err := store.Has("token")
+		err = store.Delete("s1")
+		assert.NoError(t, err)
 
+		_, err = store.ForToken("a")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+		_, err = store.ForToken("b")
 		assert.NoError(t, err)
-		assert.Equal(t, true, hasToken)
 	})
+}
 
-	t.Run("given one row with miss matching token", func(t *testing.T) {
+func TestTokenStore_DeleteExpired(t *testing.T) {
+	t.Run("given expired, unexpired and never expiring rows", func(t *testing.T) {
 		store, conn := newTestTokenStore(t)
 		defer conn.Close()
 
-		err := store.Add("token")
+		err := store.Add(&core.Session{SessionID: "s1", Token: "a", UserID: "user1", ExpiresAt: time.UnixMilli(1000)})
+		assert.NoError(t, err)
+		err = store.Add(&core.Session{SessionID: "s2", Token: "b", UserID: "user1", ExpiresAt: time.UnixMilli(3000)})
+		assert.NoError(t, err)
+		err = store.Add(&core.Session{SessionID: "s3", Token: "c", UserID: "user1"})
 		assert.NoError(t, err)
 
-		hasToken, err := store.Has("other")
+		err = store.DeleteExpired(time.UnixMilli(2000))
+		assert.NoError(t, err)
 
+		sessions, err := store.ForUser("user1")
 		assert.NoError(t, err)
-		assert.Equal(t, false, hasToken)
+		assert.Equal(t, 2, len(sessions))
 	})
 }
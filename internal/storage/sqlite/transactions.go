@@ -4,15 +4,45 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/nathanjisaac/actual-server-go/internal/core"
 	"github.com/nathanjisaac/actual-server-go/internal/core/crdt"
 	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
 	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
 	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"google.golang.org/protobuf/proto"
 )
 
-func AddNewMessagesTransaction(db *Connection, messages []*syncpb.MessageEnvelope) (crdt.Merkle, error) {
+// busyRetries is how many more times a transaction is tried when the
+// database stays locked past the busy timeout.
+const busyRetries = 3
+
+// AddNewMessagesTransaction stores messages and updates the merkle trie. With
+// a positive maxDrift, the batch is rejected if any timestamp is further than
+// that ahead of the server time.
+func AddNewMessagesTransaction(
+	db *Connection,
+	messages []*syncpb.MessageEnvelope,
+	replicate bool,
+	maxDrift time.Duration,
+) (crdt.Merkle, error) {
+	trie, err := addNewMessages(db, messages, replicate, maxDrift)
+	for retry := 1; retry <= busyRetries && IsBusy(err); retry++ {
+		time.Sleep(time.Duration(retry) * 100 * time.Millisecond)
+		trie, err = addNewMessages(db, messages, replicate, maxDrift)
+	}
+	return trie, err
+}
+
+func addNewMessages(
+	db *Connection,
+	messages []*syncpb.MessageEnvelope,
+	replicate bool,
+	maxDrift time.Duration,
+) (crdt.Merkle, error) {
 	merkleTrie := merkle.NewMerkle(0)
 	err := db.Transaction(func(tx *sql.Tx) error {
 		trie, err := getMerkle(tx)
@@ -21,11 +51,39 @@ func AddNewMessagesTransaction(db *Connection, messages []*syncpb.MessageEnvelop
 		}
 
 		if len(messages) > 0 {
+			compaction, err := getCompaction(tx)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
 			for _, msg := range messages {
-				err := updateBinaryMerkleStore(tx, msg, trie)
+				ts, err := timestamp.ParseTimestamp(msg.Timestamp)
 				if err != nil {
 					return err
 				}
+				// Compacted messages are no longer stored, so they cannot
+				// tell whether a message is new. Only a client that lags
+				// behind the horizon sends such messages.
+				if msg.Timestamp < compaction.horizon {
+					return fmt.Errorf("message %s: %w", msg.Timestamp, internal_errors.ErrMessagesCompacted)
+				}
+				if maxDrift > 0 {
+					err = timestamp.CheckDrift(ts, time.Now(), maxDrift)
+					if err != nil {
+						return fmt.Errorf("message %s: %w", msg.Timestamp, err)
+					}
+				}
+
+				err = updateBinaryMerkleStore(tx, msg, ts, trie, now)
+				if err != nil {
+					return err
+				}
+				if replicate && !msg.IsEncrypted {
+					err = updateReplica(tx, msg)
+					if err != nil {
+						return err
+					}
+				}
 			}
 		}
 
@@ -73,15 +131,23 @@ func getMerkle(tx *sql.Tx) (*merkle.Merkle, error) {
 	return merkle, nil
 }
 
-func updateBinaryMerkleStore(tx *sql.Tx, msg *syncpb.MessageEnvelope, trie crdt.Merkle) error {
-	stmt, err := tx.Prepare("INSERT OR IGNORE INTO messages_binary (timestamp, is_encrypted, content) VALUES (?, ?, ?)")
+func updateBinaryMerkleStore(
+	tx *sql.Tx,
+	msg *syncpb.MessageEnvelope,
+	ts crdt.Timestamp,
+	trie crdt.Merkle,
+	now time.Time,
+) error {
+	stmt, err := tx.Prepare(
+		"INSERT OR IGNORE INTO messages_binary (timestamp, is_encrypted, content, received_at) VALUES (?, ?, ?, ?)",
+	)
 	if err != nil {
 		return err
 	}
 
 	defer stmt.Close()
 
-	result, err := stmt.Exec(msg.Timestamp, msg.IsEncrypted, msg.Content)
+	result, err := stmt.Exec(msg.Timestamp, msg.IsEncrypted, msg.Content, now.UnixMilli())
 	if err != nil {
 		return err
 	}
@@ -91,10 +157,6 @@ func updateBinaryMerkleStore(tx *sql.Tx, msg *syncpb.MessageEnvelope, trie crdt.
 		return err
 	}
 	if rows > 0 {
-		ts, err := timestamp.ParseTimestamp(msg.Timestamp)
-		if err != nil {
-			return err
-		}
 		trie.Insert(ts)
 		return nil
 	}
@@ -102,6 +164,34 @@ func updateBinaryMerkleStore(tx *sql.Tx, msg *syncpb.MessageEnvelope, trie crdt.
 	return nil
 }
 
+// updateReplica applies an unencrypted message to the replica, unless the
+// cell was already written by a newer message. Timestamps sort by time as
+// strings, and applying the same message twice changes nothing.
+func updateReplica(tx *sql.Tx, msg *syncpb.MessageEnvelope) error {
+	var content syncpb.Message
+	if err := proto.Unmarshal(msg.Content, &content); err != nil {
+		return fmt.Errorf("message %s: %w", msg.Timestamp, err)
+	}
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO replica (dataset, row, "column", value, timestamp) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (dataset, row, "column") DO UPDATE SET value = excluded.value, timestamp = excluded.timestamp
+		WHERE excluded.timestamp > replica.timestamp`,
+	)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(content.Dataset, content.Row, content.Column, content.Value, msg.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func updateMessagesStore(tx *sql.Tx, trie *merkle.Merkle) error {
 	stmt, err := tx.Prepare(
 		"INSERT INTO messages_merkles (id, merkle) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET merkle = ?",
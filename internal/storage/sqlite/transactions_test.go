@@ -0,0 +1,213 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package sqlite_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/merkle"
+	"github.com/nathanjisaac/actual-server-go/internal/core/crdt/timestamp"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/routes/syncpb"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func testEnvelope(t *testing.T, millis int64, value string) *syncpb.MessageEnvelope {
+	t.Helper()
+
+	content, err := proto.Marshal(&syncpb.Message{
+		Dataset: "accounts",
+		Row:     "a1",
+		Column:  "name",
+		Value:   value,
+	})
+	assert.NoError(t, err)
+	return &syncpb.MessageEnvelope{
+		Timestamp: timestamp.NewTimestamp(millis, 0, "ABCDEFGH12345678").ToString(),
+		Content:   content,
+	}
+}
+
+func TestAddNewMessagesTransaction_Replica(t *testing.T) {
+	t.Run("given messages for the same cell then keeps the newest value", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+		replica := sqlite.NewReplicaStore(conn)
+
+		newer := testEnvelope(t, 1000000002000, "S:Checking")
+		older := testEnvelope(t, 1000000001000, "S:Savings")
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{newer}, true, 0)
+		assert.NoError(t, err)
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{older}, true, 0)
+		assert.NoError(t, err)
+
+		v, err := replica.Get("accounts", "a1", "name")
+		assert.NoError(t, err)
+		assert.Equal(t, "S:Checking", v.Value)
+		assert.Equal(t, newer.Timestamp, v.Timestamp)
+
+		newest := testEnvelope(t, 1000000003000, "S:Cash")
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{newest}, true, 0)
+		assert.NoError(t, err)
+
+		values, err := replica.ForDataset("accounts")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(values))
+		assert.Equal(t, "S:Cash", values[0].Value)
+	})
+
+	t.Run("given encrypted message then does not apply it", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		msg := testEnvelope(t, 1000000001000, "S:Checking")
+		msg.IsEncrypted = true
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{msg}, true, 0)
+		assert.NoError(t, err)
+
+		_, err = sqlite.NewReplicaStore(conn).Get("accounts", "a1", "name")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given replication off then only stores messages", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		msg := testEnvelope(t, 1000000001000, "S:Checking")
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{msg}, false, 0)
+		assert.NoError(t, err)
+
+		_, err = sqlite.NewReplicaStore(conn).Get("accounts", "a1", "name")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+		messages, err := sqlite.NewMessageStore(conn).GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(messages))
+	})
+
+	t.Run("given undecodable message then stores nothing", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		msg := testEnvelope(t, 1000000001000, "S:Checking")
+		msg.Content = []byte{0xff, 0xff}
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{msg}, true, 0)
+		assert.Error(t, err)
+
+		messages, err := sqlite.NewMessageStore(conn).GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(messages))
+	})
+}
+
+func TestAddNewMessagesTransaction_Drift(t *testing.T) {
+	t.Run("given timestamp within drift then stores message", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		ahead := time.Now().Add(time.Minute).UnixMilli()
+		_, err = sqlite.AddNewMessagesTransaction(
+			conn, []*syncpb.MessageEnvelope{testEnvelope(t, ahead, "S:Cash")}, false, 5*time.Minute,
+		)
+		assert.NoError(t, err)
+
+		messages, err := sqlite.NewMessageStore(conn).GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(messages))
+	})
+
+	t.Run("given timestamp beyond drift then rejects the whole batch", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		now := time.Now().UnixMilli()
+		ahead := time.Now().Add(time.Hour).UnixMilli()
+		_, err = sqlite.AddNewMessagesTransaction(conn, []*syncpb.MessageEnvelope{
+			testEnvelope(t, now, "S:Checking"),
+			testEnvelope(t, ahead, "S:Cash"),
+		}, false, 5*time.Minute)
+		assert.ErrorIs(t, err, internal_errors.ErrTimestampClockDrift)
+
+		messages, err := sqlite.NewMessageStore(conn).GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(messages))
+	})
+
+	t.Run("given batch larger than a timestamp counter then stores it", func(t *testing.T) {
+		conn, err := sqlite.NewMessageConnection(":memory:")
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		ahead := time.Now().Add(time.Minute).UnixMilli()
+		messages := make([]*syncpb.MessageEnvelope, 0, timestamp.MaxCounter+2)
+		for i := int64(0); i <= timestamp.MaxCounter+1; i++ {
+			msg := testEnvelope(t, ahead, "S:Cash")
+			ts := timestamp.NewTimestamp(ahead+i/(timestamp.MaxCounter+1), i%(timestamp.MaxCounter+1), "ABCDEFGH12345678")
+			msg.Timestamp = ts.ToString()
+			messages = append(messages, msg)
+		}
+		_, err = sqlite.AddNewMessagesTransaction(conn, messages, false, 5*time.Minute)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAddNewMessagesTransaction_Concurrent(t *testing.T) {
+	t.Run("given writers on separate connections then keeps every merkle update", func(t *testing.T) {
+		dataSource := filepath.Join(t.TempDir(), "f1.sqlite")
+		conn, err := sqlite.NewMessageConnection(dataSource)
+		assert.NoError(t, err)
+		defer conn.Close()
+
+		const writers, batches = 8, 10
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			writerConn, err := sqlite.OpenMessageConnection(dataSource)
+			assert.NoError(t, err)
+			defer writerConn.Close()
+
+			wg.Add(1)
+			go func(w int, writerConn *sqlite.Connection) {
+				defer wg.Done()
+				for b := 0; b < batches; b++ {
+					millis := 1000000000000 + int64(w*batches+b)*60000
+					_, err := sqlite.AddNewMessagesTransaction(
+						writerConn,
+						[]*syncpb.MessageEnvelope{testEnvelope(t, millis, "S:Cash")},
+						false,
+						0,
+					)
+					assert.NoError(t, err)
+				}
+			}(w, writerConn)
+		}
+		wg.Wait()
+
+		messages, err := sqlite.NewMessageStore(conn).GetSince("", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, writers*batches, len(messages))
+
+		rebuilt := merkle.NewMerkle(0)
+		for _, msg := range messages {
+			ts, err := timestamp.ParseTimestamp(msg.Timestamp)
+			assert.NoError(t, err)
+			rebuilt.Insert(ts)
+		}
+		stored, err := sqlite.NewMerkleStore(conn).GetForGroup("1")
+		assert.NoError(t, err)
+		trie, err := merkle.ParseMerkle(stored.Merkle)
+		assert.NoError(t, err)
+		assert.Equal(t, rebuilt.Hash, trie.Hash)
+		rebuiltJSON, err := rebuilt.Prune().ToJSONString()
+		assert.NoError(t, err)
+		assert.Equal(t, rebuiltJSON, stored.Merkle)
+	})
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+type TwoFactorStore struct {
+	connection *Connection
+}
+
+func NewTwoFactorStore(connection *Connection) *TwoFactorStore {
+	return &TwoFactorStore{
+		connection: connection,
+	}
+}
+
+func (ts *TwoFactorStore) ForUser(userID core.UserID) (*core.TwoFactor, error) {
+	row, err := ts.connection.First(
+		"SELECT user_id, secret, enabled, last_counter, recovery_codes FROM two_factor WHERE user_id = ?",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var tf core.TwoFactor
+	var recoveryCodes string
+	if err = row.Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.LastCounter, &recoveryCodes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+	tf.RecoveryCodes = strings.Fields(recoveryCodes)
+
+	return &tf, nil
+}
+
+func (ts *TwoFactorStore) Save(twoFactor *core.TwoFactor) error {
+	_, _, err := ts.connection.Mutate(
+		`INSERT INTO two_factor (user_id, secret, enabled, last_counter, recovery_codes) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			enabled = excluded.enabled,
+			last_counter = excluded.last_counter,
+			recovery_codes = excluded.recovery_codes`,
+		twoFactor.UserID,
+		twoFactor.Secret,
+		twoFactor.Enabled,
+		twoFactor.LastCounter,
+		strings.Join(twoFactor.RecoveryCodes, " "),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *TwoFactorStore) Delete(userID core.UserID) error {
+	rows, _, err := ts.connection.Mutate("DELETE FROM two_factor WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+
+	return nil
+}
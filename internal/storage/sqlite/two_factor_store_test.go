@@ -0,0 +1,92 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTwoFactorStore(t *testing.T) (*sqlite.TwoFactorStore, *sqlite.Connection) {
+	conn, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+
+	return sqlite.NewTwoFactorStore(conn), conn
+}
+
+func TestTwoFactorStore_ForUser(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestTwoFactorStore(t)
+		defer conn.Close()
+
+		_, err := store.ForUser("u1")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given row then returns all details", func(t *testing.T) {
+		store, conn := newTestTwoFactorStore(t)
+		defer conn.Close()
+
+		tf := &core.TwoFactor{
+			UserID:        "u1",
+			Secret:        "SECRET",
+			Enabled:       true,
+			LastCounter:   42,
+			RecoveryCodes: []string{"a", "b"},
+		}
+		err := store.Save(tf)
+		assert.NoError(t, err)
+
+		res, err := store.ForUser("u1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, tf, res)
+	})
+}
+
+func TestTwoFactorStore_Save(t *testing.T) {
+	t.Run("given existing row then replaces it", func(t *testing.T) {
+		store, conn := newTestTwoFactorStore(t)
+		defer conn.Close()
+
+		err := store.Save(&core.TwoFactor{UserID: "u1", Secret: "OLD"})
+		assert.NoError(t, err)
+
+		tf := &core.TwoFactor{UserID: "u1", Secret: "NEW", Enabled: true, LastCounter: 7, RecoveryCodes: []string{"c"}}
+		err = store.Save(tf)
+		assert.NoError(t, err)
+
+		res, err := store.ForUser("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, tf, res)
+	})
+}
+
+func TestTwoFactorStore_Delete(t *testing.T) {
+	t.Run("given no rows then returns error", func(t *testing.T) {
+		store, conn := newTestTwoFactorStore(t)
+		defer conn.Close()
+
+		err := store.Delete("u1")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given row then removes it", func(t *testing.T) {
+		store, conn := newTestTwoFactorStore(t)
+		defer conn.Close()
+
+		err := store.Save(&core.TwoFactor{UserID: "u1", Secret: "SECRET"})
+		assert.NoError(t, err)
+
+		err = store.Delete("u1")
+		assert.NoError(t, err)
+
+		_, err = store.ForUser("u1")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+}
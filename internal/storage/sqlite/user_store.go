@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+)
+
+const userColumns = "id, user_name, password, is_admin, COALESCE(external_id, '')"
+
+type UserStore struct {
+	connection *Connection
+}
+
+func NewUserStore(connection *Connection) *UserStore {
+	return &UserStore{
+		connection: connection,
+	}
+}
+
+func (us *UserStore) Count() (int, error) {
+	row, err := us.connection.First("SELECT count(*) FROM users")
+
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+
+	if err = row.Scan(&count); err != nil {
+		return 0, internal_errors.ErrStorageRecordNotFound
+	}
+
+	return count, nil
+}
+
+func (us *UserStore) ForID(id core.UserID) (*core.User, error) {
+	row, err := us.connection.First("SELECT "+userColumns+" FROM users WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	var u core.User
+	if err = row.Scan(&u.UserID, &u.UserName, &u.Password, &u.IsAdmin, &u.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (us *UserStore) ForUserName(name string) (*core.User, error) {
+	row, err := us.connection.First("SELECT "+userColumns+" FROM users WHERE user_name = ?", name)
+	if err != nil {
+		return nil, err
+	}
+
+	var u core.User
+	if err = row.Scan(&u.UserID, &u.UserName, &u.Password, &u.IsAdmin, &u.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (us *UserStore) ForExternalID(externalID string) (*core.User, error) {
+	row, err := us.connection.First("SELECT "+userColumns+" FROM users WHERE external_id = ?", externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u core.User
+	if err = row.Scan(&u.UserID, &u.UserName, &u.Password, &u.IsAdmin, &u.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, internal_errors.ErrStorageRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (us *UserStore) All() ([]*core.User, error) {
+	rows, err := us.connection.All("SELECT " + userColumns + " FROM users ORDER BY user_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*core.User, 0)
+	for rows.Next() {
+		var u core.User
+
+		if err := rows.Scan(&u.UserID, &u.UserName, &u.Password, &u.IsAdmin, &u.ExternalID); err != nil {
+			return nil, err
+		}
+
+		users = append(users, &u)
+	}
+
+	return users, nil
+}
+
+func (us *UserStore) Add(user *core.User) error {
+	_, _, err := us.connection.Mutate(
+		"INSERT INTO users (id, user_name, password, is_admin, external_id) VALUES (?, ?, ?, ?, NULLIF(?, ''))",
+		user.UserID,
+		user.UserName,
+		user.Password,
+		user.IsAdmin,
+		user.ExternalID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (us *UserStore) SetPassword(id core.UserID, password core.Password) error {
+	rows, _, err := us.connection.Mutate("UPDATE users SET password = ? WHERE id = ?", password, id)
+	if err != nil {
+		return err
+	} else if rows == 0 {
+		return internal_errors.ErrStorageNoRecordUpdated
+	}
+
+	return nil
+}
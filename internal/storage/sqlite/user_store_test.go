@@ -0,0 +1,210 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	internal_errors "github.com/nathanjisaac/actual-server-go/internal/errors"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUserStore(t *testing.T) (*sqlite.UserStore, *sqlite.Connection) {
+	conn, err := sqlite.NewAccountConnection(":memory:")
+	assert.NoError(t, err)
+
+	return sqlite.NewUserStore(conn), conn
+}
+
+func TestUserStore_Count(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		c, err := store.Count()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, c)
+	})
+
+	t.Run("given two row", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "bob", Password: "password1"})
+		assert.NoError(t, err)
+
+		c, err := store.Count()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, c)
+	})
+}
+
+func TestUserStore_ForID(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		_, err := store.ForID("u1")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given two rows returns matching", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "bob", Password: "password1"})
+		assert.NoError(t, err)
+
+		u, err := store.ForID("u2")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &core.User{UserID: "u2", UserName: "bob", Password: "password1", IsAdmin: false}, u)
+	})
+}
+
+func TestUserStore_ForUserName(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		_, err := store.ForUserName("admin")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given two rows returns matching", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "bob", Password: "password1"})
+		assert.NoError(t, err)
+
+		u, err := store.ForUserName("admin")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true}, u)
+	})
+}
+
+func TestUserStore_ForExternalID(t *testing.T) {
+	t.Run("given users without external id then returns not found", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "bob", Password: "password1"})
+		assert.NoError(t, err)
+
+		_, err = store.ForExternalID("")
+
+		assert.ErrorIs(t, err, internal_errors.ErrStorageRecordNotFound)
+	})
+
+	t.Run("given linked user returns matching", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "alice", ExternalID: "proxy:alice"})
+		assert.NoError(t, err)
+
+		u, err := store.ForExternalID("proxy:alice")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &core.User{UserID: "u1", UserName: "alice", ExternalID: "proxy:alice"}, u)
+	})
+
+	t.Run("given external id linked twice then returns error", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "alice", ExternalID: "proxy:alice"})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "bob", ExternalID: "proxy:alice"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestUserStore_All(t *testing.T) {
+	t.Run("given no rows", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		users, err := store.All()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(users))
+	})
+
+	t.Run("given two rows returns both ordered by name", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u2", UserName: "bob", Password: "password1"})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true})
+		assert.NoError(t, err)
+
+		users, err := store.All()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+		assert.Equal(t, "admin", users[0].UserName)
+		assert.Equal(t, "bob", users[1].UserName)
+	})
+}
+
+func TestUserStore_Add(t *testing.T) {
+	t.Run("given duplicate user name", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0"})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "admin", Password: "password1"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestUserStore_SetPassword(t *testing.T) {
+	t.Run("given no row", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.SetPassword("u1", "password")
+		assert.ErrorIs(t, err, internal_errors.ErrStorageNoRecordUpdated)
+	})
+
+	t.Run("given two rows updates only matching", func(t *testing.T) {
+		store, conn := newTestUserStore(t)
+		defer conn.Close()
+
+		err := store.Add(&core.User{UserID: "u1", UserName: "admin", Password: "password0", IsAdmin: true})
+		assert.NoError(t, err)
+		err = store.Add(&core.User{UserID: "u2", UserName: "bob", Password: "password1"})
+		assert.NoError(t, err)
+
+		err = store.SetPassword("u2", "newPassword")
+		assert.NoError(t, err)
+
+		u, err := store.ForID("u2")
+		assert.NoError(t, err)
+		assert.Equal(t, "newPassword", u.Password)
+
+		u, err = store.ForID("u1")
+		assert.NoError(t, err)
+		assert.Equal(t, "password0", u.Password)
+	})
+}
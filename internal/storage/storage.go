@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/nathanjisaac/actual-server-go/internal/core"
 	"github.com/nathanjisaac/actual-server-go/internal/core/crdt"
@@ -56,9 +58,12 @@ func GenerateStorageConfig(storage string, options Options) core.StorageConfig {
 
 func NewAccountStores(storageType core.StorageType, config core.StorageConfig) (
 	core.Connection,
-	core.PasswordStore,
+	core.UserStore,
 	core.TokenStore,
 	core.FileStore,
+	core.APIKeyStore,
+	core.TwoFactorStore,
+	core.AuditStore,
 	error,
 ) {
 	switch storageType {
@@ -70,32 +75,128 @@ func NewAccountStores(storageType core.StorageType, config core.StorageConfig) (
 	}
 }
 
-func NewGroupStores(storageType core.StorageType, config core.StorageConfig, fileID core.FileID) (
+// groupDataSource returns the path of the message database of a sync group.
+// Every upload after a reset starts a new group, so its history starts empty.
+func groupDataSource(config core.StorageConfig, groupID string) string {
+	return filepath.Join(config.(sqlite.StorageConfig).UserData, fmt.Sprintf("group-%s.sqlite", groupID))
+}
+
+func NewGroupStores(storageType core.StorageType, config core.StorageConfig, groupID string) (
 	core.Connection,
 	core.MerkleStore,
 	core.MessageStore,
+	core.ReplicaStore,
+	core.CompactionStore,
 	error,
 ) {
-	fileName := fmt.Sprintf("%s.sqlite", fileID)
 	switch storageType {
 	case core.Sqlite:
-		return sqlite.NewGroupStores(filepath.Join(config.(sqlite.StorageConfig).UserData, fileName))
+		return sqlite.NewGroupStores(groupDataSource(config, groupID))
 	default:
 		// Default is set to Sqlite
-		return sqlite.NewGroupStores(filepath.Join(config.(sqlite.StorageConfig).UserData, fileName))
+		return sqlite.NewGroupStores(groupDataSource(config, groupID))
 	}
 }
 
+// OpenGroupStores is like NewGroupStores, but skips the migrations of
+// databases this process already migrated.
+func OpenGroupStores(storageType core.StorageType, config core.StorageConfig, groupID string) (
+	core.Connection,
+	core.MerkleStore,
+	core.MessageStore,
+	core.ReplicaStore,
+	core.CompactionStore,
+	error,
+) {
+	switch storageType {
+	case core.Sqlite:
+		return sqlite.OpenGroupStores(groupDataSource(config, groupID))
+	default:
+		// Default is set to Sqlite
+		return sqlite.OpenGroupStores(groupDataSource(config, groupID))
+	}
+}
+
+// DeleteGroupStores removes the message database of a sync group along with
+// its journal files. Removing a database that does not exist is not an error.
+func DeleteGroupStores(storageType core.StorageType, config core.StorageConfig, groupID string) error {
+	dataSource := groupDataSource(config, groupID)
+	for _, path := range []string{
+		dataSource,
+		dataSource + "-journal",
+		dataSource + "-wal",
+		dataSource + "-shm",
+	} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateGroupStores moves message databases named after their file, as
+// written by earlier versions, to the database of the file's current group.
+// Databases of files awaiting an upload after a reset are removed, since
+// their history was discarded with the reset.
+func MigrateGroupStores(storageType core.StorageType, config core.StorageConfig, files core.FileStore) error {
+	all, err := files.All()
+	if err != nil {
+		return err
+	}
+	userData := config.(sqlite.StorageConfig).UserData
+	for _, file := range all {
+		legacy := filepath.Join(userData, fmt.Sprintf("%s.sqlite", file.FileID))
+		if _, err := os.Stat(legacy); errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if file.GroupID == "" {
+			err = os.Remove(legacy)
+		} else {
+			err = os.Rename(legacy, groupDataSource(config, file.GroupID))
+		}
+		if err != nil {
+			return fmt.Errorf("file %s: %w", file.FileID, err)
+		}
+	}
+	return nil
+}
+
+// AddNewMessagesTransaction stores the messages and updates the merkle trie.
+// With replicate set, unencrypted messages are applied to the replica as well.
+// With a positive maxDrift, the whole batch is rejected if a timestamp is
+// further than that ahead of the server time.
 func AddNewMessagesTransaction(
 	storageType core.StorageType,
 	db core.Connection,
 	messages []*syncpb.MessageEnvelope,
+	replicate bool,
+	maxDrift time.Duration,
 ) (crdt.Merkle, error) {
 	switch storageType {
 	case core.Sqlite:
-		return sqlite.AddNewMessagesTransaction(db.(*sqlite.Connection), messages)
+		return sqlite.AddNewMessagesTransaction(db.(*sqlite.Connection), messages, replicate, maxDrift)
+	default:
+		// Default is set to Sqlite
+		return sqlite.AddNewMessagesTransaction(db.(*sqlite.Connection), messages, replicate, maxDrift)
+	}
+}
+
+// CompactMessagesTransaction removes the messages of a group that are part
+// of its latest snapshot and older than retention at the time it was
+// uploaded.
+func CompactMessagesTransaction(
+	storageType core.StorageType,
+	db core.Connection,
+	retention time.Duration,
+) (*core.CompactionResult, error) {
+	switch storageType {
+	case core.Sqlite:
+		return sqlite.CompactMessagesTransaction(db.(*sqlite.Connection), retention)
 	default:
 		// Default is set to Sqlite
-		return sqlite.AddNewMessagesTransaction(db.(*sqlite.Connection), messages)
+		return sqlite.CompactMessagesTransaction(db.(*sqlite.Connection), retention)
 	}
 }
@@ -0,0 +1,124 @@
+//nolint: dupl // Disabling dupl for tests. It detects similar testcases for different tests.
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nathanjisaac/actual-server-go/internal/core"
+	"github.com/nathanjisaac/actual-server-go/internal/core/lock"
+	"github.com/nathanjisaac/actual-server-go/internal/storage"
+	"github.com/nathanjisaac/actual-server-go/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateGroupStores(t *testing.T) {
+	t.Run("given databases named after files then moves them to their groups", func(t *testing.T) {
+		db, err := sqlite.NewAccountConnection(":memory:")
+		assert.NoError(t, err)
+		defer db.Close()
+		files := sqlite.NewFileStore(db)
+		err = files.Add(&core.NewFile{FileID: "f1", GroupID: "g1", SyncVersion: 2, Name: "synced", Owner: "u1"})
+		assert.NoError(t, err)
+		err = files.Add(&core.NewFile{FileID: "f2", GroupID: "g2", SyncVersion: 2, Name: "reset", Owner: "u1"})
+		assert.NoError(t, err)
+		err = files.ClearGroup("f2")
+		assert.NoError(t, err)
+		err = files.Add(&core.NewFile{FileID: "f3", GroupID: "g3", SyncVersion: 2, Name: "never synced", Owner: "u1"})
+		assert.NoError(t, err)
+
+		userData := t.TempDir()
+		for _, name := range []string{"f1.sqlite", "f2.sqlite"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(userData, name), []byte("data"), 0o600))
+		}
+
+		config := sqlite.StorageConfig{UserData: userData}
+		err = storage.MigrateGroupStores(core.Sqlite, config, files)
+		assert.NoError(t, err)
+
+		assert.NoFileExists(t, filepath.Join(userData, "f1.sqlite"))
+		assert.FileExists(t, filepath.Join(userData, "group-g1.sqlite"))
+		assert.NoFileExists(t, filepath.Join(userData, "f2.sqlite"))
+		assert.NoFileExists(t, filepath.Join(userData, "group-g3.sqlite"))
+
+		err = storage.MigrateGroupStores(core.Sqlite, config, files)
+		assert.NoError(t, err)
+		assert.FileExists(t, filepath.Join(userData, "group-g1.sqlite"))
+	})
+}
+
+func TestDeleteGroupStores(t *testing.T) {
+	t.Run("given missing database then returns no error", func(t *testing.T) {
+		config := sqlite.StorageConfig{UserData: t.TempDir()}
+
+		err := storage.DeleteGroupStores(core.Sqlite, config, "g1")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("given database with journal files then removes all of them", func(t *testing.T) {
+		config := sqlite.StorageConfig{UserData: t.TempDir()}
+		dataSource := filepath.Join(config.UserData, "group-g1.sqlite")
+		for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+			assert.NoError(t, os.WriteFile(dataSource+suffix, nil, 0o600))
+		}
+
+		err := storage.DeleteGroupStores(core.Sqlite, config, "g1")
+		assert.NoError(t, err)
+
+		for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+			assert.NoFileExists(t, dataSource+suffix)
+		}
+	})
+}
+
+func TestCompactGroups(t *testing.T) {
+	t.Run("given files then compacts the groups that have messages", func(t *testing.T) {
+		manager, config := newTestGroupManager(t, 2, time.Hour)
+		stores, release, err := manager.Acquire("g1")
+		assert.NoError(t, err)
+		assert.NoError(t, stores.Compaction.SetSnapshot(time.Now()))
+		release()
+
+		compactions, err := storage.CompactGroups(manager, nil, []*core.File{
+			{FileID: "f1", GroupID: "g1"},
+			{FileID: "f2", GroupID: ""},
+			{FileID: "f3", GroupID: "g3"},
+		}, time.Hour)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, len(compactions))
+		assert.Equal(t, "f1", compactions[0].FileID)
+		assert.Equal(t, 0, compactions[0].Messages)
+		assert.NoFileExists(t, filepath.Join(config.UserData, "group-g3.sqlite"))
+	})
+
+	t.Run("given file reset while waiting for its lock then skips the group", func(t *testing.T) {
+		manager, config := newTestGroupManager(t, 2, time.Hour)
+		stores, release, err := manager.Acquire("g1")
+		assert.NoError(t, err)
+		assert.NoError(t, stores.Compaction.SetSnapshot(time.Now()))
+		release()
+		locks := lock.NewKeyedMutex()
+
+		unlock := locks.Lock("f1")
+		done := make(chan []*storage.GroupCompaction)
+		go func() {
+			compactions, err := storage.CompactGroups(manager, locks, []*core.File{{FileID: "f1", GroupID: "g1"}}, time.Hour)
+			assert.NoError(t, err)
+			done <- compactions
+		}()
+		select {
+		case <-done:
+			t.Fatal("compacted a locked file")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.NoError(t, manager.Delete("g1"))
+		unlock()
+
+		assert.Equal(t, 0, len(<-done))
+		assert.NoFileExists(t, filepath.Join(config.UserData, "group-g1.sqlite"))
+	})
+}